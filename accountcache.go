@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	accountCache = AccountCacheRoot{
+		Issues:   make(map[string]CachedIssueAccount),
+		Projects: make(map[string]CachedProjectAccounts),
+	}
+	accountCacheMutex sync.Mutex
+)
+
+type AccountCacheRoot struct {
+	Issues   map[string]CachedIssueAccount    `json:"issues"`
+	Projects map[string]CachedProjectAccounts `json:"projects"`
+}
+
+type CachedIssueAccount struct {
+	ProjectID      string    `json:"projectId"`
+	ProjectKey     string    `json:"projectKey"`
+	ProjectName    string    `json:"projectName"`
+	DefaultAccount string    `json:"defaultAccount"`
+	Fetched        time.Time `json:"fetched"`
+}
+
+type CachedProjectAccounts struct {
+	Accounts []string  `json:"accounts"`
+	Fetched  time.Time `json:"fetched"`
+}
+
+func accountCacheTTL() time.Duration {
+	return time.Duration(trackerConfig.Accounts.CacheTTLHours) * time.Hour
+}
+
+// accountCacheKey prefixes an issue or project key with the name of its
+// connection, as the keys and IDs of different JIRAs overlap.
+func accountCacheKey(connection *jiraConnection, key string) string {
+	return connection.Config.Name + "/" + key
+}
+
+func getIssueAccount(connection *jiraConnection, issue string) CachedIssueAccount {
+	cacheKey := accountCacheKey(connection, issue)
+	accountCacheMutex.Lock()
+	cachedIssue, found := accountCache.Issues[cacheKey]
+	accountCacheMutex.Unlock()
+	if found && time.Since(cachedIssue.Fetched) <= accountCacheTTL() {
+		return cachedIssue
+	}
+
+	projectAndAccountForIssue := getProjectAndAccountForIssue(connection, issue)
+	if projectAndAccountForIssue == (IssueWithProjectAndActivity{}) {
+		// keep using what we had rather than nothing when JIRA is unreachable
+		return cachedIssue
+	}
+	cachedIssue = CachedIssueAccount{
+		ProjectID:   projectAndAccountForIssue.Fields.Project.ID,
+		ProjectKey:  projectAndAccountForIssue.Fields.Project.Key,
+		ProjectName: projectAndAccountForIssue.Fields.Project.Name,
+		Fetched:     time.Now(),
+	}
+	if projectAndAccountForIssue.Fields.Customfield10900.Key != "" {
+		cachedIssue.DefaultAccount = fmt.Sprintf("%s:%s", projectAndAccountForIssue.Fields.Customfield10900.Key, projectAndAccountForIssue.Fields.Customfield10900.Name)
+	}
+
+	accountCacheMutex.Lock()
+	accountCache.Issues[cacheKey] = cachedIssue
+	accountCacheMutex.Unlock()
+	saveAccountCache()
+	return cachedIssue
+}
+
+// getProjectAccounts caches the accounts by connection and project ID.
+func getProjectAccounts(connection *jiraConnection, projectID string) []string {
+	cacheKey := accountCacheKey(connection, projectID)
+	accountCacheMutex.Lock()
+	cachedProject, found := accountCache.Projects[cacheKey]
+	accountCacheMutex.Unlock()
+	if found && time.Since(cachedProject.Fetched) <= accountCacheTTL() {
+		return cachedProject.Accounts
+	}
+
+	accounts := getAccountsForProject(connection, projectID)
+	if len(accounts) == 0 {
+		return cachedProject.Accounts
+	}
+
+	accountCacheMutex.Lock()
+	accountCache.Projects[cacheKey] = CachedProjectAccounts{Accounts: accounts, Fetched: time.Now()}
+	accountCacheMutex.Unlock()
+	saveAccountCache()
+	return accounts
+}
+
+// getAccountOptionsForIssue returns the accounts that can be booked on an
+// issue of connection together with the account to preselect and the issue's
+// project. The account remembered for the project wins over the issue's
+// default account.
+func getAccountOptionsForIssue(connection *jiraConnection, issue string) ([]string, string, string) {
+	var accountOptions []string = make([]string, 0)
+	var standardAccount string
+	var project string
+
+	issueAccount := getIssueAccount(connection, issue)
+	if issueAccount.ProjectID == "" {
+		return accountOptions, standardAccount, project
+	}
+
+	if issueAccount.DefaultAccount != "" {
+		standardAccount = issueAccount.DefaultAccount
+		accountOptions = append(accountOptions, issueAccount.DefaultAccount)
+	}
+	project = fmt.Sprintf("%s:%s:%s", issueAccount.ProjectID, issueAccount.ProjectKey, issueAccount.ProjectName)
+	accountOptions = append(accountOptions, getProjectAccounts(connection, issueAccount.ProjectID)...)
+
+	if rememberedAccount, found := trackerConfig.Accounts.RememberedAccounts[accountCacheKey(connection, issueAccount.ProjectKey)]; found {
+		standardAccount = rememberedAccount
+		remembered := false
+		for _, option := range accountOptions {
+			if option == rememberedAccount {
+				remembered = true
+			}
+		}
+		if !remembered {
+			accountOptions = append([]string{rememberedAccount}, accountOptions...)
+		}
+	}
+	return accountOptions, standardAccount, project
+}
+
+// rememberAccountForProject preselects account for all issues of the project
+// of task on the connection of task.
+func rememberAccountForProject(task string, project string, account string) {
+	projectKey := getElementFromStringWithColon(project, 1)
+	if projectKey == "" || account == "" {
+		return
+	}
+	if trackerConfig.Accounts.RememberedAccounts == nil {
+		trackerConfig.Accounts.RememberedAccounts = make(map[string]string)
+	}
+	connection := getConnectionForTask(getElementFromStringWithColon(task, 0))
+	myLogger.Printf("Remembering account %s for project %s on %s", account, projectKey, connection.Config.Name)
+	trackerConfig.Accounts.RememberedAccounts[accountCacheKey(connection, projectKey)] = account
+	saveTrackerConfig()
+}
+
+func retrieveAccountCache() {
+	file, err := os.OpenFile(getDataFilePath("accounts.cache"), os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		myErrorLogger.Printf("Got error when reading account cache %s", err.Error())
+		return
+	}
+	defer file.Close()
+
+	accountCacheMutex.Lock()
+	defer accountCacheMutex.Unlock()
+	err = json.NewDecoder(file).Decode(&accountCache)
+	if errors.Is(err, io.EOF) {
+		return
+	}
+	if err != nil {
+		myErrorLogger.Printf("Got error when reading account cache %s", err.Error())
+		return
+	}
+	if accountCache.Issues == nil {
+		accountCache.Issues = make(map[string]CachedIssueAccount)
+	}
+	if accountCache.Projects == nil {
+		accountCache.Projects = make(map[string]CachedProjectAccounts)
+	}
+	// issues were cached without their connection before
+	for issue := range accountCache.Issues {
+		if !strings.Contains(issue, "/") {
+			delete(accountCache.Issues, issue)
+		}
+	}
+	myLogger.Printf("Retrieved account cache of %d issues and %d projects", len(accountCache.Issues), len(accountCache.Projects))
+}
+
+func saveAccountCache() {
+	accountCacheMutex.Lock()
+	defer accountCacheMutex.Unlock()
+
+	for issue, cachedIssue := range accountCache.Issues {
+		if time.Since(cachedIssue.Fetched) > accountCacheTTL() {
+			delete(accountCache.Issues, issue)
+		}
+	}
+	for projectID, cachedProject := range accountCache.Projects {
+		if time.Since(cachedProject.Fetched) > accountCacheTTL() {
+			delete(accountCache.Projects, projectID)
+		}
+	}
+
+	data, err := json.Marshal(&accountCache)
+	if err == nil {
+		err = writeFileAtomically(getDataFilePath("accounts.cache"), data)
+	}
+	if err != nil {
+		myErrorLogger.Printf("Got error when writing account cache %s", err.Error())
+		return
+	}
+	myLogger.Printf("wrote %d bytes to account cache\n", len(data))
+}
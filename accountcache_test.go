@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestAccountJIRA serves an issue whose default account and project
+// account are named after the server.
+func newTestAccountJIRA(t *testing.T, name string) *jiraConnection {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/issue/") {
+			fmt.Fprintf(w, `{"key":"ABC-1","fields":{"project":{"id":"10000","key":"ABC","name":"Alpha"},"customfield_10900":{"key":"%s-DEFAULT","name":"Default"}}}`, name)
+			return
+		}
+		fmt.Fprintf(w, `{"accounts":[{"key":"%s-PROJECT","name":"Project"}]}`, name)
+	}))
+	t.Cleanup(server.Close)
+	connection := newJIRAConnection(ConnectionConfig{Name: name, BaseURL: server.URL})
+	connection.JIRA.Trace = nil
+	return connection
+}
+
+func TestAccountsAreKeptPerConnection(t *testing.T) {
+	useTempDataDirectory(t)
+	useWorkLogHistory(t, WorkLogHistoryRoot{})
+	useJIRAConnections(t, newTestAccountJIRA(t, "first"), newTestAccountJIRA(t, "second"))
+	previousCache, previousConfig := accountCache, trackerConfig
+	accountCache = AccountCacheRoot{Issues: make(map[string]CachedIssueAccount), Projects: make(map[string]CachedProjectAccounts)}
+	trackerConfig.Accounts = AccountsConfig{CacheTTLHours: 1, RememberedAccounts: map[string]string{"first/ABC": "first-REMEMBERED:Remembered"}}
+	t.Cleanup(func() { accountCache, trackerConfig = previousCache, previousConfig })
+
+	tests := []struct {
+		connection string
+		options    []string
+		standard   string
+	}{
+		{connection: "first", options: []string{"first-REMEMBERED:Remembered", "first-DEFAULT:Default", "first-PROJECT: Project"}, standard: "first-REMEMBERED:Remembered"},
+		{connection: "second", options: []string{"second-DEFAULT:Default", "second-PROJECT: Project"}, standard: "second-DEFAULT:Default"},
+		{connection: "first", options: []string{"first-REMEMBERED:Remembered", "first-DEFAULT:Default", "first-PROJECT: Project"}, standard: "first-REMEMBERED:Remembered"},
+	}
+	for _, test := range tests {
+		bindTaskToConnection("ABC-1", test.connection)
+		options, standard, _ := getAccountOptionsForIssue(getConnectionForTask("ABC-1"), "ABC-1")
+		if strings.Join(options, ",") != strings.Join(test.options, ",") || standard != test.standard {
+			t.Errorf("getAccountOptionsForIssue() on %s = %v, %q, want %v, %q", test.connection, options, standard, test.options, test.standard)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+const windowsLogFileName = "windows.log"
+
+var (
+	errWindowSourceUnsupported = errors.New("the focused window cannot be read on this platform, set a command")
+
+	openWindowSegment  *WindowSegment
+	windowSegmentMutex sync.Mutex
+	activityWindow     fyne.Window
+	activityButton     *widget.Button
+)
+
+// windowSource tells which application and window have the focus.
+type windowSource interface {
+	activeWindow() (string, string, error)
+}
+
+// commandWindowSource runs a command that prints the application and the
+// title of the focused window, separated by a tab, for platforms and desktops
+// the tracker cannot ask itself, such as Wayland compositors.
+type commandWindowSource struct {
+	command []string
+}
+
+// WindowSegment is a stretch of time during which the same window had the
+// focus and the user was active, one JSON line each in windows.log.
+type WindowSegment struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	App   string    `json:"app"`
+	Title string    `json:"title"`
+}
+
+// windowSuggestion is untracked time in windows matched by the same rule,
+// Window being the title the most time was spent in.
+type windowSuggestion struct {
+	Entry  WorkLogHistoryEntry
+	Start  time.Time
+	End    time.Time
+	Window string
+}
+
+func (suggestion windowSuggestion) key() string {
+	return fmt.Sprintf("window/%s@%d", suggestion.Entry.Task, suggestion.Start.Unix())
+}
+
+func (source commandWindowSource) activeWindow() (string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, source.command[0], source.command[1:]...).Output()
+	if err != nil {
+		return "", "", err
+	}
+	line, _, _ := strings.Cut(string(output), "\n")
+	app, title, found := strings.Cut(strings.TrimRight(line, "\r"), "\t")
+	if !found {
+		return "", app, nil
+	}
+	return app, title, nil
+}
+
+func getSampleInterval() time.Duration {
+	return time.Duration(max(trackerConfig.WindowTracking.SampleSeconds, 1)) * time.Second
+}
+
+// setupWindowTracking samples the focused window from then on, when window
+// tracking is enabled, after dropping the samples older than the retention.
+func setupWindowTracking() {
+	config := trackerConfig.WindowTracking
+	if !config.Enabled {
+		return
+	}
+	var source windowSource
+	if command := strings.Fields(config.Command); len(command) > 0 {
+		source = commandWindowSource{command: command}
+	} else {
+		platformSource, err := newPlatformWindowSource()
+		if err != nil {
+			myErrorLogger.Printf("Got error when setting up window tracking %s", err.Error())
+			return
+		}
+		source = platformSource
+	}
+	pruneWindowSegments(time.Now().AddDate(0, 0, -max(config.RetentionDays, 1)))
+	myLogger.Printf("Tracking the focused window every %s", getSampleInterval().String())
+
+	go func() {
+		for now := range time.Tick(getSampleInterval()) {
+			sampleWindow(now, source, getIdleDuration() > time.Duration(config.IdleSeconds)*time.Second)
+		}
+	}()
+}
+
+// sampleWindow records the window source reports focused at now, or that no
+// window counts while the user is idle or the source fails.
+func sampleWindow(now time.Time, source windowSource, idle bool) {
+	if idle {
+		recordWindowSample(now, "", "", false)
+		return
+	}
+	app, title, err := source.activeWindow()
+	if err != nil {
+		trackerLogger.Debug("Could not read the focused window", "error", err.Error())
+	}
+	recordWindowSample(now, app, title, err == nil)
+}
+
+// recordWindowSample extends the open segment while the same window keeps
+// the focus, and writes it to windows.log when another window gets it, the
+// user goes idle or samples went missing, e.g. during standby.
+func recordWindowSample(now time.Time, app string, title string, active bool) {
+	windowSegmentMutex.Lock()
+	defer windowSegmentMutex.Unlock()
+
+	segment := openWindowSegment
+	missedSamples := segment != nil && now.Sub(segment.End) > 2*getSampleInterval()
+	if segment != nil && (!active || missedSamples || segment.App != app || segment.Title != title) {
+		// the other window got the focus some time since the last sample
+		if active && !missedSamples {
+			segment.End = now
+		}
+		writeWindowSegment(*segment)
+		openWindowSegment = nil
+	}
+	if !active {
+		return
+	}
+	if openWindowSegment == nil {
+		openWindowSegment = &WindowSegment{Start: now, App: app, Title: title}
+	}
+	openWindowSegment.End = now
+}
+
+func writeWindowSegment(segment WindowSegment) {
+	if !segment.End.After(segment.Start) {
+		return
+	}
+	data, err := json.Marshal(segment)
+	if err != nil {
+		myErrorLogger.Printf("Got error when recording window %s", err.Error())
+		return
+	}
+	file, err := os.OpenFile(getDataFilePath(windowsLogFileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		myErrorLogger.Printf("Got error when recording window %s", err.Error())
+		return
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	if err != nil {
+		myErrorLogger.Printf("Got error when recording window %s", err.Error())
+	}
+}
+
+// readWindowSegments returns the recorded segments that end after from,
+// including the open one.
+func readWindowSegments(from time.Time) []WindowSegment {
+	var segments []WindowSegment
+	file, err := os.Open(getDataFilePath(windowsLogFileName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		myErrorLogger.Printf("Got error when reading windows %s", err.Error())
+	}
+	if err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var segment WindowSegment
+			if json.Unmarshal(scanner.Bytes(), &segment) == nil && segment.End.After(from) {
+				segments = append(segments, segment)
+			}
+		}
+	}
+
+	windowSegmentMutex.Lock()
+	if openWindowSegment != nil && openWindowSegment.End.After(from) {
+		segments = append(segments, *openWindowSegment)
+	}
+	windowSegmentMutex.Unlock()
+	return segments
+}
+
+func pruneWindowSegments(before time.Time) {
+	windowSegmentMutex.Lock()
+	defer windowSegmentMutex.Unlock()
+
+	data, err := os.ReadFile(getDataFilePath(windowsLogFileName))
+	if err != nil {
+		return
+	}
+	var kept []byte
+	for _, line := range strings.Split(string(data), "\n") {
+		var segment WindowSegment
+		if json.Unmarshal([]byte(line), &segment) == nil && !segment.End.Before(before) {
+			kept = append(kept, line+"\n"...)
+		}
+	}
+	if len(kept) == len(data) {
+		return
+	}
+	err = writeFileAtomically(getDataFilePath(windowsLogFileName), kept)
+	if err != nil {
+		myErrorLogger.Printf("Got error when pruning windows %s", err.Error())
+	}
+}
+
+// getTaskForWindow applies the first window rule matching the application
+// and the title of a window.
+func getTaskForWindow(app string, title string) (WindowRule, string, bool) {
+	for _, rule := range trackerConfig.WindowTracking.Rules {
+		appPattern, err := regexp.Compile(rule.App)
+		if err != nil {
+			myWarningLogger.Printf("Ignoring window rule with invalid app pattern %s: %s", rule.App, err.Error())
+			continue
+		}
+		titlePattern, err := regexp.Compile(rule.Title)
+		if err != nil {
+			myWarningLogger.Printf("Ignoring window rule with invalid title pattern %s: %s", rule.Title, err.Error())
+			continue
+		}
+		match := titlePattern.FindStringSubmatchIndex(title)
+		if match == nil || !appPattern.MatchString(app) {
+			continue
+		}
+		task := strings.TrimSpace(string(titlePattern.ExpandString(nil, rule.Task, title, match)))
+		if task != "" {
+			return rule, task, true
+		}
+	}
+	return WindowRule{}, "", false
+}
+
+// getWindowSuggestions turns the untracked time of the day in windows that
+// match a rule into entries to book. Stretches of the same task with gaps up
+// to MergeGapMinutes between them make one entry; entries shorter than
+// MinimumMinutes are left out, as are dismissed ones.
+func getWindowSuggestions(day time.Time) []windowSuggestion {
+	config := trackerConfig.WindowTracking
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+	to := from.AddDate(0, 0, 1)
+	tracked := getTrackedIntervals(from, time.Now())
+
+	type taskPiece struct {
+		Task     string
+		Rule     WindowRule
+		Interval trackedInterval
+		Title    string
+	}
+	var pieces []taskPiece
+	for _, segment := range readWindowSegments(from) {
+		if !segment.Start.Before(to) {
+			continue
+		}
+		rule, task, found := getTaskForWindow(segment.App, segment.Title)
+		if !found {
+			continue
+		}
+		for _, interval := range subtractTrackedIntervals(trackedInterval{Start: segment.Start, End: segment.End}, tracked) {
+			pieces = append(pieces, taskPiece{Task: task, Rule: rule, Interval: interval, Title: segment.Title})
+		}
+	}
+	sort.Slice(pieces, func(i, j int) bool {
+		return pieces[i].Interval.Start.Before(pieces[j].Interval.Start)
+	})
+
+	var suggestions []windowSuggestion
+	var titleDurations map[string]time.Duration
+	entries := make(map[string]WorkLogHistoryEntry)
+	mergeGap := time.Duration(config.MergeGapMinutes) * time.Minute
+	closeSuggestion := func() {
+		last := &suggestions[len(suggestions)-1]
+		for title, duration := range titleDurations {
+			if last.Window == "" || duration > titleDurations[last.Window] {
+				last.Window = title
+			}
+		}
+	}
+	for _, piece := range pieces {
+		if len(suggestions) > 0 {
+			last := &suggestions[len(suggestions)-1]
+			if last.Entry.Task == piece.Task && piece.Interval.Start.Sub(last.End) <= mergeGap {
+				last.End = piece.Interval.End
+				titleDurations[piece.Title] += piece.Interval.End.Sub(piece.Interval.Start)
+				continue
+			}
+			closeSuggestion()
+		}
+		entry, found := entries[piece.Task]
+		if !found {
+			entry = getHistoryEntryForTask(piece.Task)
+			if piece.Rule.Account != "" {
+				entry.Account = getElementFromStringWithColon(piece.Rule.Account, 0)
+				entry.AccountName = getElementFromStringWithColon(piece.Rule.Account, 1)
+			}
+			if piece.Rule.Activity != "" {
+				entry.Activity = getActivityValue(piece.Rule.Activity)
+			}
+			entries[piece.Task] = entry
+		}
+		suggestions = append(suggestions, windowSuggestion{Entry: entry, Start: piece.Interval.Start, End: piece.Interval.End})
+		titleDurations = map[string]time.Duration{piece.Title: piece.Interval.End.Sub(piece.Interval.Start)}
+	}
+	if len(suggestions) > 0 {
+		closeSuggestion()
+	}
+
+	var kept []windowSuggestion
+	for _, suggestion := range suggestions {
+		if suggestion.End.Sub(suggestion.Start) >= time.Duration(config.MinimumMinutes)*time.Minute && !isSuggestionDismissed(suggestion.key()) {
+			kept = append(kept, suggestion)
+		}
+	}
+	return kept
+}
+
+// showActivityWindow lists the suggested entries of today from the window
+// activity, to book or dismiss them one by one. Nothing is booked without
+// being confirmed here.
+func showActivityWindow() {
+	if activityWindow != nil {
+		activityWindow.RequestFocus()
+		return
+	}
+	activityWindow = fyne.CurrentApp().NewWindow("Activity")
+	window := activityWindow
+	window.SetOnClosed(func() {
+		activityWindow = nil
+	})
+
+	rows := container.NewVBox()
+	var fill func()
+	fill = func() {
+		rows.RemoveAll()
+		suggestions := getWindowSuggestions(time.Now())
+		if len(suggestions) == 0 {
+			rows.Add(widget.NewLabel("No untracked activity matches a window rule today."))
+		}
+		for _, suggestion := range suggestions {
+			suggestion := suggestion
+			label := fmt.Sprintf("%s-%s %s · %s", suggestion.Start.Format("15:04"), suggestion.End.Format("15:04"), suggestion.Entry.Task, firstN(suggestion.Window, 60))
+			// the window title stays local, it is not used as the comment
+			bookButton := widget.NewButton("Book", func() {
+				bookPastWork(suggestion.Entry, suggestion.Start, suggestion.End)
+				fill()
+			})
+			dismissButton := widget.NewButton("Dismiss", func() {
+				dismissSuggestion(suggestion.key(), suggestion.End, time.Now().AddDate(0, 0, -max(trackerConfig.WindowTracking.RetentionDays, 1)))
+				fill()
+			})
+			rows.Add(container.NewBorder(nil, nil, nil, container.NewHBox(bookButton, dismissButton), widget.NewLabel(label)))
+		}
+		rows.Refresh()
+	}
+
+	fill()
+	window.SetContent(container.NewBorder(nil, widget.NewButton("Refresh", fill), nil, nil, container.NewVScroll(rows)))
+	window.Resize(fyne.NewSize(800, 400))
+	window.CenterOnScreen()
+	window.Show()
+}
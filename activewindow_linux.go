@@ -0,0 +1,86 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// x11WindowSource follows _NET_ACTIVE_WINDOW of the root window, which the
+// window managers of all common desktops maintain, and names the application
+// after the class in WM_CLASS.
+type x11WindowSource struct {
+	X     *xgb.Conn
+	root  xproto.Window
+	atoms map[string]xproto.Atom
+}
+
+func newPlatformWindowSource() (windowSource, error) {
+	X, err := xgb.NewConn()
+	if err != nil {
+		return nil, err
+	}
+	source := &x11WindowSource{X: X, root: xproto.Setup(X).DefaultScreen(X).Root, atoms: make(map[string]xproto.Atom)}
+	if _, err := source.atom("_NET_ACTIVE_WINDOW"); err != nil {
+		X.Close()
+		return nil, err
+	}
+	return source, nil
+}
+
+func (source *x11WindowSource) atom(name string) (xproto.Atom, error) {
+	if atom, found := source.atoms[name]; found {
+		return atom, nil
+	}
+	reply, err := xproto.InternAtom(source.X, false, uint16(len(name)), name).Reply()
+	if err != nil {
+		return 0, err
+	}
+	source.atoms[name] = reply.Atom
+	return reply.Atom, nil
+}
+
+func (source *x11WindowSource) property(window xproto.Window, name string) ([]byte, error) {
+	atom, err := source.atom(name)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := xproto.GetProperty(source.X, false, window, atom, xproto.GetPropertyTypeAny, 0, 1024).Reply()
+	if err != nil {
+		return nil, err
+	}
+	return reply.Value, nil
+}
+
+func (source *x11WindowSource) activeWindow() (string, string, error) {
+	value, err := source.property(source.root, "_NET_ACTIVE_WINDOW")
+	if err != nil {
+		return "", "", err
+	}
+	if len(value) < 4 {
+		return "", "", errors.New("the window manager does not report the active window")
+	}
+	window := xproto.Window(xgb.Get32(value))
+	if window == 0 {
+		return "", "", nil
+	}
+
+	title, err := source.property(window, "_NET_WM_NAME")
+	if err == nil && len(title) == 0 {
+		title, err = source.property(window, "WM_NAME")
+	}
+	if err != nil {
+		return "", "", err
+	}
+	// WM_CLASS holds the instance and the class, each terminated by a NUL
+	class, err := source.property(window, "WM_CLASS")
+	if err != nil {
+		return "", "", err
+	}
+	names := strings.Split(strings.TrimRight(string(class), "\x00"), "\x00")
+	return names[len(names)-1], string(title), nil
+}
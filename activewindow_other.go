@@ -0,0 +1,7 @@
+//go:build !windows && !linux
+
+package main
+
+func newPlatformWindowSource() (windowSource, error) {
+	return nil, errWindowSourceUnsupported
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeWindowSource reports the windows in turn, one per sample.
+type fakeWindowSource struct {
+	windows []fakeWindow
+}
+
+type fakeWindow struct {
+	app   string
+	title string
+	err   error
+}
+
+func (source *fakeWindowSource) activeWindow() (string, string, error) {
+	window := source.windows[0]
+	source.windows = source.windows[1:]
+	return window.app, window.title, window.err
+}
+
+func useWindowSegments(t *testing.T) {
+	useTempDataDirectory(t)
+	previousSegment, previousConfig := openWindowSegment, trackerConfig
+	openWindowSegment = nil
+	t.Cleanup(func() { openWindowSegment, trackerConfig = previousSegment, previousConfig })
+}
+
+func TestRecordWindowSample(t *testing.T) {
+	useWindowSegments(t)
+	trackerConfig.WindowTracking.SampleSeconds = 10
+	start := time.Date(2024, 3, 11, 9, 0, 0, 0, time.Local)
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+
+	samples := []struct {
+		seconds int
+		idle    bool
+		window  fakeWindow
+	}{
+		{seconds: 0, window: fakeWindow{app: "editor", title: "main.go"}},
+		{seconds: 10, window: fakeWindow{app: "editor", title: "main.go"}},
+		// another window got the focus
+		{seconds: 20, window: fakeWindow{app: "browser", title: "ABC-1"}},
+		{seconds: 30, window: fakeWindow{app: "browser", title: "ABC-1"}},
+		{seconds: 40, idle: true},
+		{seconds: 50, window: fakeWindow{app: "browser", title: "ABC-1"}},
+		{seconds: 60, window: fakeWindow{app: "browser", title: "ABC-1"}},
+		// samples went missing during standby
+		{seconds: 120, window: fakeWindow{app: "browser", title: "ABC-1"}},
+		{seconds: 130, window: fakeWindow{err: errors.New("no display")}},
+		{seconds: 140, window: fakeWindow{app: "editor", title: "test.go"}},
+		{seconds: 150, window: fakeWindow{app: "editor", title: "test.go"}},
+	}
+	source := &fakeWindowSource{}
+	for _, sample := range samples {
+		if !sample.idle {
+			source.windows = append(source.windows, sample.window)
+		}
+	}
+	for _, sample := range samples {
+		sampleWindow(at(sample.seconds), source, sample.idle)
+	}
+
+	want := []WindowSegment{
+		{Start: at(0), End: at(20), App: "editor", Title: "main.go"},
+		{Start: at(20), End: at(30), App: "browser", Title: "ABC-1"},
+		{Start: at(50), End: at(60), App: "browser", Title: "ABC-1"},
+		{Start: at(140), End: at(150), App: "editor", Title: "test.go"},
+	}
+	got := readWindowSegments(start.Add(-time.Minute))
+	if len(got) != len(want) {
+		t.Fatalf("readWindowSegments() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if !got[i].Start.Equal(want[i].Start) || !got[i].End.Equal(want[i].End) || got[i].App != want[i].App || got[i].Title != want[i].Title {
+			t.Errorf("segment %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestGetWindowSuggestions(t *testing.T) {
+	useUTCAsLocalTime(t)
+	useWindowSegments(t)
+	useWorkLogHistory(t, WorkLogHistoryRoot{WorkLogHistory: []WorkLogHistoryEntry{
+		{Task: "ABC-1", TaskName: "Fix login", Count: 1},
+		{Task: "ABC-2", TaskName: "Add logout", Count: 1},
+	}})
+	connection := newJIRAConnection(ConnectionConfig{Name: "server", BaseURL: "http://jira.invalid"})
+	connection.workAttributesFetched = true
+	useJIRAConnections(t, connection)
+	trackerConfig.WindowTracking = WindowTrackingConfig{
+		SampleSeconds:   10,
+		MergeGapMinutes: 15,
+		MinimumMinutes:  10,
+		Rules:           []WindowRule{{App: "^editor$", Title: `(ABC-\d+)`, Task: "$1", Account: "ACC-1:Account One"}},
+	}
+	day := time.Date(2024, 3, 11, 0, 0, 0, 0, time.Local)
+	at := func(hour int, minute int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+	for _, segment := range []WindowSegment{
+		{Start: at(9, 0), End: at(9, 50), App: "editor", Title: "ABC-1 main.go"},
+		{Start: at(9, 50), End: at(9, 55), App: "editor", Title: "ABC-1 test.go"},
+		{Start: at(9, 55), End: at(10, 0), App: "browser", Title: "news"},
+		{Start: at(10, 0), End: at(10, 8), App: "editor", Title: "ABC-2 logout.go"},
+		{Start: at(10, 20), End: at(10, 40), App: "editor", Title: "ABC-1 main.go"},
+		{Start: at(11, 0), End: at(11, 30), App: "editor", Title: "ABC-1 dismissed.go"},
+		{Start: at(9, 0).AddDate(0, 0, 1), End: at(10, 0).AddDate(0, 0, 1), App: "editor", Title: "ABC-1 tomorrow"},
+	} {
+		writeWindowSegment(segment)
+	}
+	// 9:30 to 9:40 was tracked already
+	workLog := "127.0.0.1;ABC-1;2024-03-11;09:30:00;2024-03-11;09:40:00;10;\r"
+	if err := os.WriteFile(getDataFilePath("work.log"), []byte(workLog), 0644); err != nil {
+		t.Fatal(err)
+	}
+	previousDismissed := dismissedSuggestions
+	dismissedSuggestions = map[string]time.Time{windowSuggestion{Entry: WorkLogHistoryEntry{Task: "ABC-1"}, Start: at(11, 0)}.key(): at(11, 30)}
+	t.Cleanup(func() { dismissedSuggestions = previousDismissed })
+
+	type suggestion struct {
+		Task    string
+		Account string
+		Start   time.Time
+		End     time.Time
+		Window  string
+	}
+	want := []suggestion{
+		{Task: "ABC-1", Account: "ACC-1", Start: at(9, 0), End: at(9, 55), Window: "ABC-1 main.go"},
+		{Task: "ABC-1", Account: "ACC-1", Start: at(10, 20), End: at(10, 40), Window: "ABC-1 main.go"},
+	}
+	var got []suggestion
+	for _, windowSuggestion := range getWindowSuggestions(day) {
+		got = append(got, suggestion{Task: windowSuggestion.Entry.Task, Account: windowSuggestion.Entry.Account, Start: windowSuggestion.Start, End: windowSuggestion.End, Window: windowSuggestion.Window})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getWindowSuggestions() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+//go:build windows
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const processQueryLimitedInformation = 0x1000
+
+var (
+	getForegroundWindow       = user32.MustFindProc("GetForegroundWindow")
+	getWindowText             = user32.MustFindProc("GetWindowTextW")
+	getWindowThreadProcessID  = user32.MustFindProc("GetWindowThreadProcessId")
+	openProcess               = kernel32.MustFindProc("OpenProcess")
+	queryFullProcessImageName = kernel32.MustFindProc("QueryFullProcessImageNameW")
+	closeHandle               = kernel32.MustFindProc("CloseHandle")
+)
+
+// foregroundWindowSource reads the title of the foreground window and names
+// its application after the executable of its process.
+type foregroundWindowSource struct{}
+
+func newPlatformWindowSource() (windowSource, error) {
+	return foregroundWindowSource{}, nil
+}
+
+func (foregroundWindowSource) activeWindow() (string, string, error) {
+	hwnd, _, _ := getForegroundWindow.Call()
+	if hwnd == 0 {
+		return "", "", nil
+	}
+	title := make([]uint16, 512)
+	length, _, _ := getWindowText.Call(hwnd, uintptr(unsafe.Pointer(&title[0])), uintptr(len(title)))
+
+	var processID uint32
+	getWindowThreadProcessID.Call(hwnd, uintptr(unsafe.Pointer(&processID)))
+	process, _, err := openProcess.Call(processQueryLimitedInformation, 0, uintptr(processID))
+	if process == 0 {
+		return "", syscall.UTF16ToString(title[:length]), err
+	}
+	defer closeHandle.Call(process)
+	image := make([]uint16, 1024)
+	size := uint32(len(image))
+	r, _, err := queryFullProcessImageName.Call(process, 0, uintptr(unsafe.Pointer(&image[0])), uintptr(unsafe.Pointer(&size)))
+	if r == 0 {
+		return "", syscall.UTF16ToString(title[:length]), err
+	}
+	app := strings.TrimSuffix(filepath.Base(syscall.UTF16ToString(image[:size])), ".exe")
+	return app, syscall.UTF16ToString(title[:length]), nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+func getActivityAttribute() (WorkAttribute, bool) {
+	for _, attribute := range getWorkAttributes(getDefaultConnection()) {
+		if attribute.Key == trackerConfig.WorkAttributes.ActivityAttributeKey {
+			return attribute, true
+		}
+	}
+	return WorkAttribute{}, false
+}
+
+func getActivityName(value string) string {
+	attribute, _ := getActivityAttribute()
+	for _, staticValue := range attribute.StaticListValues {
+		if staticValue.Value == value {
+			return staticValue.Name
+		}
+	}
+	return value
+}
+
+func getActivityValue(name string) string {
+	attribute, _ := getActivityAttribute()
+	for _, staticValue := range attribute.StaticListValues {
+		if staticValue.Name == name {
+			return staticValue.Value
+		}
+	}
+	return name
+}
+
+// getDefaultActivityForTask returns the activity last used for task, falling
+// back to the configured default for the activity attribute.
+func getDefaultActivityForTask(task string) string {
+	var activity string
+	var lastUsage WorkLogHistoryEntry
+	for _, entry := range getWorkLogHistoryEntries() {
+		if entry.Task == task && entry.Activity != "" && entry.LastUsage.After(lastUsage.LastUsage) {
+			lastUsage = entry
+			activity = entry.Activity
+		}
+	}
+	if activity == "" {
+		activity = getTaskAttributes(task)[trackerConfig.WorkAttributes.ActivityAttributeKey]
+	}
+	if activity == "" {
+		activity = trackerConfig.WorkAttributes.Defaults[trackerConfig.WorkAttributes.ActivityAttributeKey]
+	}
+	return activity
+}
+
+// newActivitySelect offers the allowed values of the activity attribute and
+// reports the Tempo value, not the display name, of the selected one.
+func newActivitySelect(onChanged func(activity string)) *widget.Select {
+	attribute, _ := getActivityAttribute()
+	var options []string
+	for _, staticValue := range attribute.StaticListValues {
+		if !staticValue.Removed {
+			options = append(options, staticValue.Name)
+		}
+	}
+	activitySelect := widget.NewSelect(options, func(name string) {
+		onChanged(getActivityValue(name))
+	})
+	activitySelect.SetSelected(getActivityName(trackerConfig.WorkAttributes.Defaults[trackerConfig.WorkAttributes.ActivityAttributeKey]))
+	return activitySelect
+}
+
+const activityReportDays = 7
+
+// activityTotal is the time booked on one task with one activity on one day.
+// DraftMinutes is the part of it that is kept as drafts and was not submitted
+// to Tempo yet.
+type activityTotal struct {
+	Day          string
+	Task         string
+	Activity     string
+	Minutes      float64
+	DraftMinutes float64
+}
+
+// summarizeActivities adds up the minutes of the work.log lines per day, task
+// and activity, ordered by day and task, and marks the minutes of the drafts
+// that are still waiting for submission. Breaks are not booked, so they are
+// left out. A draft that was changed to another task or activity than its
+// work.log line has a total of its own.
+func summarizeActivities(lines []workLogLine, drafts []DraftWorkLog) []activityTotal {
+	var totals []activityTotal
+	index := make(map[activityTotal]int)
+	add := func(key activityTotal, minutes float64, draftMinutes float64) {
+		i, found := index[key]
+		if !found {
+			i = len(totals)
+			index[key] = i
+			totals = append(totals, key)
+		}
+		totals[i].Minutes += minutes
+		totals[i].DraftMinutes += draftMinutes
+	}
+	for _, line := range lines {
+		if line.Task == pomodoroBreakTask {
+			continue
+		}
+		add(activityTotal{Day: line.Start.Format("2006-01-02"), Task: line.Task, Activity: line.Activity}, line.Minutes, 0)
+	}
+	for _, draft := range drafts {
+		key := activityTotal{Day: draft.Start.Local().Format("2006-01-02"), Task: draft.Task, Activity: draft.Activity}
+		minutes := draft.duration().Minutes()
+		if _, found := index[key]; found {
+			add(key, 0, minutes)
+		} else {
+			add(key, minutes, minutes)
+		}
+	}
+	sort.SliceStable(totals, func(i, j int) bool {
+		if totals[i].Day != totals[j].Day {
+			return totals[i].Day < totals[j].Day
+		}
+		return totals[i].Task < totals[j].Task
+	})
+	return totals
+}
+
+// formatActivityMinutes shows the minutes of a total, noting how many of them
+// are not submitted yet.
+func formatActivityMinutes(minutes float64, draftMinutes float64) string {
+	if draftMinutes <= 0 {
+		return formatEstimateShort(int(minutes * 60))
+	}
+	return fmt.Sprintf("%s, %s not submitted", formatEstimateShort(int(minutes*60)), formatEstimateShort(int(draftMinutes*60)))
+}
+
+// showActivityReport shows the time of the last days from work.log per day,
+// task and activity, and the total per activity.
+func showActivityReport() {
+	reportWindow := fyne.CurrentApp().NewWindow("Activity Report")
+	today := time.Now()
+	from := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1-activityReportDays)
+	lines, err := readWorkLog(from)
+	if err != nil {
+		myErrorLogger.Printf("Got error when reading work.log %s", err.Error())
+		dialog.NewError(err, myWindow).Show()
+		return
+	}
+	var pendingDrafts []DraftWorkLog
+	for _, draft := range getDrafts() {
+		if !draft.end().Before(from) {
+			pendingDrafts = append(pendingDrafts, draft)
+		}
+	}
+
+	rows := container.NewVBox()
+	perActivity := make(map[string]float64)
+	perActivityDrafts := make(map[string]float64)
+	var activities []string
+	day := ""
+	for _, total := range summarizeActivities(lines, pendingDrafts) {
+		activity := getActivityName(total.Activity)
+		if activity == "" {
+			activity = "no activity"
+		}
+		if _, found := perActivity[activity]; !found {
+			activities = append(activities, activity)
+		}
+		perActivity[activity] += total.Minutes
+		perActivityDrafts[activity] += total.DraftMinutes
+		if total.Day != day {
+			day = total.Day
+			rows.Add(widget.NewLabelWithStyle(day, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		}
+		rows.Add(widget.NewLabel(fmt.Sprintf("%s · %s · %s", total.Task, activity, formatActivityMinutes(total.Minutes, total.DraftMinutes))))
+	}
+	sort.Strings(activities)
+	summary := container.NewVBox(widget.NewLabelWithStyle(fmt.Sprintf("Last %d days by activity", activityReportDays), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	for _, activity := range activities {
+		summary.Add(widget.NewLabel(fmt.Sprintf("%s · %s", activity, formatActivityMinutes(perActivity[activity], perActivityDrafts[activity]))))
+	}
+	summary.Add(widget.NewSeparator())
+
+	reportWindow.SetContent(container.NewBorder(summary, nil, nil, nil, container.NewVScroll(rows)))
+	reportWindow.Resize(fyne.NewSize(600, 600))
+	reportWindow.Show()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSummarizeActivities(t *testing.T) {
+	at := func(day int, hour int) time.Time {
+		return time.Date(2024, 3, day, hour, 0, 0, 0, time.Local)
+	}
+	lines := []workLogLine{
+		{Task: "ABC-2", Start: at(12, 9), Minutes: 30, Activity: "Development"},
+		{Task: "ABC-1", Start: at(11, 9), Minutes: 60, Activity: "Development"},
+		{Task: "ABC-1", Start: at(11, 11), Minutes: 15, Activity: "Development"},
+		{Task: "ABC-1", Start: at(11, 13), Minutes: 45, Activity: "Meeting"},
+		{Task: pomodoroBreakTask, Start: at(11, 12), Minutes: 5},
+	}
+	drafts := []DraftWorkLog{
+		{Task: "ABC-2", Start: at(12, 9), Seconds: 30 * 60, Activity: "Development"},
+		{Task: "ABC-3", Start: at(12, 10), Seconds: 20 * 60, Activity: "Review"},
+	}
+	tests := []struct {
+		name   string
+		drafts []DraftWorkLog
+		want   []activityTotal
+	}{
+		{
+			name: "submitted",
+			want: []activityTotal{
+				{Day: "2024-03-11", Task: "ABC-1", Activity: "Development", Minutes: 75},
+				{Day: "2024-03-11", Task: "ABC-1", Activity: "Meeting", Minutes: 45},
+				{Day: "2024-03-12", Task: "ABC-2", Activity: "Development", Minutes: 30},
+			},
+		},
+		{
+			name:   "drafts",
+			drafts: drafts,
+			want: []activityTotal{
+				{Day: "2024-03-11", Task: "ABC-1", Activity: "Development", Minutes: 75},
+				{Day: "2024-03-11", Task: "ABC-1", Activity: "Meeting", Minutes: 45},
+				{Day: "2024-03-12", Task: "ABC-2", Activity: "Development", Minutes: 30, DraftMinutes: 30},
+				{Day: "2024-03-12", Task: "ABC-3", Activity: "Review", Minutes: 20, DraftMinutes: 20},
+			},
+		},
+	}
+	for _, test := range tests {
+		if got := summarizeActivities(lines, test.drafts); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: summarizeActivities() = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Each client sends at most apiRequestsPerSecond requests per second on
+// average, with bursts of up to apiRequestBurst requests, to stay below the
+// rate limits of JIRA Cloud and Tempo.
+const (
+	apiDefaultTimeout    = 60 * time.Second
+	apiMaxRetryWait      = 2 * time.Minute
+	apiRequestsPerSecond = 5
+	apiRequestBurst      = 10
+)
+
+// apiClient talks to the REST APIs of JIRA and Tempo. It adds the base URL
+// and the authorization, retries requests that failed for a temporary reason
+// and turns error responses into APIErrors.
+type apiClient struct {
+	BaseURL string
+	// Username switches from bearer to basic authentication with Token as
+	// the password, as JIRA Cloud API tokens require.
+	Username string
+	Token    string
+	// Authenticator, when set, replaces Username and Token.
+	Authenticator apiAuthenticator
+	HTTPClient    *http.Client
+	MaxRetries    int
+	// Limiter, when set, is waited for before every attempt.
+	Limiter *apiRateLimiter
+	// Trace, when set, is called after every attempt of every request.
+	Trace func(trace APITrace)
+}
+
+// apiAuthenticator authorizes the requests of an apiClient with schemes
+// beyond static tokens. Authorize is called once per request and may run an
+// authorization flow, Authenticate once per attempt. BaseURL may send the
+// requests elsewhere than the client's BaseURL, and is empty otherwise.
+// When the credentials are rejected, Refresh is called to renew them once per
+// request, and Invalidate when they cannot be renewed or are rejected again.
+type apiAuthenticator interface {
+	Authorize() error
+	BaseURL() string
+	Authenticate(req *http.Request) error
+	Refresh() error
+	Invalidate()
+}
+
+type APITrace struct {
+	Method     string
+	URL        string
+	Attempt    int
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// apiRateLimiter is a token bucket that fills up at rate tokens per second
+// to at most burst tokens. Every request takes one token.
+type apiRateLimiter struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	// until holds off all requests after a server asked to slow down
+	until time.Time
+}
+
+func newAPIRateLimiter(rate float64, burst int) *apiRateLimiter {
+	return &apiRateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve takes a token and returns how long to wait until it may be used.
+func (limiter *apiRateLimiter) reserve(now time.Time) time.Duration {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	limiter.tokens += now.Sub(limiter.last).Seconds() * limiter.rate
+	if limiter.tokens > limiter.burst {
+		limiter.tokens = limiter.burst
+	}
+	limiter.last = now
+	limiter.tokens--
+
+	var wait time.Duration
+	if limiter.tokens < 0 {
+		wait = time.Duration(-limiter.tokens / limiter.rate * float64(time.Second))
+	}
+	if pause := limiter.until.Sub(now); pause > wait {
+		wait = pause
+	}
+	return wait
+}
+
+// Wait blocks until the next request may be sent or ctx is done.
+func (limiter *apiRateLimiter) Wait(ctx context.Context) error {
+	wait := limiter.reserve(time.Now())
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Pause holds off all requests for the given time.
+func (limiter *apiRateLimiter) Pause(wait time.Duration) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	if until := time.Now().Add(wait); until.After(limiter.until) {
+		limiter.until = until
+	}
+}
+
+// APIError is an error response of JIRA or Tempo, with the messages JIRA
+// puts in its errorMessages and errors fields.
+type APIError struct {
+	Method        string
+	URL           string
+	Host          string
+	StatusCode    int
+	Status        string
+	ErrorMessages []string
+	Errors        map[string]string
+	RetryAfter    time.Duration
+}
+
+type apiErrorBody struct {
+	ErrorMessages []string        `json:"errorMessages"`
+	Errors        json.RawMessage `json:"errors"`
+	Message       string          `json:"message"`
+}
+
+func newAPIClient(baseURL string, token string) *apiClient {
+	return &apiClient{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: apiDefaultTimeout},
+		MaxRetries: 3,
+		Limiter:    newAPIRateLimiter(apiRequestsPerSecond, apiRequestBurst),
+		Trace:      logAPITrace,
+	}
+}
+
+func (err *APIError) Error() string {
+	messages := append([]string{}, err.ErrorMessages...)
+	fields := make([]string, 0, len(err.Errors))
+	for field := range err.Errors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		messages = append(messages, fmt.Sprintf("%s: %s", field, err.Errors[field]))
+	}
+	if len(messages) == 0 {
+		return fmt.Sprintf("%s returned error code %s", err.Host, err.Status)
+	}
+	return fmt.Sprintf("%s returned error code %s: %s", err.Host, err.Status, strings.Join(messages, "; "))
+}
+
+// Temporary reports whether the request may succeed when sent again.
+func (err *APIError) Temporary() bool {
+	return err.StatusCode == http.StatusTooManyRequests || err.StatusCode >= 500
+}
+
+func isAPIStatus(err error, statusCode int) bool {
+	var apiError *APIError
+	return errors.As(err, &apiError) && apiError.StatusCode == statusCode
+}
+
+func logAPITrace(trace APITrace) {
+	attributes := []slog.Attr{
+		slog.String("method", trace.Method),
+		slog.String("url", trace.URL),
+		slog.Int("attempt", trace.Attempt),
+		slog.Int("status", trace.StatusCode),
+		slog.Duration("duration", trace.Duration),
+	}
+	if trace.Err != nil {
+		attributes = append(attributes, slog.String("error", trace.Err.Error()))
+	}
+	trackerLogger.LogAttrs(context.Background(), slog.LevelDebug, "API request", attributes...)
+}
+
+func newAPIError(req *http.Request, resp *http.Response) *APIError {
+	apiError := &APIError{
+		Method:     req.Method,
+		URL:        redactURL(req.URL),
+		Host:       req.URL.Host,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var body apiErrorBody
+	if json.Unmarshal(data, &body) != nil {
+		return apiError
+	}
+	apiError.ErrorMessages = body.ErrorMessages
+	if body.Message != "" {
+		apiError.ErrorMessages = append(apiError.ErrorMessages, body.Message)
+	}
+	// JIRA sends errors as an object of field errors, Tempo as a list
+	var fieldErrors map[string]string
+	var listErrors []struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body.Errors, &fieldErrors) == nil {
+		apiError.Errors = fieldErrors
+	} else if json.Unmarshal(body.Errors, &listErrors) == nil {
+		for _, listError := range listErrors {
+			apiError.ErrorMessages = append(apiError.ErrorMessages, listError.Message)
+		}
+	}
+	return apiError
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as a date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// getRetryWait returns how long to wait before the given retry, honoring
+// Retry-After and otherwise backing off exponentially with full jitter.
+func getRetryWait(attempt int, retryAfter time.Duration) time.Duration {
+	wait := retryAfter
+	if wait <= 0 {
+		backoff := 500 * time.Millisecond << attempt
+		if backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+		wait = time.Duration(rand.Int63n(int64(backoff)) + 1)
+	}
+	if wait > apiMaxRetryWait {
+		wait = apiMaxRetryWait
+	}
+	return wait
+}
+
+// isRetryable decides whether a failed request is sent again. A POST is only
+// repeated when the server surely did not process it, so that a worklog is
+// never booked twice.
+func isRetryable(method string, statusCode int) bool {
+	idempotent := method != "POST" && method != "PATCH"
+	switch {
+	case statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable:
+		return true
+	case statusCode == 0 || statusCode >= 500:
+		return idempotent
+	}
+	return false
+}
+
+// Do sends body as JSON to path and decodes the response into result, both
+// of which may be nil.
+func (client *apiClient) Do(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, apiDefaultTimeout*time.Duration(client.MaxRetries+1))
+		defer cancel()
+	}
+	baseURL := client.BaseURL
+	if client.Authenticator != nil {
+		err := client.Authenticator.Authorize()
+		if err != nil {
+			return err
+		}
+		if authenticatorBaseURL := client.Authenticator.BaseURL(); authenticatorBaseURL != "" {
+			baseURL = authenticatorBaseURL
+		}
+	}
+	url := baseURL + path
+
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		if client.Limiter != nil {
+			if err := client.Limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		if client.Authenticator != nil {
+			err = client.Authenticator.Authenticate(req)
+			if err != nil {
+				return err
+			}
+		} else if client.Username != "" {
+			req.SetBasicAuth(client.Username, client.Token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+client.Token)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		myLogger.Printf("Sending %s to %s", method, url)
+
+		started := time.Now()
+		resp, err := client.HTTPClient.Do(req)
+		trace := APITrace{Method: method, URL: redactURL(req.URL), Attempt: attempt, Duration: time.Since(started), Err: err}
+		if resp != nil {
+			trace.StatusCode = resp.StatusCode
+		}
+		if client.Trace != nil {
+			client.Trace(trace)
+		}
+
+		var retryAfter time.Duration
+		if err != nil {
+			if ctx.Err() != nil || !isRetryable(method, 0) || attempt >= client.MaxRetries {
+				return err
+			}
+			myWarningLogger.Printf("Could not send %s to %s, retrying: %s", method, url, err.Error())
+		} else {
+			myLogger.Printf("Got Response Code %s after %s", resp.Status, trace.Duration.String())
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				defer resp.Body.Close()
+				if result == nil || resp.StatusCode == http.StatusNoContent {
+					return nil
+				}
+				err = json.NewDecoder(resp.Body).Decode(result)
+				if err != nil {
+					myErrorLogger.Printf("Decode Failed %s", err.Error())
+				}
+				return err
+			}
+			apiError := newAPIError(req, resp)
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusUnauthorized && client.Authenticator != nil {
+				// the rejected request was not processed, so even a POST is
+				// sent again with renewed credentials
+				if !refreshed {
+					refreshed = true
+					err = client.Authenticator.Refresh()
+					if err == nil {
+						myWarningLogger.Printf("%s rejected the credentials, retrying with refreshed ones", url)
+						continue
+					}
+					myWarningLogger.Printf("Could not refresh the rejected credentials: %s", err.Error())
+				}
+				client.Authenticator.Invalidate()
+				return apiError
+			}
+			if !isRetryable(method, resp.StatusCode) || attempt >= client.MaxRetries {
+				return apiError
+			}
+			retryAfter = apiError.RetryAfter
+			if resp.StatusCode == http.StatusTooManyRequests && retryAfter > 0 && client.Limiter != nil {
+				client.Limiter.Pause(retryAfter)
+			}
+			myWarningLogger.Printf("Could not send %s to %s, retrying: %s", method, url, apiError.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(getRetryWait(attempt, retryAfter)):
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		method     string
+		statusCode int
+		want       bool
+	}{
+		{method: "GET", statusCode: 0, want: true},
+		{method: "GET", statusCode: http.StatusInternalServerError, want: true},
+		{method: "GET", statusCode: http.StatusBadGateway, want: true},
+		{method: "GET", statusCode: http.StatusTooManyRequests, want: true},
+		{method: "GET", statusCode: http.StatusBadRequest, want: false},
+		{method: "GET", statusCode: http.StatusUnauthorized, want: false},
+		{method: "PUT", statusCode: http.StatusInternalServerError, want: true},
+		{method: "DELETE", statusCode: 0, want: true},
+		{method: "POST", statusCode: 0, want: false},
+		{method: "POST", statusCode: http.StatusInternalServerError, want: false},
+		{method: "POST", statusCode: http.StatusTooManyRequests, want: true},
+		{method: "POST", statusCode: http.StatusServiceUnavailable, want: true},
+		{method: "PATCH", statusCode: http.StatusGatewayTimeout, want: false},
+		{method: "POST", statusCode: http.StatusNotFound, want: false},
+	}
+	for _, test := range tests {
+		if got := isRetryable(test.method, test.statusCode); got != test.want {
+			t.Errorf("isRetryable(%s, %d) = %t, want %t", test.method, test.statusCode, got, test.want)
+		}
+	}
+}
+
+func TestGetRetryWait(t *testing.T) {
+	tests := []struct {
+		attempt    int
+		retryAfter time.Duration
+		min        time.Duration
+		max        time.Duration
+	}{
+		{attempt: 0, retryAfter: 0, min: 1, max: 500 * time.Millisecond},
+		{attempt: 2, retryAfter: 0, min: 1, max: 2 * time.Second},
+		{attempt: 10, retryAfter: 0, min: 1, max: 30 * time.Second},
+		{attempt: 0, retryAfter: 7 * time.Second, min: 7 * time.Second, max: 7 * time.Second},
+		{attempt: 3, retryAfter: time.Hour, min: apiMaxRetryWait, max: apiMaxRetryWait},
+		{attempt: 1, retryAfter: -time.Second, min: 1, max: time.Second},
+	}
+	for _, test := range tests {
+		for i := 0; i < 100; i++ {
+			if got := getRetryWait(test.attempt, test.retryAfter); got < test.min || got > test.max {
+				t.Fatalf("getRetryWait(%d, %s) = %s, want between %s and %s", test.attempt, test.retryAfter, got, test.min, test.max)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{value: "", min: 0, max: 0},
+		{value: "0", min: 0, max: 0},
+		{value: "120", min: 120 * time.Second, max: 120 * time.Second},
+		{value: "soon", min: 0, max: 0},
+		{value: time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), min: 58 * time.Second, max: time.Minute},
+		{value: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), min: -time.Minute - time.Second, max: -58 * time.Second},
+	}
+	for _, test := range tests {
+		if got := parseRetryAfter(test.value); got < test.min || got > test.max {
+			t.Errorf("parseRetryAfter(%q) = %s, want between %s and %s", test.value, got, test.min, test.max)
+		}
+	}
+}
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		retryAfter    string
+		wantMessages  []string
+		wantErrors    map[string]string
+		wantRetry     time.Duration
+		wantErrorText string
+	}{
+		{
+			name:          "JIRA field errors",
+			body:          `{"errorMessages":["Issue does not exist"],"errors":{"timeSpent":"Invalid time duration","comment":"Too long"}}`,
+			wantMessages:  []string{"Issue does not exist"},
+			wantErrors:    map[string]string{"timeSpent": "Invalid time duration", "comment": "Too long"},
+			wantErrorText: "jira.example.com returned error code 400 Bad Request: Issue does not exist; comment: Too long; timeSpent: Invalid time duration",
+		},
+		{
+			name:          "Tempo error list",
+			body:          `{"errors":[{"message":"Account is closed"},{"message":"Worklog is in a locked period"}]}`,
+			wantMessages:  []string{"Account is closed", "Worklog is in a locked period"},
+			wantErrorText: "jira.example.com returned error code 400 Bad Request: Account is closed; Worklog is in a locked period",
+		},
+		{
+			name:          "message",
+			body:          `{"message":"Rate limit exceeded"}`,
+			retryAfter:    "30",
+			wantMessages:  []string{"Rate limit exceeded"},
+			wantRetry:     30 * time.Second,
+			wantErrorText: "jira.example.com returned error code 400 Bad Request: Rate limit exceeded",
+		},
+		{
+			name:          "no JSON",
+			body:          `<html>Bad Gateway</html>`,
+			wantErrorText: "jira.example.com returned error code 400 Bad Request",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "https://jira.example.com/rest/tempo-timesheets/4/worklogs?access_token=abc", nil)
+			resp := &http.Response{
+				StatusCode: http.StatusBadRequest,
+				Status:     "400 Bad Request",
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(test.body)),
+			}
+			if test.retryAfter != "" {
+				resp.Header.Set("Retry-After", test.retryAfter)
+			}
+			apiError := newAPIError(req, resp)
+			if !reflect.DeepEqual(apiError.ErrorMessages, test.wantMessages) {
+				t.Errorf("ErrorMessages = %q, want %q", apiError.ErrorMessages, test.wantMessages)
+			}
+			if !reflect.DeepEqual(apiError.Errors, test.wantErrors) {
+				t.Errorf("Errors = %q, want %q", apiError.Errors, test.wantErrors)
+			}
+			if apiError.RetryAfter != test.wantRetry {
+				t.Errorf("RetryAfter = %s, want %s", apiError.RetryAfter, test.wantRetry)
+			}
+			if apiError.Error() != test.wantErrorText {
+				t.Errorf("Error() = %q, want %q", apiError.Error(), test.wantErrorText)
+			}
+			if strings.Contains(apiError.URL, "abc") {
+				t.Errorf("URL = %q, want the token redacted", apiError.URL)
+			}
+		})
+	}
+}
+
+func TestAPIRateLimiter(t *testing.T) {
+	limiter := newAPIRateLimiter(2, 3)
+	now := limiter.last
+	for i := 0; i < 3; i++ {
+		if wait := limiter.reserve(now); wait != 0 {
+			t.Fatalf("request %d of the burst waits %s", i, wait)
+		}
+	}
+	if wait := limiter.reserve(now); wait != 500*time.Millisecond {
+		t.Errorf("request after the burst waits %s, want 500ms", wait)
+	}
+	if wait := limiter.reserve(now); wait != time.Second {
+		t.Errorf("second request after the burst waits %s, want 1s", wait)
+	}
+	if wait := limiter.reserve(now.Add(10 * time.Second)); wait != 0 {
+		t.Errorf("request after the bucket filled up waits %s", wait)
+	}
+
+	limiter.Pause(time.Minute)
+	if wait := limiter.reserve(time.Now()); wait < 59*time.Second {
+		t.Errorf("request during a pause waits %s, want about a minute", wait)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/gen2brain/beeep"
+)
+
+const (
+	approvalStatusWaiting  = "waiting_for_approval"
+	approvalStatusApproved = "approved"
+)
+
+var (
+	timesheetWindow fyne.Window
+	timesheetButton *widget.Button
+	// approvalRemindedDay is the day on which the approval reminder was last
+	// checked, so that it is checked once a day
+	approvalRemindedDay string
+)
+
+// TimesheetApproval is the approval of the timesheet of one period as Tempo
+// Server reports it.
+type TimesheetApproval struct {
+	Status           string          `json:"status"`
+	WorkedSeconds    int             `json:"workedSeconds"`
+	SubmittedSeconds int             `json:"submittedSeconds"`
+	RequiredSeconds  int             `json:"requiredSeconds"`
+	Period           TimesheetPeriod `json:"period"`
+	Reviewer         *JIRAUser       `json:"reviewer,omitempty"`
+}
+
+type TimesheetPeriod struct {
+	DateFrom string `json:"dateFrom"`
+	DateTo   string `json:"dateTo"`
+}
+
+type TimesheetApprovalUser struct {
+	Key string `json:"key"`
+}
+
+type TimesheetApprovalAction struct {
+	Name     string                 `json:"name"`
+	Comment  string                 `json:"comment,omitempty"`
+	Reviewer *TimesheetApprovalUser `json:"reviewer,omitempty"`
+}
+
+type TimesheetApprovalRequest struct {
+	User   TimesheetApprovalUser   `json:"user"`
+	Period TimesheetPeriod         `json:"period"`
+	Action TimesheetApprovalAction `json:"action"`
+}
+
+func (approval TimesheetApproval) hasStatus(status string) bool {
+	return strings.EqualFold(approval.Status, status)
+}
+
+func (approval TimesheetApproval) canBeSubmitted() bool {
+	return !approval.hasStatus(approvalStatusWaiting) && !approval.hasStatus(approvalStatusApproved)
+}
+
+func (approval TimesheetApproval) dates() (time.Time, time.Time, error) {
+	from, err := time.ParseInLocation("2006-01-02", approval.Period.DateFrom, time.Local)
+	if err != nil {
+		return from, from, err
+	}
+	to, err := time.ParseInLocation("2006-01-02", approval.Period.DateTo, time.Local)
+	return from, to, err
+}
+
+// getTarget returns the hours Tempo requires for the period, or the daily
+// target for every working day of it when Tempo has no workload scheme.
+func (approval TimesheetApproval) getTarget() time.Duration {
+	if approval.RequiredSeconds > 0 {
+		return time.Duration(approval.RequiredSeconds) * time.Second
+	}
+	from, to, err := approval.dates()
+	if err != nil {
+		return 0
+	}
+	var target time.Duration
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+			target += getDailyTarget()
+		}
+	}
+	return target
+}
+
+// getLastWorkingDay returns the last day of the period that is not on a
+// weekend.
+func (approval TimesheetApproval) getLastWorkingDay() (time.Time, error) {
+	from, day, err := approval.dates()
+	if err != nil {
+		return day, err
+	}
+	for day.After(from) && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day, nil
+}
+
+// getApprovalConnection returns the connection whose timesheet is approved,
+// the default connection, as long as it is a Tempo Server.
+func getApprovalConnection() (*jiraConnection, error) {
+	connection := getDefaultConnection()
+	if connection.isCloud() {
+		return connection, fmt.Errorf("timesheet approvals are only supported on Tempo Server, %s is a cloud connection", connection.Config.Name)
+	}
+	return connection, nil
+}
+
+// getTimesheetApproval reads the approval of the period that includes day,
+// or of the current period when day is zero.
+func getTimesheetApproval(day time.Time) (TimesheetApproval, error) {
+	var approval TimesheetApproval
+	connection, err := getApprovalConnection()
+	if err != nil {
+		return approval, err
+	}
+	path := "/rest/tempo-timesheets/4/timesheet-approval/current?userKey=" + url.QueryEscape(getWorker(connection))
+	if !day.IsZero() {
+		path += "&periodStartDate=" + day.Format("2006-01-02")
+	}
+	err = connection.Tempo.Do(context.Background(), "GET", path, nil, &approval)
+	return approval, err
+}
+
+// submitTimesheet submits the timesheet of a period to reviewer, a user key.
+func submitTimesheet(period TimesheetPeriod, reviewer string, comment string) error {
+	connection, err := getApprovalConnection()
+	if err != nil {
+		return err
+	}
+	request := TimesheetApprovalRequest{
+		User:   TimesheetApprovalUser{Key: getWorker(connection)},
+		Period: TimesheetPeriod{DateFrom: period.DateFrom},
+		Action: TimesheetApprovalAction{Name: "submit", Comment: comment, Reviewer: &TimesheetApprovalUser{Key: reviewer}},
+	}
+	myLogger.Printf("Submitting timesheet %s to %s for approval by %s", period.DateFrom, period.DateTo, reviewer)
+	return connection.Tempo.Do(context.Background(), "POST", "/rest/tempo-timesheets/4/timesheet-approval", &request, nil)
+}
+
+// searchReviewers returns the JIRA users matching query as "key:Display Name".
+func searchReviewers(query string) ([]string, error) {
+	connection, err := getApprovalConnection()
+	if err != nil {
+		return nil, err
+	}
+	var users []JIRAUser
+	err = connection.JIRA.Do(context.Background(), "GET", connection.apiPath("/user/search?username=%s&maxResults=20", url.QueryEscape(query)), nil, &users)
+	var options []string
+	for _, user := range users {
+		options = append(options, user.Key+":"+user.DisplayName)
+	}
+	return options, err
+}
+
+func formatApprovalStatus(status string) string {
+	return strings.ReplaceAll(strings.ToLower(status), "_", " ")
+}
+
+// tickApprovals is called by the idleness ticker every second. From
+// ReminderTime on the last working day of a period it reminds once to submit
+// the timesheet when its hours are below target.
+func tickApprovals(now time.Time) {
+	reminderTime := trackerConfig.Approvals.ReminderTime
+	if reminderTime == "" || now.Format("15:04") < reminderTime || getDefaultConnection().isCloud() {
+		return
+	}
+	today := now.Format("2006-01-02")
+	if approvalRemindedDay == today {
+		return
+	}
+	approvalRemindedDay = today
+
+	go func() {
+		approval, err := getTimesheetApproval(time.Time{})
+		if err != nil {
+			myWarningLogger.Printf("Could not read the timesheet approval: %s", err.Error())
+			return
+		}
+		lastWorkingDay, err := approval.getLastWorkingDay()
+		if err != nil || lastWorkingDay.Format("2006-01-02") != today || !approval.canBeSubmitted() {
+			return
+		}
+		worked := time.Duration(approval.WorkedSeconds) * time.Second
+		if worked >= approval.getTarget() {
+			return
+		}
+		message := fmt.Sprintf("%s of %s are booked for %s to %s", formatEstimateShort(approval.WorkedSeconds), formatEstimateShort(int(approval.getTarget().Seconds())), approval.Period.DateFrom, approval.Period.DateTo)
+		if pending := len(getDrafts()); pending > 0 {
+			message += fmt.Sprintf(", %d drafts are not submitted yet", pending)
+		}
+		myLogger.Printf("Reminding of the timesheet below target: %s", message)
+		if err := beeep.Notify("Timesheet below target", message, ""); err != nil {
+			myWarningLogger.Printf("Could not show notification: %s", err.Error())
+		}
+		showTimesheetWindow()
+	}()
+}
+
+// showTimesheetWindow shows the approval status of the timesheet period by
+// period, starting with the current one, and submits a period for approval.
+func showTimesheetWindow() {
+	if timesheetWindow != nil {
+		timesheetWindow.RequestFocus()
+		return
+	}
+	timesheetWindow = fyne.CurrentApp().NewWindow("Timesheet Approval")
+	window := timesheetWindow
+	window.SetOnClosed(func() {
+		timesheetWindow = nil
+	})
+
+	var approval TimesheetApproval
+	periodLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	statusLabel := widget.NewLabel("")
+	hoursLabel := widget.NewLabel("")
+	reviewerLabel := widget.NewLabel("")
+	var earlierButton, laterButton, submitButton *widget.Button
+
+	load := func(day time.Time) {
+		go func() {
+			loaded, err := getTimesheetApproval(day)
+			if err != nil {
+				myErrorLogger.Printf("Got error when reading the timesheet approval %s", err.Error())
+				dialog.NewError(err, window).Show()
+				return
+			}
+			approval = loaded
+			periodLabel.SetText(fmt.Sprintf("%s to %s", approval.Period.DateFrom, approval.Period.DateTo))
+			statusLabel.SetText("Status: " + formatApprovalStatus(approval.Status))
+			hours := fmt.Sprintf("Booked %s of %s, %s submitted", formatEstimateShort(approval.WorkedSeconds), formatEstimateShort(int(approval.getTarget().Seconds())), formatEstimateShort(approval.SubmittedSeconds))
+			if time.Duration(approval.WorkedSeconds)*time.Second < approval.getTarget() {
+				hours += ", below target"
+			}
+			hoursLabel.SetText(hours)
+			if approval.Reviewer != nil {
+				reviewerLabel.SetText("Reviewer: " + approval.Reviewer.DisplayName)
+			} else {
+				reviewerLabel.SetText("")
+			}
+			earlierButton.Enable()
+			laterButton.Enable()
+			if approval.canBeSubmitted() {
+				submitButton.Enable()
+			} else {
+				submitButton.Disable()
+			}
+		}()
+	}
+
+	earlierButton = widget.NewButton("Earlier", func() {
+		if from, _, err := approval.dates(); err == nil {
+			load(from.AddDate(0, 0, -1))
+		}
+	})
+	laterButton = widget.NewButton("Later", func() {
+		if _, to, err := approval.dates(); err == nil {
+			load(to.AddDate(0, 0, 1))
+		}
+	})
+	submitButton = widget.NewButton("Submit week for approval", func() {
+		showSubmitTimesheetDialog(approval, window, func() {
+			from, _, _ := approval.dates()
+			load(from)
+		})
+	})
+	earlierButton.Disable()
+	laterButton.Disable()
+	submitButton.Disable()
+
+	load(time.Time{})
+	buttons := container.NewHBox(earlierButton, laterButton, widget.NewButton("Current", func() { load(time.Time{}) }), submitButton)
+	window.SetContent(container.NewVBox(periodLabel, statusLabel, hoursLabel, reviewerLabel, buttons))
+	window.Resize(fyne.NewSize(500, 220))
+	window.CenterOnScreen()
+	window.Show()
+}
+
+// showSubmitTimesheetDialog picks the reviewer, searched in JIRA on Enter,
+// and submits the period. The reviewer is remembered for the next period.
+func showSubmitTimesheetDialog(approval TimesheetApproval, parent fyne.Window, onSubmitted func()) {
+	reviewerEntry := widget.NewSelectEntry(nil)
+	reviewerEntry.SetPlaceHolder("Name of the reviewer, Enter to search")
+	if approval.Reviewer != nil && approval.Reviewer.Key != "" {
+		reviewerEntry.SetText(approval.Reviewer.Key + ":" + approval.Reviewer.DisplayName)
+	} else {
+		reviewerEntry.SetText(trackerConfig.Approvals.Reviewer)
+	}
+	reviewerEntry.OnSubmitted = func(query string) {
+		go func() {
+			options, err := searchReviewers(strings.TrimSpace(query))
+			if err != nil {
+				myErrorLogger.Printf("Got error when searching reviewers %s", err.Error())
+				dialog.NewError(err, parent).Show()
+				return
+			}
+			reviewerEntry.SetOptions(options)
+			reviewerEntry.Refresh()
+		}()
+	}
+	reviewerEntry.Validator = func(text string) error {
+		if !strings.Contains(text, ":") {
+			return errors.New("no reviewer selected")
+		}
+		return nil
+	}
+	commentEntry := widget.NewEntry()
+
+	message := ""
+	if time.Duration(approval.WorkedSeconds)*time.Second < approval.getTarget() {
+		message = fmt.Sprintf("only %s of %s booked", formatEstimateShort(approval.WorkedSeconds), formatEstimateShort(int(approval.getTarget().Seconds())))
+	}
+	if pending := len(getDrafts()); pending > 0 {
+		message = strings.TrimPrefix(message+fmt.Sprintf(", %d drafts not submitted", pending), ", ")
+	}
+	formItems := []*widget.FormItem{
+		widget.NewFormItem("Reviewer", reviewerEntry),
+		widget.NewFormItem("Comment", commentEntry),
+	}
+	if message != "" {
+		formItems = append(formItems, widget.NewFormItem("Note", widget.NewLabel(message)))
+	}
+	submitDialog := dialog.NewForm(fmt.Sprintf("Submitting %s to %s", approval.Period.DateFrom, approval.Period.DateTo), "Submit", "Cancel", formItems, func(submit bool) {
+		if !submit {
+			return
+		}
+		reviewer := reviewerEntry.Text
+		if reviewer != trackerConfig.Approvals.Reviewer {
+			trackerConfig.Approvals.Reviewer = reviewer
+			saveTrackerConfig()
+		}
+		go func() {
+			if err := submitTimesheet(approval.Period, getElementFromStringWithColon(reviewer, 0), commentEntry.Text); err != nil {
+				myErrorLogger.Printf("Got error when submitting the timesheet %s", err.Error())
+				dialog.NewError(err, parent).Show()
+				return
+			}
+			onSubmitted()
+		}()
+	}, parent)
+	submitDialog.Resize(fyne.NewSize(500, 200))
+	submitDialog.Show()
+}
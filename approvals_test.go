@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimesheetApprovalTarget(t *testing.T) {
+	useUTCAsLocalTime(t)
+	previousConfig := trackerConfig
+	t.Cleanup(func() { trackerConfig = previousConfig })
+	trackerConfig.Submission.DailyTargetHours = 8
+
+	tests := []struct {
+		name     string
+		approval TimesheetApproval
+		want     time.Duration
+	}{
+		{name: "required by Tempo", approval: TimesheetApproval{RequiredSeconds: 3600 * 30, Period: TimesheetPeriod{DateFrom: "2024-03-04", DateTo: "2024-03-10"}}, want: 30 * time.Hour},
+		{name: "week", approval: TimesheetApproval{Period: TimesheetPeriod{DateFrom: "2024-03-04", DateTo: "2024-03-10"}}, want: 40 * time.Hour},
+		{name: "month", approval: TimesheetApproval{Period: TimesheetPeriod{DateFrom: "2024-03-01", DateTo: "2024-03-31"}}, want: 21 * 8 * time.Hour},
+		{name: "single day", approval: TimesheetApproval{Period: TimesheetPeriod{DateFrom: "2024-03-05", DateTo: "2024-03-05"}}, want: 8 * time.Hour},
+		{name: "weekend", approval: TimesheetApproval{Period: TimesheetPeriod{DateFrom: "2024-03-02", DateTo: "2024-03-03"}}, want: 0},
+		{name: "invalid period", approval: TimesheetApproval{Period: TimesheetPeriod{DateFrom: "March", DateTo: "2024-03-31"}}, want: 0},
+	}
+	for _, test := range tests {
+		if got := test.approval.getTarget(); got != test.want {
+			t.Errorf("%s: getTarget() = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestTimesheetApprovalLastWorkingDay(t *testing.T) {
+	useUTCAsLocalTime(t)
+	tests := []struct {
+		name    string
+		period  TimesheetPeriod
+		want    string
+		wantErr bool
+	}{
+		{name: "ends on a Friday", period: TimesheetPeriod{DateFrom: "2024-02-26", DateTo: "2024-03-01"}, want: "2024-03-01"},
+		{name: "ends on a Sunday", period: TimesheetPeriod{DateFrom: "2024-03-04", DateTo: "2024-03-10"}, want: "2024-03-08"},
+		{name: "month ending on a Sunday", period: TimesheetPeriod{DateFrom: "2024-03-01", DateTo: "2024-03-31"}, want: "2024-03-29"},
+		{name: "weekend only", period: TimesheetPeriod{DateFrom: "2024-03-02", DateTo: "2024-03-03"}, want: "2024-03-02"},
+		{name: "invalid period", period: TimesheetPeriod{DateFrom: "2024-03-01", DateTo: "end of March"}, wantErr: true},
+	}
+	for _, test := range tests {
+		day, err := TimesheetApproval{Period: test.period}.getLastWorkingDay()
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: getLastWorkingDay() returned no error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: getLastWorkingDay() error %v", test.name, err)
+			continue
+		}
+		if got := day.Format("2006-01-02"); got != test.want {
+			t.Errorf("%s: getLastWorkingDay() = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
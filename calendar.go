@@ -0,0 +1,501 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+const (
+	calendarSourceICS    = "ics"
+	calendarSourceCalDAV = "caldav"
+
+	// maxRecurrences stops the expansion of rules that never reach the
+	// window, e.g. a daily meeting since 1990 without an end
+	maxRecurrences = 10000
+)
+
+var (
+	calendarClient = &http.Client{
+		Timeout: time.Second * 60,
+	}
+	icsTextUnescaper = strings.NewReplacer("\\\\", "\\", "\\;", ";", "\\,", ",", "\\n", " ", "\\N", " ")
+	icsWeekdays      = map[string]int{"MO": 0, "TU": 1, "WE": 2, "TH": 3, "FR": 4, "SA": 5, "SU": 6}
+)
+
+// Meeting is a single occurrence of a calendar event; the occurrences of a
+// recurring event share the UID.
+type Meeting struct {
+	UID     string
+	Summary string
+	Start   time.Time
+	End     time.Time
+	Source  string
+}
+
+func (meeting Meeting) key() string {
+	return fmt.Sprintf("%s@%d", meeting.UID, meeting.Start.Unix())
+}
+
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+type icsEvent struct {
+	UID          string
+	Summary      string
+	Status       string
+	Transparent  bool
+	AllDay       bool
+	Start        time.Time
+	End          time.Time
+	Duration     time.Duration
+	RRule        string
+	ExDates      []time.Time
+	RecurrenceID time.Time
+}
+
+type calDAVMultistatus struct {
+	Responses []struct {
+		Href      string `xml:"href"`
+		Propstats []struct {
+			CalendarData string `xml:"prop>calendar-data"`
+			Status       string `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// fetchMeetings returns the meetings of source that overlap from and to.
+func fetchMeetings(ctx context.Context, source CalendarSource, from time.Time, to time.Time) ([]Meeting, error) {
+	var calendars [][]byte
+	var err error
+	if source.Type == calendarSourceCalDAV {
+		calendars, err = queryCalDAV(ctx, source, from, to)
+	} else {
+		var calendar []byte
+		calendar, err = readICS(ctx, source)
+		calendars = append(calendars, calendar)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var events []icsEvent
+	for _, calendar := range calendars {
+		calendarEvents, err := parseICS(calendar)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, calendarEvents...)
+	}
+	meetings := expandEvents(events, from, to)
+	for i := range meetings {
+		meetings[i].Source = source.Name
+	}
+	return meetings, nil
+}
+
+func newCalendarRequest(ctx context.Context, source CalendarSource, method string, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if source.Username != "" {
+		req.SetBasicAuth(source.Username, source.Password)
+	}
+	return req, nil
+}
+
+// readICS reads an iCalendar file, either from a URL or from a path, which
+// is relative to the data directory unless absolute.
+func readICS(ctx context.Context, source CalendarSource) ([]byte, error) {
+	location := source.URL
+	if strings.HasPrefix(location, "webcal://") {
+		location = "https://" + strings.TrimPrefix(location, "webcal://")
+	}
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		location = strings.TrimPrefix(location, "file://")
+		if !filepath.IsAbs(location) {
+			location = getDataFilePath(location)
+		}
+		return os.ReadFile(location)
+	}
+
+	req, err := newCalendarRequest(ctx, source, "GET", location, nil)
+	if err != nil {
+		return nil, err
+	}
+	myLogger.Printf("Requesting calendar %s", source.Name)
+	resp, err := calendarClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("calendar %s answered %s", source.Name, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// queryCalDAV asks the calendar collection at source.URL for the events
+// between from and to, expanded into their occurrences by the server.
+func queryCalDAV(ctx context.Context, source CalendarSource, from time.Time, to time.Time) ([][]byte, error) {
+	start := from.UTC().Format("20060102T150405Z")
+	end := to.UTC().Format("20060102T150405Z")
+	query := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop>
+    <C:calendar-data>
+      <C:expand start="%[1]s" end="%[2]s"/>
+    </C:calendar-data>
+  </D:prop>
+  <C:filter>
+    <C:comp-filter name="VCALENDAR">
+      <C:comp-filter name="VEVENT">
+        <C:time-range start="%[1]s" end="%[2]s"/>
+      </C:comp-filter>
+    </C:comp-filter>
+  </C:filter>
+</C:calendar-query>`, start, end)
+
+	req, err := newCalendarRequest(ctx, source, "REPORT", source.URL, strings.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Depth", "1")
+	myLogger.Printf("Querying CalDAV calendar %s", source.Name)
+	resp, err := calendarClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("CalDAV calendar %s answered %s", source.Name, resp.Status)
+	}
+
+	var multistatus calDAVMultistatus
+	err = xml.NewDecoder(resp.Body).Decode(&multistatus)
+	if err != nil {
+		return nil, err
+	}
+	var calendars [][]byte
+	for _, response := range multistatus.Responses {
+		for _, propstat := range response.Propstats {
+			if strings.TrimSpace(propstat.CalendarData) != "" {
+				calendars = append(calendars, []byte(propstat.CalendarData))
+			}
+		}
+	}
+	return calendars, nil
+}
+
+// parseICS returns the VEVENTs of an iCalendar file. Lines folded by RFC 5545
+// are joined before the properties are read.
+func parseICS(data []byte) ([]icsEvent, error) {
+	text := strings.ReplaceAll(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\n ", "")
+	text = strings.ReplaceAll(text, "\n\t", "")
+	if !strings.HasPrefix(strings.TrimSpace(text), "BEGIN:VCALENDAR") {
+		return nil, errors.New("not an iCalendar file")
+	}
+
+	var events []icsEvent
+	var event *icsEvent
+	// nested components such as VALARM carry properties of their own
+	depth := 0
+	for _, line := range strings.Split(text, "\n") {
+		property, ok := parseICSProperty(strings.TrimRight(line, "\r"))
+		if !ok {
+			continue
+		}
+		switch {
+		case property.Name == "BEGIN" && property.Value == "VEVENT":
+			event = &icsEvent{}
+			depth = 0
+			continue
+		case property.Name == "BEGIN" && event != nil:
+			depth++
+			continue
+		case property.Name == "END" && event != nil && depth > 0:
+			depth--
+			continue
+		case property.Name == "END" && property.Value == "VEVENT" && event != nil:
+			events = append(events, *event)
+			event = nil
+			continue
+		}
+		if event == nil || depth > 0 {
+			continue
+		}
+
+		var err error
+		switch property.Name {
+		case "UID":
+			event.UID = property.Value
+		case "SUMMARY":
+			event.Summary = icsTextUnescaper.Replace(property.Value)
+		case "STATUS":
+			event.Status = strings.ToUpper(property.Value)
+		case "TRANSP":
+			event.Transparent = strings.EqualFold(property.Value, "TRANSPARENT")
+		case "DTSTART":
+			event.Start, event.AllDay, err = parseICSTime(property)
+		case "DTEND":
+			event.End, _, err = parseICSTime(property)
+		case "DURATION":
+			event.Duration, err = parseICSDuration(property.Value)
+		case "RRULE":
+			event.RRule = property.Value
+		case "RECURRENCE-ID":
+			event.RecurrenceID, _, err = parseICSTime(property)
+		case "EXDATE":
+			for _, value := range strings.Split(property.Value, ",") {
+				exDate, _, exDateErr := parseICSTime(icsProperty{Name: property.Name, Params: property.Params, Value: value})
+				if exDateErr != nil {
+					err = exDateErr
+					break
+				}
+				event.ExDates = append(event.ExDates, exDate)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("event %s has an invalid %s: %w", event.UID, property.Name, err)
+		}
+	}
+	return events, nil
+}
+
+// parseICSProperty splits a content line into its name, its parameters and
+// its value; colons in quoted parameter values do not end the parameters.
+func parseICSProperty(line string) (icsProperty, bool) {
+	quoted := false
+	for i, char := range line {
+		switch {
+		case char == '"':
+			quoted = !quoted
+		case char == ':' && !quoted:
+			parts := strings.Split(line[:i], ";")
+			property := icsProperty{
+				Name:   strings.ToUpper(parts[0]),
+				Params: make(map[string]string),
+				Value:  line[i+1:],
+			}
+			for _, param := range parts[1:] {
+				name, value, _ := strings.Cut(param, "=")
+				property.Params[strings.ToUpper(name)] = strings.Trim(value, `"`)
+			}
+			return property, true
+		}
+	}
+	return icsProperty{}, false
+}
+
+// parseICSTime reads a date or date-time in UTC, in the time zone of its
+// TZID or floating. Time zones Go does not know, such as the Windows names
+// Outlook writes, are taken as the local time zone. The time stays in its
+// time zone, so that recurrences keep their time of day there across
+// daylight saving time changes.
+func parseICSTime(property icsProperty) (time.Time, bool, error) {
+	value := strings.TrimSpace(property.Value)
+	if property.Params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		date, err := time.ParseInLocation("20060102", value, time.Local)
+		return date, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		instant, err := time.Parse("20060102T150405Z", value)
+		return instant, false, err
+	}
+	location := time.Local
+	if tzid := property.Params["TZID"]; tzid != "" {
+		if tzLocation, err := time.LoadLocation(tzid); err == nil {
+			location = tzLocation
+		} else {
+			trackerLogger.Debug("Unknown time zone, using local time", "tzid", tzid)
+		}
+	}
+	instant, err := time.ParseInLocation("20060102T150405", value, location)
+	return instant, false, err
+}
+
+// parseICSDuration reads durations such as PT1H30M, P1D or P2W.
+func parseICSDuration(value string) (time.Duration, error) {
+	text := strings.TrimPrefix(strings.TrimPrefix(value, "+"), "-")
+	if !strings.HasPrefix(text, "P") {
+		return 0, fmt.Errorf("invalid duration %s", value)
+	}
+	var duration time.Duration
+	inTime := false
+	number := ""
+	for _, char := range text[1:] {
+		if char >= '0' && char <= '9' {
+			number += string(char)
+			continue
+		}
+		if char == 'T' {
+			inTime = true
+			continue
+		}
+		amount, err := strconv.Atoi(number)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %s", value)
+		}
+		number = ""
+		switch {
+		case char == 'W':
+			duration += time.Duration(amount) * 7 * 24 * time.Hour
+		case char == 'D':
+			duration += time.Duration(amount) * 24 * time.Hour
+		case char == 'H' && inTime:
+			duration += time.Duration(amount) * time.Hour
+		case char == 'M' && inTime:
+			duration += time.Duration(amount) * time.Minute
+		case char == 'S' && inTime:
+			duration += time.Duration(amount) * time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration %s", value)
+		}
+	}
+	if strings.HasPrefix(value, "-") {
+		duration = -duration
+	}
+	return duration, nil
+}
+
+// expandEvents turns events into the meetings between from and to. All-day,
+// cancelled and free events are left out; a modified occurrence of a
+// recurring event, carrying a RECURRENCE-ID, replaces the regular one.
+func expandEvents(events []icsEvent, from time.Time, to time.Time) []Meeting {
+	overridden := make(map[string]bool)
+	for _, event := range events {
+		if !event.RecurrenceID.IsZero() {
+			overridden[fmt.Sprintf("%s@%d", event.UID, event.RecurrenceID.Unix())] = true
+		}
+	}
+
+	var meetings []Meeting
+	for _, event := range events {
+		if event.AllDay || event.Transparent || event.Status == "CANCELLED" || event.Start.IsZero() {
+			continue
+		}
+		length := event.Duration
+		if !event.End.IsZero() {
+			length = event.End.Sub(event.Start)
+		}
+		if length <= 0 {
+			continue
+		}
+
+		starts := []time.Time{event.Start}
+		if event.RRule != "" && event.RecurrenceID.IsZero() {
+			starts = expandRecurrence(event, length, from, to)
+		}
+		for _, start := range starts {
+			meeting := Meeting{UID: event.UID, Summary: event.Summary, Start: start.Local(), End: start.Add(length).Local()}
+			if event.RecurrenceID.IsZero() && overridden[meeting.key()] {
+				continue
+			}
+			if meeting.Start.Before(to) && meeting.End.After(from) {
+				meetings = append(meetings, meeting)
+			}
+		}
+	}
+	sort.Slice(meetings, func(i, j int) bool {
+		return meetings[i].Start.Before(meetings[j].Start)
+	})
+	return meetings
+}
+
+// expandRecurrence returns the starts of the occurrences of a recurring
+// event that overlap from and to. It supports FREQ of DAILY, WEEKLY,
+// MONTHLY and YEARLY with INTERVAL, COUNT, UNTIL and, for weekly rules,
+// BYDAY, which covers the rules calendars write for meetings; other parts
+// are ignored.
+func expandRecurrence(event icsEvent, length time.Duration, from time.Time, to time.Time) []time.Time {
+	rule := make(map[string]string)
+	for _, part := range strings.Split(event.RRule, ";") {
+		name, value, _ := strings.Cut(part, "=")
+		rule[strings.ToUpper(name)] = strings.ToUpper(value)
+	}
+	interval, err := strconv.Atoi(rule["INTERVAL"])
+	if err != nil || interval < 1 {
+		interval = 1
+	}
+	count, _ := strconv.Atoi(rule["COUNT"])
+	var until time.Time
+	if rule["UNTIL"] != "" {
+		until, _, err = parseICSTime(icsProperty{Value: rule["UNTIL"]})
+		if err != nil {
+			myWarningLogger.Printf("Ignoring invalid UNTIL of event %s: %s", event.UID, err.Error())
+		}
+	}
+	var weekdays []int
+	for _, day := range strings.Split(rule["BYDAY"], ",") {
+		if weekday, found := icsWeekdays[day]; found {
+			weekdays = append(weekdays, weekday)
+		}
+	}
+	sort.Ints(weekdays)
+	excluded := make(map[int64]bool)
+	for _, exDate := range event.ExDates {
+		excluded[exDate.Unix()] = true
+	}
+
+	start := event.Start
+	// Monday of the week of the first occurrence, at its time of day
+	weekStart := start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+	var starts []time.Time
+	occurrences := 0
+	for period := 0; period < maxRecurrences; period++ {
+		var candidates []time.Time
+		switch rule["FREQ"] {
+		case "DAILY":
+			candidates = append(candidates, start.AddDate(0, 0, period*interval))
+		case "WEEKLY":
+			if len(weekdays) == 0 {
+				candidates = append(candidates, start.AddDate(0, 0, 7*period*interval))
+			}
+			for _, weekday := range weekdays {
+				candidates = append(candidates, weekStart.AddDate(0, 0, 7*period*interval+weekday))
+			}
+		case "MONTHLY":
+			candidates = append(candidates, start.AddDate(0, period*interval, 0))
+		case "YEARLY":
+			candidates = append(candidates, start.AddDate(period*interval, 0, 0))
+		default:
+			myWarningLogger.Printf("Ignoring unsupported recurrence %s of event %s", event.RRule, event.UID)
+			return []time.Time{start}
+		}
+
+		for _, candidate := range candidates {
+			// the 31st does not occur in every month, nor the 29th of February
+			if candidate.Before(start) || (rule["FREQ"] != "WEEKLY" && rule["FREQ"] != "DAILY" && candidate.Day() != start.Day()) {
+				continue
+			}
+			if (!until.IsZero() && candidate.After(until)) || !candidate.Before(to) {
+				return starts
+			}
+			occurrences++
+			if count > 0 && occurrences > count {
+				return starts
+			}
+			if candidate.Add(length).After(from) && !excluded[candidate.Unix()] {
+				starts = append(starts, candidate)
+			}
+		}
+	}
+	return starts
+}
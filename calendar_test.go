@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newICS builds a calendar with the given lines between the VCALENDAR
+// lines, with the CRLF line endings of RFC 5545.
+func newICS(lines ...string) string {
+	return strings.Join(append(append([]string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//Test//EN"}, lines...), "END:VCALENDAR", ""), "\r\n")
+}
+
+// useUTCAsLocalTime makes floating and all-day times independent of the
+// time zone the tests run in.
+func useUTCAsLocalTime(t *testing.T) {
+	local := time.Local
+	time.Local = time.UTC
+	t.Cleanup(func() { time.Local = local })
+}
+
+func formatMeetingStarts(meetings []Meeting) []string {
+	var starts []string
+	for _, meeting := range meetings {
+		starts = append(starts, meeting.Start.UTC().Format("2006-01-02 15:04"))
+	}
+	return starts
+}
+
+func TestParseICS(t *testing.T) {
+	useUTCAsLocalTime(t)
+	data := "\xef\xbb\xbf" + newICS(
+		"BEGIN:VEVENT",
+		"UID:planning@example.com",
+		"SUMMARY:Sprint planning\\, team A: backlog refinement and estimation of",
+		"  the next sprint",
+		"DTSTART;TZID=\"Europe/Berlin\":20240305T100000",
+		"DTEND;TZID=Europe/Berlin:20240305T113000",
+		"RRULE:FREQ=WEEKLY;BYDAY=TU",
+		"EXDATE;TZID=Europe/Berlin:20240312T100000,20240319T100000",
+		"BEGIN:VALARM",
+		"TRIGGER:-PT15M",
+		"SUMMARY:Reminder",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:offsite@example.com",
+		"SUMMARY:Offsite",
+		"DTSTART;VALUE=DATE:20240307",
+		"DURATION:P1D",
+		"TRANSP:TRANSPARENT",
+		"STATUS:cancelled",
+		"END:VEVENT",
+	)
+
+	events, err := parseICS([]byte(data))
+	if err != nil {
+		t.Fatalf("parseICS() error %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("parseICS() returned %d events, want 2", len(events))
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	planning := events[0]
+	if planning.Summary != "Sprint planning, team A: backlog refinement and estimation of the next sprint" {
+		t.Errorf("Summary = %q", planning.Summary)
+	}
+	if !planning.Start.Equal(time.Date(2024, 3, 5, 10, 0, 0, 0, berlin)) || !planning.End.Equal(time.Date(2024, 3, 5, 11, 30, 0, 0, berlin)) {
+		t.Errorf("Start, End = %s, %s", planning.Start, planning.End)
+	}
+	if planning.RRule != "FREQ=WEEKLY;BYDAY=TU" || len(planning.ExDates) != 2 || !planning.ExDates[1].Equal(time.Date(2024, 3, 19, 10, 0, 0, 0, berlin)) {
+		t.Errorf("RRule, ExDates = %s, %v", planning.RRule, planning.ExDates)
+	}
+	offsite := events[1]
+	if !offsite.AllDay || !offsite.Transparent || offsite.Status != "CANCELLED" || offsite.Duration != 24*time.Hour {
+		t.Errorf("offsite = %+v", offsite)
+	}
+
+	if _, err := parseICS([]byte("<html></html>")); err == nil {
+		t.Error("parseICS() accepted HTML")
+	}
+	if _, err := parseICS([]byte(newICS("BEGIN:VEVENT", "UID:broken", "DTSTART:2024-03-05", "END:VEVENT"))); err == nil {
+		t.Error("parseICS() accepted an invalid DTSTART")
+	}
+}
+
+func TestExpandEvents(t *testing.T) {
+	useUTCAsLocalTime(t)
+	tests := []struct {
+		name  string
+		lines []string
+		from  time.Time
+		to    time.Time
+		want  []string
+	}{
+		{
+			name:  "weekly on days with count",
+			lines: []string{"DTSTART:20240304T090000Z", "DTEND:20240304T093000Z", "RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=5"},
+			from:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			want:  []string{"2024-03-04 09:00", "2024-03-06 09:00", "2024-03-08 09:00", "2024-03-11 09:00", "2024-03-13 09:00"},
+		},
+		{
+			name:  "daily until",
+			lines: []string{"DTSTART:20240304T090000Z", "DURATION:PT15M", "RRULE:FREQ=DAILY;UNTIL=20240307T090000Z"},
+			from:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			want:  []string{"2024-03-04 09:00", "2024-03-05 09:00", "2024-03-06 09:00", "2024-03-07 09:00"},
+		},
+		{
+			name:  "excluded dates count towards the count",
+			lines: []string{"DTSTART:20240304T090000Z", "DURATION:PT15M", "RRULE:FREQ=DAILY;COUNT=4", "EXDATE:20240305T090000Z"},
+			from:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			want:  []string{"2024-03-04 09:00", "2024-03-06 09:00", "2024-03-07 09:00"},
+		},
+		{
+			name: "moved occurrence",
+			lines: []string{"DTSTART:20240304T090000Z", "DURATION:PT30M", "RRULE:FREQ=WEEKLY", "END:VEVENT",
+				"BEGIN:VEVENT", "UID:event@example.com", "SUMMARY:Moved", "RECURRENCE-ID:20240311T090000Z", "DTSTART:20240312T140000Z", "DURATION:PT30M"},
+			from: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			to:   time.Date(2024, 3, 19, 0, 0, 0, 0, time.UTC),
+			want: []string{"2024-03-04 09:00", "2024-03-12 14:00", "2024-03-18 09:00"},
+		},
+		{
+			name:  "monthly on the 31st skips shorter months",
+			lines: []string{"DTSTART:20240131T100000Z", "DURATION:PT1H", "RRULE:FREQ=MONTHLY;COUNT=4"},
+			from:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:  []string{"2024-01-31 10:00", "2024-03-31 10:00", "2024-05-31 10:00", "2024-07-31 10:00"},
+		},
+		{
+			name:  "time of day kept in the time zone across daylight saving time",
+			lines: []string{"DTSTART;TZID=Europe/Berlin:20240318T100000", "DTEND;TZID=Europe/Berlin:20240318T103000", "RRULE:FREQ=WEEKLY;COUNT=3"},
+			from:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			want:  []string{"2024-03-18 09:00", "2024-03-25 09:00", "2024-04-01 08:00"},
+		},
+		{
+			name:  "only the occurrences overlapping the window",
+			lines: []string{"DTSTART:20240304T090000Z", "DURATION:PT1H", "RRULE:FREQ=DAILY"},
+			from:  time.Date(2024, 3, 6, 9, 30, 0, 0, time.UTC),
+			to:    time.Date(2024, 3, 8, 9, 0, 0, 0, time.UTC),
+			want:  []string{"2024-03-06 09:00", "2024-03-07 09:00"},
+		},
+		{
+			name:  "cancelled",
+			lines: []string{"DTSTART:20240304T090000Z", "DURATION:PT1H", "STATUS:CANCELLED"},
+			from:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "all day",
+			lines: []string{"DTSTART;VALUE=DATE:20240304", "DTEND;VALUE=DATE:20240305"},
+			from:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines := append([]string{"BEGIN:VEVENT", "UID:event@example.com", "SUMMARY:Meeting"}, test.lines...)
+			events, err := parseICS([]byte(newICS(append(lines, "END:VEVENT")...)))
+			if err != nil {
+				t.Fatalf("parseICS() error %v", err)
+			}
+			got := formatMeetingStarts(expandEvents(events, test.from, test.to))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expandEvents() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestQueryCalDAV(t *testing.T) {
+	useUTCAsLocalTime(t)
+	calendar := newICS(
+		"BEGIN:VEVENT",
+		"UID:standup@example.com",
+		"SUMMARY:Stand-up of the platform team with the product owner & the",
+		"  designers",
+		"DTSTART:20240305T083000Z",
+		"DTEND:20240305T084500Z",
+		"END:VEVENT",
+	)
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(calendar))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case r.Method != "REPORT" || r.Header.Get("Depth") != "1":
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case username != "me" || password != "secret":
+			w.WriteHeader(http.StatusUnauthorized)
+		case !strings.Contains(string(body), `<C:time-range start="20240304T000000Z" end="20240311T000000Z"/>`):
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			w.WriteHeader(http.StatusMultiStatus)
+			io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>
+<d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">
+  <d:response>
+    <d:href>/calendars/me/work/standup.ics</d:href>
+    <d:propstat>
+      <d:prop><cal:calendar-data>`+escaped.String()+`</cal:calendar-data></d:prop>
+      <d:status>HTTP/1.1 200 OK</d:status>
+    </d:propstat>
+  </d:response>
+  <d:response>
+    <d:href>/calendars/me/work/gone.ics</d:href>
+    <d:propstat>
+      <d:prop><cal:calendar-data/></d:prop>
+      <d:status>HTTP/1.1 404 Not Found</d:status>
+    </d:propstat>
+  </d:response>
+</d:multistatus>`)
+		}
+	}))
+	defer server.Close()
+
+	from := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	source := CalendarSource{Name: "Work", Type: calendarSourceCalDAV, URL: server.URL + "/calendars/me/work/", Username: "me", Password: "secret"}
+	meetings, err := fetchMeetings(context.Background(), source, from, to)
+	if err != nil {
+		t.Fatalf("fetchMeetings() error %v", err)
+	}
+	want := []Meeting{{
+		UID:     "standup@example.com",
+		Summary: "Stand-up of the platform team with the product owner & the designers",
+		Start:   time.Date(2024, 3, 5, 8, 30, 0, 0, time.UTC),
+		End:     time.Date(2024, 3, 5, 8, 45, 0, 0, time.UTC),
+		Source:  "Work",
+	}}
+	if !reflect.DeepEqual(meetings, want) {
+		t.Errorf("fetchMeetings() = %+v, want %+v", meetings, want)
+	}
+
+	source.Password = "wrong"
+	if _, err := queryCalDAV(context.Background(), source, from, to); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("queryCalDAV() with a wrong password error %v, want 401", err)
+	}
+}
+
+func TestReadICSFromDataDirectory(t *testing.T) {
+	useUTCAsLocalTime(t)
+	useTempDataDirectory(t)
+	data := newICS("BEGIN:VEVENT", "UID:review@example.com", "SUMMARY:Review", "DTSTART:20240305T150000", "DURATION:PT1H", "END:VEVENT")
+	os.WriteFile(getDataFilePath("work.ics"), []byte(data), 0644)
+
+	source := CalendarSource{Name: "File", Type: calendarSourceICS, URL: "work.ics"}
+	meetings, err := fetchMeetings(context.Background(), source, time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("fetchMeetings() error %v", err)
+	}
+	if got := formatMeetingStarts(meetings); !reflect.DeepEqual(got, []string{"2024-03-05 15:00"}) || meetings[0].Source != "File" {
+		t.Errorf("fetchMeetings() = %+v", meetings)
+	}
+}
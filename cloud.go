@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type IssuePickerResponse struct {
+	Sections []struct {
+		Label  string `json:"label"`
+		Issues []struct {
+			Key         string `json:"key"`
+			SummaryText string `json:"summaryText"`
+		} `json:"issues"`
+	} `json:"sections"`
+}
+
+type CloudJQLSearchResponse struct {
+	NextPageToken string `json:"nextPageToken"`
+	IsLast        bool   `json:"isLast"`
+	Issues        []struct {
+		ID     string `json:"id"`
+		Key    string `json:"key"`
+		Fields struct {
+			Summary string `json:"summary"`
+			Project struct {
+				ID   string `json:"id"`
+				Key  string `json:"key"`
+				Name string `json:"name"`
+			} `json:"project"`
+		} `json:"fields"`
+	} `json:"issues"`
+}
+
+type CloudIssue struct {
+	ID     string                     `json:"id"`
+	Key    string                     `json:"key"`
+	Fields map[string]json.RawMessage `json:"fields"`
+}
+
+type TempoCloudAccount struct {
+	ID     int    `json:"id"`
+	Key    string `json:"key"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+type TempoCloudAccountsResponse struct {
+	Results []TempoCloudAccount `json:"results"`
+}
+
+type TempoCloudAccountLinksResponse struct {
+	Results []struct {
+		Account struct {
+			ID int `json:"id"`
+		} `json:"account"`
+	} `json:"results"`
+}
+
+type TempoCloudWorkAttributesResponse struct {
+	Results []struct {
+		Key      string            `json:"key"`
+		Name     string            `json:"name"`
+		Type     string            `json:"type"`
+		Required bool              `json:"required"`
+		Names    map[string]string `json:"names"`
+		Values   []string          `json:"values"`
+	} `json:"results"`
+}
+
+type TempoCloudWorklog struct {
+	AuthorAccountID          string                       `json:"authorAccountId"`
+	IssueID                  int                          `json:"issueId"`
+	StartDate                string                       `json:"startDate"`
+	StartTime                string                       `json:"startTime"`
+	TimeSpentSeconds         int                          `json:"timeSpentSeconds"`
+	Description              string                       `json:"description"`
+	RemainingEstimateSeconds *int                         `json:"remainingEstimateSeconds,omitempty"`
+	Attributes               []TempoCloudWorklogAttribute `json:"attributes"`
+}
+
+type TempoCloudWorklogAttribute struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func searchJIRACloudIssuePicker(ctx context.Context, connection *jiraConnection, q string) ([]JIRAIssue, error) {
+	var result []JIRAIssue = make([]JIRAIssue, 0)
+
+	path := connection.apiPath("/issue/picker?query=%s&showSubTasks=true", url.QueryEscape(q))
+	myLogger.Printf("Requesting issues from JIRA %s", path)
+
+	var pickerResponse IssuePickerResponse
+	err := connection.JIRA.Do(ctx, "GET", path, nil, &pickerResponse)
+	if err != nil {
+		myErrorLogger.Printf("Got error %s", err.Error())
+		return result, err
+	}
+
+	fetched := time.Now()
+	found := make(map[string]bool)
+	for _, section := range pickerResponse.Sections {
+		for _, issue := range section.Issues {
+			if found[issue.Key] {
+				continue
+			}
+			found[issue.Key] = true
+			result = append(result, JIRAIssue{
+				Key:     issue.Key,
+				Summary: issue.SummaryText,
+				Project: getProjectKeyFromIssueKey(issue.Key),
+				Fetched: fetched,
+			})
+		}
+	}
+	return result, nil
+}
+
+// searchJIRACloudWithJQL pages through /rest/api/3/search/jql, which unlike
+// the Server search pages by token instead of by offset.
+func searchJIRACloudWithJQL(ctx context.Context, connection *jiraConnection, jql string, maxResults int) ([]JIRAIssue, error) {
+	var result []JIRAIssue = make([]JIRAIssue, 0)
+
+	var nextPageToken string
+	for len(result) < maxResults {
+		pageSize := maxResults - len(result)
+		if pageSize > 50 {
+			pageSize = 50
+		}
+		path := connection.apiPath("/search/jql?jql=%s&fields=summary,project&maxResults=%d", url.QueryEscape(jql), pageSize)
+		if nextPageToken != "" {
+			path += "&nextPageToken=" + url.QueryEscape(nextPageToken)
+		}
+		myLogger.Printf("Requesting issues by JQL from JIRA %s", path)
+
+		var searchResponse CloudJQLSearchResponse
+		err := connection.JIRA.Do(ctx, "GET", path, nil, &searchResponse)
+		if err != nil {
+			myErrorLogger.Printf("Got error %s", err.Error())
+			return result, err
+		}
+
+		fetched := time.Now()
+		for _, issue := range searchResponse.Issues {
+			result = append(result, JIRAIssue{
+				Key:     issue.Key,
+				Summary: issue.Fields.Summary,
+				Project: issue.Fields.Project.Key,
+				Fetched: fetched,
+			})
+		}
+
+		nextPageToken = searchResponse.NextPageToken
+		if len(searchResponse.Issues) == 0 || searchResponse.IsLast || nextPageToken == "" {
+			break
+		}
+	}
+	return result, nil
+}
+
+func getTempoCloudAccount(connection *jiraConnection, id int) (TempoCloudAccount, error) {
+	var account TempoCloudAccount
+	err := connection.Tempo.Do(context.Background(), "GET", fmt.Sprintf("/accounts/%d", id), nil, &account)
+	return account, err
+}
+
+// getCloudProjectAndAccountForIssue reads the project and the Tempo account
+// field of an issue. On Cloud the account field holds the account ID and its
+// name only, so the key is looked up in Tempo.
+func getCloudProjectAndAccountForIssue(connection *jiraConnection, issue string) (IssueWithProjectAndActivity, error) {
+	var result IssueWithProjectAndActivity
+	accountField := connection.Config.AccountFieldID
+	path := connection.apiPath("/issue/%s?fields=project,%s", url.PathEscape(issue), url.QueryEscape(accountField))
+	myLogger.Printf("Requesting project and accounts for issue from JIRA %s", path)
+
+	var cloudIssue CloudIssue
+	err := connection.JIRA.Do(context.Background(), "GET", path, nil, &cloudIssue)
+	if err != nil {
+		return result, err
+	}
+	result.ID = cloudIssue.ID
+	result.Key = cloudIssue.Key
+	err = json.Unmarshal(cloudIssue.Fields["project"], &result.Fields.Project)
+	if err != nil {
+		return result, err
+	}
+
+	var accountValue struct {
+		ID    int    `json:"id"`
+		Value string `json:"value"`
+	}
+	if json.Unmarshal(cloudIssue.Fields[accountField], &accountValue) == nil && accountValue.ID != 0 {
+		account, err := getTempoCloudAccount(connection, accountValue.ID)
+		if err != nil {
+			myWarningLogger.Printf("Could not get Tempo account %d: %s", accountValue.ID, err.Error())
+		} else {
+			result.Fields.Customfield10900.ID = strconv.Itoa(account.ID)
+			result.Fields.Customfield10900.Key = account.Key
+			result.Fields.Customfield10900.Name = account.Name
+		}
+	}
+	return result, nil
+}
+
+func getCloudAccountsForProject(connection *jiraConnection, projectID string) ([]string, error) {
+	var result []string = make([]string, 0)
+
+	var links TempoCloudAccountLinksResponse
+	err := connection.Tempo.Do(context.Background(), "GET", fmt.Sprintf("/account-links/project/%s", url.PathEscape(projectID)), nil, &links)
+	if err != nil {
+		return result, err
+	}
+	linked := make(map[int]bool)
+	for _, link := range links.Results {
+		linked[link.Account.ID] = true
+	}
+
+	var accounts TempoCloudAccountsResponse
+	err = connection.Tempo.Do(context.Background(), "GET", "/accounts?status=OPEN&limit=1000", nil, &accounts)
+	if err != nil {
+		return result, err
+	}
+	for _, account := range accounts.Results {
+		if linked[account.ID] || len(linked) == 0 {
+			result = append(result, fmt.Sprintf("%s: %s", account.Key, account.Name))
+		}
+	}
+	return result, nil
+}
+
+// getCloudWorkAttributeDefinitions maps the Tempo Cloud work attributes to
+// the Server schema the rest of the tracker works with.
+func getCloudWorkAttributeDefinitions(connection *jiraConnection) ([]WorkAttribute, error) {
+	var response TempoCloudWorkAttributesResponse
+	err := connection.Tempo.Do(context.Background(), "GET", "/work-attributes", nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	var attributes []WorkAttribute
+	for sequence, result := range response.Results {
+		attributeType := result.Type
+		if attributeType == "INPUT_FIELD" {
+			attributeType = "INPUT"
+		}
+		attribute := WorkAttribute{
+			Key:      result.Key,
+			Name:     result.Name,
+			Type:     WorkAttributeType{Name: result.Type, Value: attributeType},
+			Required: result.Required,
+			Sequence: sequence,
+		}
+		for valueSequence, value := range result.Values {
+			name := result.Names[value]
+			if name == "" {
+				name = value
+			}
+			attribute.StaticListValues = append(attribute.StaticListValues, WorkAttributeStaticValue{Name: name, Value: value, Sequence: valueSequence})
+		}
+		attributes = append(attributes, attribute)
+	}
+	return attributes, nil
+}
+
+// getIssueID returns the numeric ID of an issue, which Tempo Cloud requires
+// instead of the key.
+func getIssueID(connection *jiraConnection, issue string) (int, error) {
+	if id, err := strconv.Atoi(issue); err == nil {
+		return id, nil
+	}
+	var idOnly struct {
+		ID string `json:"id"`
+	}
+	err := connection.JIRA.Do(context.Background(), "GET", connection.apiPath("/issue/%s?fields=none", url.PathEscape(issue)), nil, &idOnly)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(idOnly.ID)
+}
+
+func postTempoCloudWorklog(connection *jiraConnection, worklog Worklog, started time.Time) error {
+	issueID, err := getIssueID(connection, worklog.OriginTaskID)
+	if err != nil {
+		return err
+	}
+	cloudWorklog := TempoCloudWorklog{
+		AuthorAccountID:  worklog.Worker,
+		IssueID:          issueID,
+		StartDate:        started.Format("2006-01-02"),
+		StartTime:        started.Format("15:04:05"),
+		TimeSpentSeconds: worklog.TimeSpentSeconds,
+		Description:      worklog.Comment,
+		Attributes:       make([]TempoCloudWorklogAttribute, 0),
+	}
+	if remainingEstimate, ok := worklog.RemainingEstimate.(int); ok {
+		cloudWorklog.RemainingEstimateSeconds = &remainingEstimate
+	}
+	for key, attribute := range worklog.Attributes {
+		cloudWorklog.Attributes = append(cloudWorklog.Attributes, TempoCloudWorklogAttribute{Key: key, Value: attribute.Value})
+	}
+	return connection.Tempo.Do(context.Background(), "POST", "/worklogs", &cloudWorklog, nil)
+}
+
+// newDocument wraps plain text into the Atlassian Document Format that the
+// JIRA Cloud REST API v3 expects for comments, one paragraph per line.
+func newDocument(text string) map[string]interface{} {
+	var paragraphs []interface{}
+	for _, line := range strings.Split(text, "\n") {
+		paragraph := map[string]interface{}{"type": "paragraph"}
+		if line != "" {
+			paragraph["content"] = []interface{}{map[string]interface{}{"type": "text", "text": line}}
+		}
+		paragraphs = append(paragraphs, paragraph)
+	}
+	return map[string]interface{}{"type": "doc", "version": 1, "content": paragraphs}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultWorklogFooter = "Working from {{.Location}}\nAutomatically filled by MyTracker written in GoLang"
+	defaultIssueFooter   = "Logged {{.Duration}} from {{.Location}} with MyTracker"
+	// legacyWorklogFooter is the default of earlier versions, which put the
+	// public IP into every worklog
+	legacyWorklogFooter = "Working from {{.IP}}\nAutomatically filled by MyTracker written in GoLang"
+)
+
+// commentTemplateData holds the fields available to the comment footer
+// templates. IP is left empty for comments posted to the issue itself.
+type commentTemplateData struct {
+	Task     string
+	TaskName string
+	Comment  string
+	Account  string
+	Activity string
+	Location string
+	IP       string
+	Duration string
+	Date     string
+}
+
+func renderCommentFooter(footerTemplate string, data commentTemplateData) string {
+	if strings.TrimSpace(footerTemplate) == "" {
+		return ""
+	}
+	parsedTemplate, err := template.New("footer").Parse(footerTemplate)
+	if err != nil {
+		myErrorLogger.Printf("Got error when parsing comment footer %s", err.Error())
+		return ""
+	}
+	buf := new(bytes.Buffer)
+	err = parsedTemplate.Execute(buf, data)
+	if err != nil {
+		myErrorLogger.Printf("Got error when rendering comment footer %s", err.Error())
+		return ""
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+func buildComment(text string, footer string) string {
+	if footer == "" {
+		return text
+	}
+	return fmt.Sprintf("%s\n%s", text, footer)
+}
+
+// newCommentTemplateData fills the footer data of a worklog. The date is the
+// day the work was started, which for past meetings, window bookings and
+// drafts submitted later is not today.
+func newCommentTemplateData(task string, taskName string, account string, comment string, activity string, publicIP string, started time.Time, duration time.Duration) commentTemplateData {
+	return commentTemplateData{
+		Task:     task,
+		TaskName: taskName,
+		Comment:  comment,
+		Account:  account,
+		Activity: getActivityName(activity),
+		Location: getWorkLocation(publicIP),
+		IP:       publicIP,
+		Duration: duration.Round(time.Minute).String(),
+		Date:     started.Format("2006-01-02"),
+	}
+}
+
+func shouldPostCommentToIssue(task string) bool {
+	if postToIssue, found := trackerConfig.Comments.PostToIssue[task]; found {
+		return postToIssue
+	}
+	return trackerConfig.Comments.PostToIssueByDefault
+}
+
+func rememberPostCommentToIssue(task string, postToIssue bool) {
+	task = getElementFromStringWithColon(task, 0)
+	if postToIssue == shouldPostCommentToIssue(task) {
+		return
+	}
+	if trackerConfig.Comments.PostToIssue == nil {
+		trackerConfig.Comments.PostToIssue = make(map[string]bool)
+	}
+	trackerConfig.Comments.PostToIssue[task] = postToIssue
+	saveTrackerConfig()
+}
+
+// postIssueComment adds the comment of a worklog to the issue. The comment is
+// visible to everybody with access to the issue, so the public IP is never
+// handed to its footer.
+func postIssueComment(connection *jiraConnection, task string, comment string, data commentTemplateData) error {
+	data.IP = ""
+	body := buildComment(comment, renderCommentFooter(trackerConfig.Comments.IssueFooter, data))
+	myLogger.Printf("Posting comment to %s on %s", task, connection.Config.Name)
+	path := connection.apiPath("/issue/%s/comment", url.PathEscape(task))
+	if connection.isCloud() {
+		return connection.JIRA.Do(context.Background(), "POST", path, map[string]interface{}{"body": newDocument(body)}, nil)
+	}
+	return connection.JIRA.Do(context.Background(), "POST", path, map[string]string{"body": body}, nil)
+}
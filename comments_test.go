@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCommentFooterDate(t *testing.T) {
+	tests := []struct {
+		name    string
+		started time.Time
+		want    string
+	}{
+		{name: "today", started: time.Now(), want: "on " + time.Now().Format("2006-01-02")},
+		{name: "past meeting", started: time.Date(2024, 3, 11, 9, 30, 0, 0, time.Local), want: "on 2024-03-11"},
+		{name: "draft of yesterday", started: time.Now().AddDate(0, 0, -1), want: "on " + time.Now().AddDate(0, 0, -1).Format("2006-01-02")},
+	}
+	connection := newJIRAConnection(ConnectionConfig{Name: "server", BaseURL: "http://jira.invalid"})
+	connection.workAttributesFetched = true
+	useJIRAConnections(t, connection)
+	for _, test := range tests {
+		data := newCommentTemplateData("ABC-1", "Fix login", "Account", "", "", "", test.started, 30*time.Minute)
+		if got := renderCommentFooter("on {{.Date}}", data); got != test.want {
+			t.Errorf("%s: footer %q, want %q", test.name, got, test.want)
+		}
+	}
+}
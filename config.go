@@ -0,0 +1,446 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"fyne.io/fyne/v2/dialog"
+)
+
+var trackerConfig TrackerConfig = defaultTrackerConfig()
+
+type TrackerConfig struct {
+	Connections    []ConnectionConfig   `json:"connections"`
+	Hotkeys        HotkeyConfig         `json:"hotkeys"`
+	JIRASearch     JIRASearchConfig     `json:"jiraSearch"`
+	Suggestions    SuggestionsConfig    `json:"suggestions"`
+	Accounts       AccountsConfig       `json:"accounts"`
+	WorkAttributes WorkAttributesConfig `json:"workAttributes"`
+	Estimates      EstimatesConfig      `json:"estimates"`
+	Transitions    TransitionsConfig    `json:"transitions"`
+	Comments       CommentsConfig       `json:"comments"`
+	Rounding       RoundingConfig       `json:"rounding"`
+	Logging        LoggingConfig        `json:"logging"`
+	Pomodoro       PomodoroConfig       `json:"pomodoro"`
+	Calendar       CalendarConfig       `json:"calendar"`
+	Git            GitConfig            `json:"git"`
+	WindowTracking WindowTrackingConfig `json:"windowTracking"`
+	Submission     SubmissionConfig     `json:"submission"`
+	Approvals      ApprovalsConfig      `json:"approvals"`
+
+	// Connection is the single connection of earlier versions, it is moved
+	// to Connections when the config is read.
+	Connection *ConnectionConfig `json:"connection,omitempty"`
+}
+
+// ConnectionConfig.Name labels the search results of the connection and binds
+// tasks to it; the first connection that is not Disabled books all tasks not
+// bound to another one. Type is "server" for JIRA Server and Data Center, which
+// use the personal access token Token, or "cloud" for JIRA Cloud, which uses
+// Email and APIToken, with Tempo Cloud at TempoBaseURL using TempoToken.
+// Worker is the Tempo worker of the worklogs, the user key on Server and the
+// account ID on Cloud; when empty it is read from JIRA. AccountFieldID is the
+// issue field holding the Tempo account on Cloud. Worklogs without an account
+// are booked on InternalIssue with InternalAccount, and cannot be booked when
+// InternalIssue is empty. Only the default Server connection presets Worker,
+// InternalIssue and InternalAccount. Auth is "token" for the
+// tokens above, or "oauth2" or "oauth1" to authorize with OAuth.
+type ConnectionConfig struct {
+	Name            string      `json:"name"`
+	Disabled        bool        `json:"disabled,omitempty"`
+	Type            string      `json:"type"`
+	BaseURL         string      `json:"baseUrl"`
+	Token           string      `json:"token"`
+	Email           string      `json:"email"`
+	APIToken        string      `json:"apiToken"`
+	TempoBaseURL    string      `json:"tempoBaseUrl"`
+	TempoToken      string      `json:"tempoToken"`
+	Worker          string      `json:"worker"`
+	AccountFieldID  string      `json:"accountFieldId"`
+	InternalIssue   string      `json:"internalIssue"`
+	InternalAccount string      `json:"internalAccount"`
+	Auth            string      `json:"auth"`
+	OAuth           OAuthConfig `json:"oauth"`
+}
+
+// OAuthConfig holds the OAuth 2.0 client of the connection, or for OAuth
+// 1.0a the consumer key and the private key file of its JIRA application
+// link. The endpoints default to those of Atlassian and JIRA; overriding
+// them allows to authorize against another server, e.g. a local mock. The
+// redirect goes to http://127.0.0.1:RedirectPort/callback, which must be
+// registered with the OAuth 2.0 client.
+type OAuthConfig struct {
+	ClientID         string   `json:"clientId,omitempty"`
+	ClientSecret     string   `json:"clientSecret,omitempty"`
+	Scopes           []string `json:"scopes,omitempty"`
+	Audience         string   `json:"audience,omitempty"`
+	ConsumerKey      string   `json:"consumerKey,omitempty"`
+	PrivateKeyFile   string   `json:"privateKeyFile,omitempty"`
+	AuthorizationURL string   `json:"authorizationUrl,omitempty"`
+	TokenURL         string   `json:"tokenUrl,omitempty"`
+	ResourcesURL     string   `json:"resourcesUrl,omitempty"`
+	APIBaseURL       string   `json:"apiBaseUrl,omitempty"`
+	RequestTokenURL  string   `json:"requestTokenUrl,omitempty"`
+	AccessTokenURL   string   `json:"accessTokenUrl,omitempty"`
+	RedirectPort     int      `json:"redirectPort,omitempty"`
+}
+
+type HotkeyConfig struct {
+	Enabled     bool   `json:"enabled"`
+	QuickSwitch string `json:"quickSwitch"`
+	Stop        string `json:"stop"`
+	Pause       string `json:"pause"`
+}
+
+type JIRASearchConfig struct {
+	DebounceMilliseconds int `json:"debounceMilliseconds"`
+	TimeoutSeconds       int `json:"timeoutSeconds"`
+	CacheTTLHours        int `json:"cacheTTLHours"`
+}
+
+type SuggestionsConfig struct {
+	Queries        []SuggestionQuery `json:"queries"`
+	SprintBoardID  int               `json:"sprintBoardId"`
+	MaxResults     int               `json:"maxResults"`
+	RefreshMinutes int               `json:"refreshMinutes"`
+}
+
+// AccountsConfig.RememberedAccounts maps the connection name and project key,
+// joined by "/", to the account that is preselected for all of its issues.
+type AccountsConfig struct {
+	CacheTTLHours      int               `json:"cacheTTLHours"`
+	RememberedAccounts map[string]string `json:"rememberedAccounts"`
+}
+
+// WorkAttributesConfig.Defaults maps a Tempo work attribute key to the value
+// used when a task has no value of its own for it.
+type WorkAttributesConfig struct {
+	LocationAttributeKey string            `json:"locationAttributeKey"`
+	ActivityAttributeKey string            `json:"activityAttributeKey"`
+	OfficeIPPrefix       string            `json:"officeIpPrefix"`
+	OfficeValue          string            `json:"officeValue"`
+	HomeValue            string            `json:"homeValue"`
+	Defaults             map[string]string `json:"defaults"`
+}
+
+// EstimatesConfig.DefaultMode and the values of IssueModes are one of
+// "auto", "leave" or "new" and decide what a worklog does to the remaining
+// estimate of its issue. HoursPerDay and DaysPerWeek must match the time
+// tracking settings of JIRA.
+type EstimatesConfig struct {
+	DefaultMode string            `json:"defaultMode"`
+	IssueModes  map[string]string `json:"issueModes"`
+	HoursPerDay int               `json:"hoursPerDay"`
+	DaysPerWeek int               `json:"daysPerWeek"`
+}
+
+// TransitionsConfig.Projects maps a project key to the workflow hooks of its
+// issues; the "*" entry applies to all projects without an entry.
+type TransitionsConfig struct {
+	Projects map[string]ProjectTransitionsConfig `json:"projects"`
+}
+
+// ProjectTransitionsConfig.StartTransition is the name of the transition, or
+// of the status it leads to, offered when starting to work on an issue.
+type ProjectTransitionsConfig struct {
+	StartTransition string `json:"startTransition"`
+	AssignOnStart   bool   `json:"assignOnStart"`
+	OfferOnStop     bool   `json:"offerOnStop"`
+}
+
+// CommentsConfig.WorklogFooter and IssueFooter are text/templates appended to
+// the worklog comment and to the comment posted to the issue; an empty
+// template drops the footer. Worklogs can be read by everybody who sees the
+// issue, so the public IP ({{.IP}}) is only in a worklog footer that asks for
+// it and never in the issue footer. PostToIssue maps a task to whether its
+// comments are posted to the issue as well.
+type CommentsConfig struct {
+	WorklogFooter        string          `json:"worklogFooter"`
+	IssueFooter          string          `json:"issueFooter"`
+	PostToIssueByDefault bool            `json:"postToIssueByDefault"`
+	PostToIssue          map[string]bool `json:"postToIssue"`
+}
+
+// RoundingConfig is the policy applied to every booked duration, in
+// work.log as well as in Tempo. Mode is one of "none", "nearest", "up" or
+// "down"; ShortEntryAction, one of "keep", "merge" or "drop", decides what
+// happens to tracked entries shorter than ShortEntryMinutes; merged ones are
+// added to the entry that follows them on the same day.
+type RoundingConfig struct {
+	Mode               string `json:"mode"`
+	GranularityMinutes int    `json:"granularityMinutes"`
+	MinimumMinutes     int    `json:"minimumMinutes"`
+	ShortEntryMinutes  int    `json:"shortEntryMinutes"`
+	ShortEntryAction   string `json:"shortEntryAction"`
+}
+
+// LoggingConfig.Level is one of "debug", "info", "warn" or "error" and Format
+// one of "text" or "json". tracker.log is rotated when it grows beyond
+// MaxSizeMB, keeping MaxBackups old logs for at most MaxAgeDays.
+type LoggingConfig struct {
+	Level      string `json:"level"`
+	Format     string `json:"format"`
+	MaxSizeMB  int    `json:"maxSizeMB"`
+	MaxBackups int    `json:"maxBackups"`
+	MaxAgeDays int    `json:"maxAgeDays"`
+}
+
+// PomodoroConfig sets the lengths of focus sessions and breaks in focus mode,
+// every SessionsBeforeLongBreak sessions the break is a long one. With
+// PauseDuringBreaks the running task is paused for a break and resumed after.
+type PomodoroConfig struct {
+	WorkMinutes             int  `json:"workMinutes"`
+	ShortBreakMinutes       int  `json:"shortBreakMinutes"`
+	LongBreakMinutes        int  `json:"longBreakMinutes"`
+	SessionsBeforeLongBreak int  `json:"sessionsBeforeLongBreak"`
+	PauseDuringBreaks       bool `json:"pauseDuringBreaks"`
+}
+
+// CalendarConfig.Sources are read every RefreshMinutes. Meetings of the last
+// LookbackDays days, today included, without tracked time are proposed for
+// booking, and with OfferSwitch the tracker offers to switch to the task of a
+// meeting when it starts. Rules map meeting titles to tasks; the first
+// matching rule wins.
+type CalendarConfig struct {
+	Sources        []CalendarSource `json:"sources"`
+	Rules          []CalendarRule   `json:"rules"`
+	RefreshMinutes int              `json:"refreshMinutes"`
+	LookbackDays   int              `json:"lookbackDays"`
+	OfferSwitch    bool             `json:"offerSwitch"`
+}
+
+// CalendarSource.Type is "ics" for an iCalendar file, where URL is an http(s)
+// or webcal URL or a path, or "caldav" for a CalDAV calendar collection.
+// Username and Password are sent with basic auth.
+type CalendarSource struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// CalendarRule assigns the meetings whose title matches the regular
+// expression Pattern to Task, which may refer to groups of the pattern, e.g.
+// "$1". Account ("KEY:Name"), Activity and Connection override those the
+// task would get otherwise.
+type CalendarRule struct {
+	Pattern    string `json:"pattern"`
+	Task       string `json:"task"`
+	Account    string `json:"account,omitempty"`
+	Activity   string `json:"activity,omitempty"`
+	Connection string `json:"connection,omitempty"`
+}
+
+// GitConfig.Repositories are local working copies whose branch names and
+// commit messages contain issue keys matching KeyPattern. The issues of the
+// current branches and recent commits are suggested when starting a task.
+// WarnOnCommitMismatch flags commits to another issue than the running task,
+// and SwitchOnCheckout switches the running task to the issue of a branch
+// when it is checked out.
+type GitConfig struct {
+	Repositories         []string `json:"repositories"`
+	KeyPattern           string   `json:"keyPattern"`
+	PollSeconds          int      `json:"pollSeconds"`
+	WarnOnCommitMismatch bool     `json:"warnOnCommitMismatch"`
+	SwitchOnCheckout     bool     `json:"switchOnCheckout"`
+}
+
+// WindowTrackingConfig.Enabled samples the focused window every SampleSeconds
+// while the user was active within the last IdleSeconds, from the platform or
+// from Command, which prints the application and the title separated by a
+// tab. The samples stay in windows.log for RetentionDays and are never
+// uploaded; Rules turn them into suggested entries to review, joining
+// stretches of a task up to MergeGapMinutes apart and leaving out those
+// shorter than MinimumMinutes.
+type WindowTrackingConfig struct {
+	Enabled         bool         `json:"enabled"`
+	Command         string       `json:"command,omitempty"`
+	SampleSeconds   int          `json:"sampleSeconds"`
+	IdleSeconds     int          `json:"idleSeconds"`
+	RetentionDays   int          `json:"retentionDays"`
+	MergeGapMinutes int          `json:"mergeGapMinutes"`
+	MinimumMinutes  int          `json:"minimumMinutes"`
+	Rules           []WindowRule `json:"rules"`
+}
+
+// WindowRule assigns the time in windows whose application matches App and
+// whose title matches Title, both regular expressions that match any window
+// when empty, to Task, which may refer to groups of Title, e.g. "$1".
+// Account ("KEY:Name") and Activity override those the task would get
+// otherwise.
+type WindowRule struct {
+	App      string `json:"app,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Task     string `json:"task"`
+	Account  string `json:"account,omitempty"`
+	Activity string `json:"activity,omitempty"`
+}
+
+// SubmissionConfig.Mode "immediate" books every worklog in Tempo when it is
+// logged, "draft" keeps them locally until they are submitted from the
+// review, which opens at ReviewTime ("15:04") while drafts are pending. Days
+// with less than DailyTargetHours are flagged in the review.
+type SubmissionConfig struct {
+	Mode             string  `json:"mode"`
+	ReviewTime       string  `json:"reviewTime"`
+	DailyTargetHours float64 `json:"dailyTargetHours"`
+}
+
+// ApprovalsConfig.Reviewer is the reviewer ("key:Name") last chosen to submit
+// the timesheet to. From ReminderTime ("15:04") on the last working day of a
+// Tempo period the tracker reminds to submit the timesheet when its hours are
+// below target; an empty ReminderTime turns the reminder off.
+type ApprovalsConfig struct {
+	Reviewer     string `json:"reviewer,omitempty"`
+	ReminderTime string `json:"reminderTime"`
+}
+
+func defaultTrackerConfig() TrackerConfig {
+	return TrackerConfig{
+		Connections: []ConnectionConfig{{
+			Name:            "Surecomp",
+			Type:            connectionTypeServer,
+			BaseURL:         "https://jira.surecomp.com",
+			TempoBaseURL:    defaultTempoCloudBaseURL,
+			Worker:          "JIRAUSER11920",
+			AccountFieldID:  defaultAccountFieldID,
+			InternalIssue:   "71238",
+			InternalAccount: "INT101",
+			Auth:            authToken,
+		}},
+		Hotkeys: HotkeyConfig{
+			Enabled:     true,
+			QuickSwitch: "Ctrl+Alt+T",
+			Stop:        "Ctrl+Alt+S",
+			Pause:       "Ctrl+Alt+P",
+		},
+		JIRASearch: JIRASearchConfig{
+			DebounceMilliseconds: 300,
+			TimeoutSeconds:       15,
+			CacheTTLHours:        24 * 14,
+		},
+		Suggestions: SuggestionsConfig{
+			Queries: []SuggestionQuery{
+				{Name: "In Progress", JQL: `assignee = currentUser() AND status in ("In Progress") ORDER BY updated DESC`},
+				{Name: "Updated by me in the last 7 days", JQL: `issuekey in updatedBy(currentUser(), "-7d") ORDER BY updated DESC`},
+			},
+			MaxResults:     20,
+			RefreshMinutes: 10,
+		},
+		Accounts: AccountsConfig{
+			CacheTTLHours:      24 * 7,
+			RememberedAccounts: make(map[string]string),
+		},
+		WorkAttributes: WorkAttributesConfig{
+			LocationAttributeKey: "_WorkFrom_",
+			ActivityAttributeKey: "_Task_",
+			OfficeIPPrefix:       "89.245",
+			OfficeValue:          "Office",
+			HomeValue:            "Home",
+			Defaults:             map[string]string{"_Task_": "Administration"},
+		},
+		Estimates: EstimatesConfig{
+			DefaultMode: remainingEstimateAuto,
+			IssueModes:  make(map[string]string),
+			HoursPerDay: 8,
+			DaysPerWeek: 5,
+		},
+		Transitions: TransitionsConfig{
+			Projects: make(map[string]ProjectTransitionsConfig),
+		},
+		Comments: CommentsConfig{
+			WorklogFooter: defaultWorklogFooter,
+			IssueFooter:   defaultIssueFooter,
+			PostToIssue:   make(map[string]bool),
+		},
+		Rounding: RoundingConfig{
+			Mode:               roundingNearest,
+			GranularityMinutes: 1,
+			ShortEntryAction:   shortEntryKeep,
+		},
+		Logging: LoggingConfig{
+			Level:      "info",
+			Format:     "text",
+			MaxSizeMB:  5,
+			MaxBackups: 3,
+			MaxAgeDays: 30,
+		},
+		Pomodoro: PomodoroConfig{
+			WorkMinutes:             25,
+			ShortBreakMinutes:       5,
+			LongBreakMinutes:        15,
+			SessionsBeforeLongBreak: 4,
+		},
+		Calendar: CalendarConfig{
+			RefreshMinutes: 15,
+			LookbackDays:   1,
+			OfferSwitch:    true,
+		},
+		Git: GitConfig{
+			KeyPattern:           defaultGitKeyPattern,
+			PollSeconds:          5,
+			WarnOnCommitMismatch: true,
+		},
+		WindowTracking: WindowTrackingConfig{
+			SampleSeconds:   5,
+			IdleSeconds:     120,
+			RetentionDays:   14,
+			MergeGapMinutes: 5,
+			MinimumMinutes:  5,
+		},
+		Submission: SubmissionConfig{
+			Mode:             submissionImmediate,
+			ReviewTime:       "17:00",
+			DailyTargetHours: 8,
+		},
+		Approvals: ApprovalsConfig{
+			ReminderTime: "15:00",
+		},
+	}
+}
+
+// retrieveTrackerConfig reads tracker.config on top of the defaults, so that
+// settings missing from the file keep their default value. A missing file is
+// created with the defaults to give the user something to edit.
+func retrieveTrackerConfig() {
+	file, err := os.OpenFile(getDataFilePath("tracker.config"), os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		myErrorLogger.Printf("Got error when reading config %s", err.Error())
+		dialog.NewError(err, myWindow).Show()
+		return
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err == nil && fileInfo.Size() == 0 {
+		myLogger.Printf("No config found, writing defaults to tracker.config")
+		saveTrackerConfig()
+		return
+	}
+
+	// a list in the file would be decoded over the default connection
+	trackerConfig.Connections = nil
+	err = json.NewDecoder(file).Decode(&trackerConfig)
+	if err != nil {
+		myErrorLogger.Printf("Got error when reading config %s", err.Error())
+		dialog.NewError(err, myWindow).Show()
+	}
+	if trackerConfig.Comments.WorklogFooter == legacyWorklogFooter {
+		myLogger.Printf("Replacing the public IP in the worklog footer by the work location")
+		trackerConfig.Comments.WorklogFooter = defaultWorklogFooter
+	}
+}
+
+func saveTrackerConfig() {
+	data, err := json.MarshalIndent(&trackerConfig, "", "  ")
+	if err == nil {
+		err = writeFileAtomically(getDataFilePath("tracker.config"), data)
+	}
+	if err != nil {
+		myErrorLogger.Printf("Got error when writing config %s", err.Error())
+		dialog.NewError(err, myWindow).Show()
+		return
+	}
+	myLogger.Printf("wrote %d bytes to config\n", len(data))
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	connectionTypeServer     = "server"
+	connectionTypeCloud      = "cloud"
+	defaultTempoCloudBaseURL = "https://api.tempo.io/4"
+	defaultAccountFieldID    = "customfield_10900"
+)
+
+var jiraConnections []*jiraConnection
+
+// jiraConnection is one configured JIRA with its Tempo. On Server and Data
+// Center Tempo is part of JIRA, on Cloud it has an API and a token of its
+// own. The current user and the work attribute schema differ between
+// connections, so they are kept here.
+type jiraConnection struct {
+	Config ConnectionConfig
+	JIRA   *apiClient
+	Tempo  *apiClient
+
+	userMutex sync.Mutex
+	user      JIRAUser
+
+	workAttributesMutex   sync.Mutex
+	workAttributes        []WorkAttribute
+	workAttributesFetched bool
+	workAttributesLoading bool
+	workAttributesRetry   time.Time
+}
+
+// newJIRAConnection creates the API clients of a connection. With token
+// authentication JIRA Cloud takes e-mail address and API token, Server and
+// Data Center a personal access token.
+func newJIRAConnection(config ConnectionConfig) *jiraConnection {
+	connection := &jiraConnection{Config: config}
+	switch {
+	case config.Auth == authOAuth2 || config.Auth == authOAuth1:
+		connection.JIRA = newAPIClient(config.BaseURL, "")
+		connection.JIRA.Authenticator = newOAuthAuthenticator(config)
+	case config.Type == connectionTypeCloud:
+		connection.JIRA = newAPIClient(config.BaseURL, config.APIToken)
+		connection.JIRA.Username = config.Email
+	default:
+		connection.JIRA = newAPIClient(config.BaseURL, config.Token)
+	}
+	if config.Type == connectionTypeCloud {
+		connection.Tempo = newAPIClient(config.TempoBaseURL, config.TempoToken)
+	} else {
+		connection.Tempo = connection.JIRA
+	}
+	return connection
+}
+
+func (connection *jiraConnection) isCloud() bool {
+	return connection.Config.Type == connectionTypeCloud
+}
+
+// apiPath prefixes path with the JIRA REST API version of the connection, 3
+// on Cloud and 2 on Server.
+func (connection *jiraConnection) apiPath(format string, args ...interface{}) string {
+	version := "2"
+	if connection.isCloud() {
+		version = "3"
+	}
+	return fmt.Sprintf("/rest/api/%s", version) + fmt.Sprintf(format, args...)
+}
+
+// normalizeConnectionConfigs moves the single connection of earlier versions
+// into the list of connections and fills in what a connection leaves out.
+func normalizeConnectionConfigs() {
+	if trackerConfig.Connection != nil {
+		if len(trackerConfig.Connections) == 0 {
+			myLogger.Printf("Moving the connection to the list of connections")
+			trackerConfig.Connections = []ConnectionConfig{*trackerConfig.Connection}
+		}
+		trackerConfig.Connection = nil
+		saveTrackerConfig()
+	}
+	if len(trackerConfig.Connections) == 0 {
+		trackerConfig.Connections = defaultTrackerConfig().Connections
+	}
+	for i := range trackerConfig.Connections {
+		config := &trackerConfig.Connections[i]
+		if config.Name == "" {
+			config.Name = fmt.Sprintf("Connection %d", i+1)
+		}
+		if config.Type == "" {
+			config.Type = connectionTypeServer
+		}
+		if config.TempoBaseURL == "" {
+			config.TempoBaseURL = defaultTempoCloudBaseURL
+		}
+		if config.AccountFieldID == "" {
+			config.AccountFieldID = defaultAccountFieldID
+		}
+		if config.Type == connectionTypeCloud {
+			clearServerDefaults(config)
+		}
+		normalizeOAuthConfig(config)
+	}
+	// accounts were remembered by project key only when there was a single
+	// connection, the first one of the list
+	movedAccounts := false
+	for projectKey, account := range trackerConfig.Accounts.RememberedAccounts {
+		if !strings.Contains(projectKey, "/") {
+			delete(trackerConfig.Accounts.RememberedAccounts, projectKey)
+			trackerConfig.Accounts.RememberedAccounts[trackerConfig.Connections[0].Name+"/"+projectKey] = account
+			movedAccounts = true
+		}
+	}
+	if movedAccounts {
+		saveTrackerConfig()
+	}
+}
+
+// clearServerDefaults removes the worker, internal issue and internal account
+// of the default connection from a cloud connection. They belong to our JIRA
+// Server and stay behind when the type of the written defaults is changed to
+// cloud, where they would book worklogs on the wrong user and issue.
+func clearServerDefaults(config *ConnectionConfig) {
+	defaults := defaultTrackerConfig().Connections[0]
+	if config.Worker == defaults.Worker {
+		config.Worker = ""
+	}
+	if config.InternalIssue == defaults.InternalIssue {
+		config.InternalIssue = ""
+	}
+	if config.InternalAccount == defaults.InternalAccount {
+		config.InternalAccount = ""
+	}
+}
+
+// normalizeOAuthConfig defaults to the authorization server of Atlassian for
+// OAuth 2.0 and to the OAuth endpoints of JIRA itself for OAuth 1.0a. Only
+// with the Atlassian authorization server the requests go through the
+// Atlassian API gateway.
+func normalizeOAuthConfig(config *ConnectionConfig) {
+	oauth := &config.OAuth
+	switch config.Auth {
+	case "":
+		config.Auth = authToken
+	case authOAuth2:
+		if oauth.AuthorizationURL == "" {
+			oauth.AuthorizationURL = atlassianAuthorizationURL
+			oauth.TokenURL = atlassianTokenURL
+			oauth.ResourcesURL = atlassianResourcesURL
+			oauth.APIBaseURL = atlassianAPIBaseURL
+			oauth.Audience = atlassianAudience
+		}
+		if len(oauth.Scopes) == 0 {
+			oauth.Scopes = []string{"read:jira-user", "read:jira-work", "write:jira-work", "offline_access"}
+		}
+	case authOAuth1:
+		servletURL := strings.TrimRight(config.BaseURL, "/") + "/plugins/servlet/oauth"
+		if oauth.RequestTokenURL == "" {
+			oauth.RequestTokenURL = servletURL + "/request-token"
+		}
+		if oauth.AuthorizationURL == "" {
+			oauth.AuthorizationURL = servletURL + "/authorize"
+		}
+		if oauth.AccessTokenURL == "" {
+			oauth.AccessTokenURL = servletURL + "/access-token"
+		}
+	}
+	if oauth.RedirectPort == 0 {
+		oauth.RedirectPort = defaultOAuthRedirectPort
+	}
+}
+
+// setupConnections creates the enabled connections. The first one is the
+// default, it books all tasks that are not bound to another connection.
+func setupConnections() {
+	normalizeConnectionConfigs()
+	var connections []*jiraConnection
+	for _, config := range trackerConfig.Connections {
+		if config.Disabled {
+			myLogger.Printf("Skipping disabled connection %s", config.Name)
+			continue
+		}
+		myLogger.Printf("Using %s connection %s to %s", config.Type, config.Name, config.BaseURL)
+		connections = append(connections, newJIRAConnection(config))
+	}
+	if len(connections) == 0 {
+		myWarningLogger.Printf("All connections are disabled, using %s anyway", trackerConfig.Connections[0].Name)
+		connections = append(connections, newJIRAConnection(trackerConfig.Connections[0]))
+	}
+	jiraConnections = connections
+}
+
+func getDefaultConnection() *jiraConnection {
+	return jiraConnections[0]
+}
+
+func hasMultipleConnections() bool {
+	return len(jiraConnections) > 1
+}
+
+// getConnectionName resolves the empty connection name of tasks on the
+// default connection.
+func getConnectionName(name string) string {
+	if name == "" {
+		return getDefaultConnection().Config.Name
+	}
+	return name
+}
+
+// getConnection returns the connection called name, or the default
+// connection for tasks of a connection that was removed or disabled since.
+func getConnection(name string) *jiraConnection {
+	if name == "" {
+		return getDefaultConnection()
+	}
+	for _, connection := range jiraConnections {
+		if connection.Config.Name == name {
+			return connection
+		}
+	}
+	myWarningLogger.Printf("Connection %s is not configured or disabled, using %s", name, getDefaultConnection().Config.Name)
+	return getDefaultConnection()
+}
+
+// bindTaskToConnection routes all later requests about task to the named
+// connection, or to the default connection when name is empty. Like the task
+// attributes, the binding is written to work.history with the next worklog.
+func bindTaskToConnection(task string, name string) {
+	task = getElementFromStringWithColon(task, 0)
+	workLogHistoryMutex.Lock()
+	defer workLogHistoryMutex.Unlock()
+	if name == "" {
+		delete(worklogHistory.TaskConnections, task)
+		return
+	}
+	if worklogHistory.TaskConnections == nil {
+		worklogHistory.TaskConnections = make(map[string]string)
+	}
+	worklogHistory.TaskConnections[task] = name
+}
+
+func getTaskConnectionName(task string) string {
+	workLogHistoryMutex.Lock()
+	defer workLogHistoryMutex.Unlock()
+	return worklogHistory.TaskConnections[getElementFromStringWithColon(task, 0)]
+}
+
+func getConnectionForTask(task string) *jiraConnection {
+	return getConnection(getTaskConnectionName(task))
+}
+
+// getWorker returns the Tempo worker of the worklogs, the user key on
+// Server and the account ID on Cloud.
+func getWorker(connection *jiraConnection) string {
+	if connection.Config.Worker != "" {
+		return connection.Config.Worker
+	}
+	me, err := getMyJIRAUser(connection)
+	if err != nil {
+		myErrorLogger.Printf("Got error when getting the current user of %s %s", connection.Config.Name, err.Error())
+	}
+	if connection.isCloud() {
+		return me.AccountID
+	}
+	return me.Key
+}
+
+// searchAllConnections runs the issue search on all connections at once and
+// labels every issue with the connection it was found in. It only fails when
+// all connections failed, so that one unreachable JIRA does not hide the
+// results of the others.
+func searchAllConnections(ctx context.Context, q string) ([]JIRAIssue, error) {
+	type connectionResult struct {
+		issues []JIRAIssue
+		err    error
+	}
+	results := make([]connectionResult, len(jiraConnections))
+	var wait sync.WaitGroup
+	for i, connection := range jiraConnections {
+		wait.Add(1)
+		go func(i int, connection *jiraConnection) {
+			defer wait.Done()
+			issues, err := searchJIRAIsssue(ctx, connection, q)
+			results[i] = connectionResult{issues: labelIssues(issues, connection), err: err}
+		}(i, connection)
+	}
+	wait.Wait()
+
+	var result []JIRAIssue = make([]JIRAIssue, 0)
+	var firstErr error
+	failed := 0
+	for i, connectionResult := range results {
+		if connectionResult.err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = connectionResult.err
+			}
+			if !errors.Is(connectionResult.err, context.Canceled) {
+				myWarningLogger.Printf("Search on %s failed: %s", jiraConnections[i].Config.Name, connectionResult.err.Error())
+			}
+			continue
+		}
+		result = append(result, connectionResult.issues...)
+	}
+	if failed == len(results) {
+		return result, firstErr
+	}
+	return result, nil
+}
+
+func labelIssues(issues []JIRAIssue, connection *jiraConnection) []JIRAIssue {
+	for i := range issues {
+		issues[i].Connection = connection.Config.Name
+	}
+	return issues
+}
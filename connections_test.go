@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func useJIRAConnections(t *testing.T, connections ...*jiraConnection) {
+	previous := jiraConnections
+	jiraConnections = connections
+	t.Cleanup(func() { jiraConnections = previous })
+}
+
+func TestClearServerDefaults(t *testing.T) {
+	defaults := defaultTrackerConfig().Connections[0]
+	tests := []struct {
+		name   string
+		config ConnectionConfig
+		want   ConnectionConfig
+	}{
+		{
+			name:   "written defaults changed to cloud",
+			config: ConnectionConfig{Type: connectionTypeCloud, Worker: defaults.Worker, InternalIssue: defaults.InternalIssue, InternalAccount: defaults.InternalAccount},
+			want:   ConnectionConfig{Type: connectionTypeCloud},
+		},
+		{
+			name:   "own values are kept",
+			config: ConnectionConfig{Type: connectionTypeCloud, Worker: "5b10a2844c20165700ede21g", InternalIssue: "ADM-1", InternalAccount: defaults.InternalAccount},
+			want:   ConnectionConfig{Type: connectionTypeCloud, Worker: "5b10a2844c20165700ede21g", InternalIssue: "ADM-1"},
+		},
+	}
+	for _, test := range tests {
+		config := test.config
+		clearServerDefaults(&config)
+		if config.Worker != test.want.Worker || config.InternalIssue != test.want.InternalIssue || config.InternalAccount != test.want.InternalAccount {
+			t.Errorf("%s: got %s/%s/%s, want %s/%s/%s", test.name, config.Worker, config.InternalIssue, config.InternalAccount, test.want.Worker, test.want.InternalIssue, test.want.InternalAccount)
+		}
+	}
+}
+
+func TestGetProjectAndAccountForIssuePath(t *testing.T) {
+	useWorkLogHistory(t, WorkLogHistoryRoot{})
+	var paths []string
+	jira := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		w.Write([]byte(`{"key":"ABC-1","fields":{"project":{"key":"ABC"}}}`))
+	}))
+	defer jira.Close()
+	connection := newJIRAConnection(ConnectionConfig{Name: "server", BaseURL: jira.URL})
+	connection.JIRA.Trace = nil
+	useJIRAConnections(t, connection)
+
+	tests := []struct {
+		issue string
+		want  string
+	}{
+		{issue: "ABC-1", want: "/rest/api/2/issue/ABC-1"},
+		{issue: "ABC/1?x", want: "/rest/api/2/issue/ABC%2F1%3Fx"},
+	}
+	for _, test := range tests {
+		paths = nil
+		getProjectAndAccountForIssue(connection, test.issue)
+		if len(paths) != 1 || paths[0] != test.want {
+			t.Errorf("getProjectAndAccountForIssue(%q) requested %v, want %s", test.issue, paths, test.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+const credentialsFileName = "credentials"
+
+var credentialsMutex sync.Mutex
+
+// StoredCredentials are what an authorization flow leaves behind for a
+// connection: the OAuth 2.0 access and refresh tokens with the cloud ID of
+// the site, or the OAuth 1.0a access token and its secret.
+type StoredCredentials struct {
+	AccessToken  string    `json:"accessToken"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	TokenSecret  string    `json:"tokenSecret,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+	CloudID      string    `json:"cloudId,omitempty"`
+}
+
+// readCredentialsFile returns the credentials of all connections by name.
+// The file is protected by protectData, on Windows with the user's DPAPI
+// key, elsewhere only by its permissions.
+func readCredentialsFile() (map[string]StoredCredentials, error) {
+	credentials := make(map[string]StoredCredentials)
+	data, err := os.ReadFile(getDataFilePath(credentialsFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return credentials, nil
+	}
+	if err != nil {
+		return credentials, err
+	}
+	data, err = unprotectData(data)
+	if err != nil {
+		return credentials, err
+	}
+	err = json.Unmarshal(data, &credentials)
+	return credentials, err
+}
+
+func loadCredentials(name string) (StoredCredentials, bool) {
+	credentialsMutex.Lock()
+	defer credentialsMutex.Unlock()
+
+	credentials, err := readCredentialsFile()
+	if err != nil {
+		myErrorLogger.Printf("Got error when reading credentials %s", err.Error())
+	}
+	stored, found := credentials[name]
+	return stored, found
+}
+
+// saveCredentials stores the credentials of a connection, or removes them
+// when stored is empty.
+func saveCredentials(name string, stored StoredCredentials) error {
+	credentialsMutex.Lock()
+	defer credentialsMutex.Unlock()
+
+	credentials, err := readCredentialsFile()
+	if err != nil {
+		myWarningLogger.Printf("Replacing unreadable credentials: %s", err.Error())
+	}
+	if stored == (StoredCredentials{}) {
+		delete(credentials, name)
+	} else {
+		credentials[name] = stored
+	}
+	data, err := json.Marshal(credentials)
+	if err != nil {
+		return err
+	}
+	data, err = protectData(data)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(getDataFilePath(credentialsFileName), data)
+}
@@ -0,0 +1,55 @@
+//go:build windows
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const cryptProtectUIForbidden = 0x1
+
+var (
+	crypt32            = syscall.MustLoadDLL("crypt32.dll")
+	cryptProtectData   = crypt32.MustFindProc("CryptProtectData")
+	cryptUnprotectData = crypt32.MustFindProc("CryptUnprotectData")
+	localFree          = kernel32.MustFindProc("LocalFree")
+)
+
+type dataBlob struct {
+	size uint32
+	data *byte
+}
+
+func newDataBlob(data []byte) *dataBlob {
+	if len(data) == 0 {
+		return &dataBlob{}
+	}
+	return &dataBlob{size: uint32(len(data)), data: &data[0]}
+}
+
+// takeBytes copies the data of a blob allocated by Windows and frees it.
+func (blob *dataBlob) takeBytes() []byte {
+	defer localFree.Call(uintptr(unsafe.Pointer(blob.data)))
+	return append([]byte(nil), unsafe.Slice(blob.data, blob.size)...)
+}
+
+// protectData encrypts data with DPAPI, so that only the current Windows
+// user can read it.
+func protectData(data []byte) ([]byte, error) {
+	var protected dataBlob
+	r, _, err := cryptProtectData.Call(uintptr(unsafe.Pointer(newDataBlob(data))), 0, 0, 0, 0, cryptProtectUIForbidden, uintptr(unsafe.Pointer(&protected)))
+	if r == 0 {
+		return nil, err
+	}
+	return protected.takeBytes(), nil
+}
+
+func unprotectData(data []byte) ([]byte, error) {
+	var unprotected dataBlob
+	r, _, err := cryptUnprotectData.Call(uintptr(unsafe.Pointer(newDataBlob(data))), 0, 0, 0, 0, cryptProtectUIForbidden, uintptr(unsafe.Pointer(&unprotected)))
+	if r == 0 {
+		return nil, err
+	}
+	return unprotected.takeBytes(), nil
+}
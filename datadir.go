@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const dataDirectoryEnv = "TIMETRACKER_DATA_DIR"
+
+var (
+	dataDirectory string
+	dataFileNames = []string{"tracker.log", "work.log", workLogHistoryFileName, "tracker.config", "issues.cache", "accounts.cache"}
+)
+
+// getDefaultDataDirectory follows the XDG base directory specification and
+// uses %APPDATA% on Windows.
+func getDefaultDataDirectory() (string, error) {
+	if runtime.GOOS == "windows" {
+		appData := os.Getenv("APPDATA")
+		if appData == "" {
+			return "", errors.New("%APPDATA% is not set")
+		}
+		return filepath.Join(appData, "timetracker"), nil
+	}
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return filepath.Join(dataHome, "timetracker"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "timetracker"), nil
+}
+
+// setupDataDirectory chooses the data directory, in order of precedence from
+// the flag, the environment and the platform default, and creates it.
+func setupDataDirectory(flagValue string) error {
+	directory := flagValue
+	if directory == "" {
+		directory = os.Getenv(dataDirectoryEnv)
+	}
+	if directory == "" {
+		defaultDirectory, err := getDefaultDataDirectory()
+		if err != nil {
+			return err
+		}
+		directory = defaultDirectory
+	}
+	directory, err := filepath.Abs(directory)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(directory, 0755)
+	if err != nil {
+		return err
+	}
+	dataDirectory = directory
+	return nil
+}
+
+func getDataFilePath(name string) string {
+	return filepath.Join(dataDirectory, name)
+}
+
+// moveFile renames source to target, copying it when both are on different
+// volumes.
+func moveFile(source string, target string) error {
+	if err := os.Rename(source, target); err == nil {
+		return nil
+	}
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	targetFile, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		sourceFile.Close()
+		return err
+	}
+	_, err = io.Copy(targetFile, sourceFile)
+	sourceFile.Close()
+	if closeErr := targetFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(target)
+		return err
+	}
+	return os.Remove(source)
+}
+
+// migrateDataFiles moves the files that earlier versions kept in the working
+// directory, or next to the executable, into the data directory. Files that
+// already exist in the data directory are never overwritten. It runs before
+// the log is opened, so it returns what it did for logging later.
+func migrateDataFiles() []string {
+	var messages []string
+	var oldDirectories []string
+	if workingDirectory, err := os.Getwd(); err == nil {
+		oldDirectories = append(oldDirectories, workingDirectory)
+	}
+	if executable, err := os.Executable(); err == nil {
+		oldDirectories = append(oldDirectories, filepath.Dir(executable))
+	}
+
+	names := append([]string{}, dataFileNames...)
+	for number := 1; number <= workLogHistoryBackups; number++ {
+		names = append(names, fmt.Sprintf("%s.%d", workLogHistoryFileName, number))
+	}
+
+	for _, oldDirectory := range oldDirectories {
+		if absolute, err := filepath.Abs(oldDirectory); err != nil || absolute == dataDirectory {
+			continue
+		}
+		for _, name := range names {
+			source := filepath.Join(oldDirectory, name)
+			target := getDataFilePath(name)
+			if _, err := os.Stat(source); err != nil {
+				continue
+			}
+			if _, err := os.Stat(target); err == nil {
+				continue
+			}
+			if err := moveFile(source, target); err != nil {
+				messages = append(messages, fmt.Sprintf("Could not move %s to %s: %s", source, target, err.Error()))
+			} else {
+				messages = append(messages, fmt.Sprintf("Moved %s to %s", source, target))
+			}
+		}
+	}
+	return messages
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2/dialog"
+)
+
+const (
+	submissionImmediate = "immediate"
+	submissionDraft     = "draft"
+
+	draftsFileName = "drafts"
+)
+
+// DraftWorkLog is a worklog that is kept locally in draft mode until it is
+// submitted from the review. Connection is the connection the work was
+// recorded on, which it is booked on even if the task is bound elsewhere by
+// then, and Location the public IP it was recorded from, which sets its work
+// location.
+type DraftWorkLog struct {
+	ID          int64     `json:"id"`
+	Task        string    `json:"task"`
+	Connection  string    `json:"connection,omitempty"`
+	TaskName    string    `json:"taskName"`
+	Account     string    `json:"account"`
+	AccountName string    `json:"accountName"`
+	Comment     string    `json:"comment"`
+	Activity    string    `json:"activity"`
+	Location    string    `json:"location,omitempty"`
+	Start       time.Time `json:"start"`
+	Seconds     int       `json:"seconds"`
+}
+
+func (draft DraftWorkLog) duration() time.Duration {
+	return time.Duration(draft.Seconds) * time.Second
+}
+
+func (draft DraftWorkLog) end() time.Time {
+	return draft.Start.Add(draft.duration())
+}
+
+var (
+	drafts      []DraftWorkLog
+	lastDraftID int64
+	// submittingDrafts holds the IDs of the drafts being booked, which are
+	// neither submitted a second time nor changed meanwhile
+	submittingDrafts = make(map[int64]bool)
+	draftsMutex      sync.Mutex
+)
+
+func addDraft(task string, connectionName string, taskName string, account string, accountName string, comment string, activity string, location string, started time.Time, duration time.Duration) {
+	draftsMutex.Lock()
+	lastDraftID++
+	drafts = append(drafts, DraftWorkLog{
+		ID:          lastDraftID,
+		Task:        task,
+		Connection:  connectionName,
+		TaskName:    taskName,
+		Account:     account,
+		AccountName: accountName,
+		Comment:     comment,
+		Activity:    activity,
+		Location:    location,
+		Start:       started,
+		Seconds:     int(duration.Seconds()),
+	})
+	saveDrafts()
+	draftsMutex.Unlock()
+	myLogger.Printf("Keeping worklog %s %s as a draft", task, duration.String())
+	onDraftsChanged()
+}
+
+// getDrafts returns a copy of the drafts ordered by their start.
+func getDrafts() []DraftWorkLog {
+	draftsMutex.Lock()
+	defer draftsMutex.Unlock()
+	sorted := append([]DraftWorkLog(nil), drafts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+	return sorted
+}
+
+// changeDrafts runs change on the drafts and saves them when it succeeds.
+func changeDrafts(change func() error) error {
+	draftsMutex.Lock()
+	err := change()
+	if err == nil {
+		saveDrafts()
+	}
+	draftsMutex.Unlock()
+	if err == nil {
+		onDraftsChanged()
+	}
+	return err
+}
+
+// findDraft returns the index of the draft with id, draftsMutex must be held.
+func findDraft(id int64) (int, error) {
+	for i, draft := range drafts {
+		if draft.ID == id {
+			return i, nil
+		}
+	}
+	return -1, errors.New("the draft was submitted or removed meanwhile")
+}
+
+// findEditableDraft is findDraft for a draft that is about to be changed,
+// which it cannot while being submitted.
+func findEditableDraft(id int64) (int, error) {
+	if submittingDrafts[id] {
+		return -1, errors.New("the draft is being submitted")
+	}
+	return findDraft(id)
+}
+
+func updateDraft(updated DraftWorkLog) error {
+	return changeDrafts(func() error {
+		i, err := findEditableDraft(updated.ID)
+		if err == nil {
+			drafts[i] = updated
+		}
+		return err
+	})
+}
+
+func removeDraft(id int64) error {
+	return changeDrafts(func() error {
+		i, err := findDraft(id)
+		if err == nil {
+			drafts = append(drafts[:i], drafts[i+1:]...)
+		}
+		return err
+	})
+}
+
+// splitDraft cuts a draft in two, the first part lasting first and the
+// second starting where the first ends.
+func splitDraft(id int64, first time.Duration) error {
+	return changeDrafts(func() error {
+		i, err := findEditableDraft(id)
+		if err != nil {
+			return err
+		}
+		if first <= 0 || first >= drafts[i].duration() {
+			return fmt.Errorf("the first part must be shorter than %s", drafts[i].duration().String())
+		}
+		second := drafts[i]
+		lastDraftID++
+		second.ID = lastDraftID
+		second.Start = drafts[i].Start.Add(first)
+		second.Seconds = drafts[i].Seconds - int(first.Seconds())
+		drafts[i].Seconds = int(first.Seconds())
+		drafts = append(drafts, second)
+		return nil
+	})
+}
+
+// mergeDrafts joins the draft with nextID into the draft with id. Both must
+// be of the same task on the same connection; their durations add up and
+// different comments are kept both.
+func mergeDrafts(id int64, nextID int64) error {
+	return changeDrafts(func() error {
+		i, err := findEditableDraft(id)
+		if err != nil {
+			return err
+		}
+		j, err := findEditableDraft(nextID)
+		if err != nil {
+			return err
+		}
+		if drafts[i].Task != drafts[j].Task || drafts[i].Connection != drafts[j].Connection {
+			return fmt.Errorf("%s and %s are different tasks", drafts[i].Task, drafts[j].Task)
+		}
+		if drafts[j].Start.Before(drafts[i].Start) {
+			drafts[i].Start = drafts[j].Start
+		}
+		drafts[i].Seconds += drafts[j].Seconds
+		if drafts[i].Account == "" {
+			drafts[i].Account = drafts[j].Account
+			drafts[i].AccountName = drafts[j].AccountName
+		}
+		if drafts[i].Comment == "" {
+			drafts[i].Comment = drafts[j].Comment
+		} else if drafts[j].Comment != "" && !strings.Contains(drafts[i].Comment, drafts[j].Comment) {
+			drafts[i].Comment += "; " + drafts[j].Comment
+		}
+		drafts = append(drafts[:j], drafts[j+1:]...)
+		return nil
+	})
+}
+
+// submitDrafts books the drafts in Tempo one by one. Drafts that were booked
+// are removed, the others stay to be submitted again. Drafts that are already
+// being submitted, or were removed meanwhile, are skipped.
+func submitDrafts(toSubmit []DraftWorkLog) error {
+	var claimed []DraftWorkLog
+	draftsMutex.Lock()
+	for _, draft := range toSubmit {
+		if i, err := findEditableDraft(draft.ID); err == nil {
+			submittingDrafts[draft.ID] = true
+			claimed = append(claimed, drafts[i])
+		}
+	}
+	draftsMutex.Unlock()
+
+	var errs []error
+	for _, draft := range claimed {
+		// drafts of earlier versions did not keep where they were recorded
+		location := draft.Location
+		if location == "" {
+			location = getCurrentLocation()
+		}
+		err := submitWorkLog(draft.Task, draft.Connection, draft.TaskName, draft.Account, draft.AccountName, draft.Comment, draft.Activity, location, draft.Start, draft.duration())
+		if err == nil {
+			removeDraft(draft.ID)
+		} else {
+			errs = append(errs, fmt.Errorf("%s at %s: %w", draft.Task, draft.Start.Format("02.01. 15:04"), err))
+		}
+		draftsMutex.Lock()
+		delete(submittingDrafts, draft.ID)
+		draftsMutex.Unlock()
+	}
+	myLogger.Printf("Submitted %d of %d drafts", len(claimed)-len(errs), len(claimed))
+	return errors.Join(errs...)
+}
+
+// saveDrafts writes the drafts to their file, draftsMutex must be held.
+func saveDrafts() {
+	data, err := json.Marshal(drafts)
+	if err == nil {
+		err = writeFileAtomically(getDataFilePath(draftsFileName), data)
+	}
+	if err != nil {
+		myErrorLogger.Printf("Got error when saving drafts %s", err.Error())
+		dialog.NewError(err, myWindow).Show()
+	}
+}
+
+func retrieveDrafts() {
+	data, err := os.ReadFile(getDataFilePath(draftsFileName))
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(bytes.TrimSpace(data)) == 0) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &drafts)
+	}
+	if err != nil {
+		myErrorLogger.Printf("Got error when reading drafts %s", err.Error())
+		return
+	}
+	for i, draft := range drafts {
+		lastDraftID = max(lastDraftID, draft.ID)
+		// drafts of earlier versions go where their task is bound
+		if draft.Connection == "" {
+			drafts[i].Connection = getTaskConnectionName(draft.Task)
+		}
+	}
+	myLogger.Printf("Read %d drafts", len(drafts))
+}
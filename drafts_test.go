@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDraftsBeingSubmitted(t *testing.T) {
+	useTempDataDirectory(t)
+	defer func(previous []DraftWorkLog) { drafts = previous }(drafts)
+	drafts = nil
+
+	start := time.Date(2024, 3, 5, 9, 0, 0, 0, time.Local)
+	addDraft("ABC-1", "Work", "Name", "ACC", "Account", "", "", "10.0.0.1", start, time.Hour)
+	addDraft("ABC-1", "Other", "Name", "ACC", "Account", "", "", "10.0.0.1", start.Add(time.Hour), time.Hour)
+	addDraft("ABC-1", "Work", "Name", "ACC", "Account", "", "", "10.0.0.1", start.Add(2*time.Hour), time.Hour)
+	all := getDrafts()
+
+	if err := mergeDrafts(all[0].ID, all[1].ID); err == nil {
+		t.Error("merged drafts of different connections")
+	}
+
+	draftsMutex.Lock()
+	submittingDrafts[all[0].ID] = true
+	draftsMutex.Unlock()
+	defer func() {
+		draftsMutex.Lock()
+		delete(submittingDrafts, all[0].ID)
+		draftsMutex.Unlock()
+	}()
+
+	tests := []struct {
+		name   string
+		change func() error
+	}{
+		{name: "update", change: func() error { return updateDraft(all[0]) }},
+		{name: "split", change: func() error { return splitDraft(all[0].ID, 30*time.Minute) }},
+		{name: "merge", change: func() error { return mergeDrafts(all[0].ID, all[2].ID) }},
+		{name: "merge into", change: func() error { return mergeDrafts(all[2].ID, all[0].ID) }},
+	}
+	for _, test := range tests {
+		if err := test.change(); err == nil {
+			t.Errorf("%s changed a draft being submitted", test.name)
+		}
+	}
+	if got := len(getDrafts()); got != 3 {
+		t.Errorf("%d drafts left, want 3", got)
+	}
+	if err := splitDraft(all[2].ID, 30*time.Minute); err != nil {
+		t.Errorf("splitDraft() of a draft not being submitted error %v", err)
+	}
+}
+
+func TestDraftLocation(t *testing.T) {
+	useTempDataDirectory(t)
+	defer func(previous []DraftWorkLog) { drafts = previous }(drafts)
+	drafts = nil
+
+	start := time.Date(2024, 3, 5, 9, 0, 0, 0, time.Local)
+	addDraft("ABC-1", "Work", "Name", "ACC", "Account", "", "", "10.0.0.1", start, time.Hour)
+	if err := splitDraft(getDrafts()[0].ID, 30*time.Minute); err != nil {
+		t.Fatalf("splitDraft() error %v", err)
+	}
+
+	drafts = nil
+	retrieveDrafts()
+	all := getDrafts()
+	if len(all) != 2 {
+		t.Fatalf("%d drafts retrieved, want 2", len(all))
+	}
+	for _, draft := range all {
+		if draft.Location != "10.0.0.1" {
+			t.Errorf("draft at %s has location %q, want the location it was recorded at", draft.Start.Format("15:04"), draft.Location)
+		}
+	}
+}
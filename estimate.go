@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	remainingEstimateAuto  = "auto"
+	remainingEstimateLeave = "leave"
+	remainingEstimateNew   = "new"
+)
+
+var (
+	remainingEstimateModeNames = map[string]string{
+		remainingEstimateAuto:  "Reduce automatically",
+		remainingEstimateLeave: "Leave unchanged",
+		remainingEstimateNew:   "Set to",
+	}
+
+	currentTimeTracking      IssueTimeTracking
+	currentTimeTrackingTask  string
+	estimateExceededNotified bool
+	newRemainingEstimates    = make(map[string]time.Duration)
+	estimateMutex            sync.Mutex
+)
+
+type IssueTimeTracking struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Timetracking struct {
+			OriginalEstimate         string `json:"originalEstimate"`
+			RemainingEstimate        string `json:"remainingEstimate"`
+			TimeSpent                string `json:"timeSpent"`
+			OriginalEstimateSeconds  int    `json:"originalEstimateSeconds"`
+			RemainingEstimateSeconds int    `json:"remainingEstimateSeconds"`
+			TimeSpentSeconds         int    `json:"timeSpentSeconds"`
+		} `json:"timetracking"`
+	} `json:"fields"`
+}
+
+// remainingEstimateForm lets the user choose, per issue, what a worklog does
+// to the issue's remaining estimate.
+type remainingEstimateForm struct {
+	modeSelect    *widget.Select
+	estimateEntry *widget.Entry
+	items         []*widget.FormItem
+}
+
+func getTimeTrackingForIssue(issue string) (IssueTimeTracking, error) {
+	var timeTracking IssueTimeTracking
+	issue = getElementFromStringWithColon(issue, 0)
+	connection := getConnectionForTask(issue)
+	path := connection.apiPath("/issue/%s?fields=timetracking", url.PathEscape(issue))
+	myLogger.Printf("Requesting time tracking for issue from JIRA %s", path)
+
+	err := connection.JIRA.Do(context.Background(), "GET", path, nil, &timeTracking)
+	return timeTracking, err
+}
+
+// parseJIRADuration parses durations the way JIRA writes them, e.g.
+// "1w 2d 3h 30m", with the configured working hours per day and days per
+// week.
+func parseJIRADuration(text string) (time.Duration, error) {
+	var duration time.Duration
+	fields := strings.Fields(strings.ToLower(text))
+	if len(fields) == 0 {
+		return 0, errors.New("empty duration")
+	}
+	day := time.Duration(trackerConfig.Estimates.HoursPerDay) * time.Hour
+	week := time.Duration(trackerConfig.Estimates.DaysPerWeek) * day
+	for _, field := range fields {
+		if len(field) < 2 {
+			return 0, fmt.Errorf("invalid duration %q", text)
+		}
+		value, err := strconv.ParseFloat(field[:len(field)-1], 64)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("invalid duration %q", text)
+		}
+		var unit time.Duration
+		switch field[len(field)-1] {
+		case 'w':
+			unit = week
+		case 'd':
+			unit = day
+		case 'h':
+			unit = time.Hour
+		case 'm':
+			unit = time.Minute
+		default:
+			return 0, fmt.Errorf("invalid duration %q", text)
+		}
+		duration += time.Duration(value * float64(unit))
+	}
+	return duration, nil
+}
+
+func getRemainingEstimateMode(issue string) string {
+	if mode, found := trackerConfig.Estimates.IssueModes[issue]; found {
+		return mode
+	}
+	return trackerConfig.Estimates.DefaultMode
+}
+
+func newRemainingEstimateForm() *remainingEstimateForm {
+	form := &remainingEstimateForm{}
+	form.estimateEntry = widget.NewEntry()
+	form.estimateEntry.SetPlaceHolder("e.g. 1d 4h")
+	form.estimateEntry.Disable()
+	form.modeSelect = widget.NewSelect([]string{
+		remainingEstimateModeNames[remainingEstimateAuto],
+		remainingEstimateModeNames[remainingEstimateLeave],
+		remainingEstimateModeNames[remainingEstimateNew],
+	}, func(name string) {
+		if name == remainingEstimateModeNames[remainingEstimateNew] {
+			form.estimateEntry.Enable()
+		} else {
+			form.estimateEntry.Disable()
+		}
+	})
+	form.modeSelect.SetSelected(remainingEstimateModeNames[trackerConfig.Estimates.DefaultMode])
+	form.items = []*widget.FormItem{
+		widget.NewFormItem("Remaining estimate", form.modeSelect),
+		widget.NewFormItem("New remaining estimate", form.estimateEntry),
+	}
+	return form
+}
+
+func (form *remainingEstimateForm) FormItems() []*widget.FormItem {
+	return form.items
+}
+
+func (form *remainingEstimateForm) SetIssue(issue string) {
+	form.modeSelect.SetSelected(remainingEstimateModeNames[getRemainingEstimateMode(issue)])
+	form.estimateEntry.SetText("")
+}
+
+// Apply remembers the chosen mode for issue. A new remaining estimate is
+// only used for the next worklog of the issue.
+func (form *remainingEstimateForm) Apply(issue string) error {
+	issue = getElementFromStringWithColon(issue, 0)
+	var mode string
+	for key, name := range remainingEstimateModeNames {
+		if name == form.modeSelect.Selected {
+			mode = key
+		}
+	}
+	if mode == "" {
+		return nil
+	}
+
+	estimateMutex.Lock()
+	defer estimateMutex.Unlock()
+	if mode == remainingEstimateNew {
+		newEstimate, err := parseJIRADuration(form.estimateEntry.Text)
+		if err != nil {
+			return err
+		}
+		newRemainingEstimates[issue] = newEstimate
+	} else {
+		delete(newRemainingEstimates, issue)
+		if mode != getRemainingEstimateMode(issue) {
+			if trackerConfig.Estimates.IssueModes == nil {
+				trackerConfig.Estimates.IssueModes = make(map[string]string)
+			}
+			trackerConfig.Estimates.IssueModes[issue] = mode
+			saveTrackerConfig()
+		}
+	}
+	return nil
+}
+
+// getRemainingEstimateForWorklog returns what to send as the remaining
+// estimate of a worklog on issue, in seconds. nil leaves it to Tempo.
+func getRemainingEstimateForWorklog(issue string, timeSpent time.Duration) interface{} {
+	estimateMutex.Lock()
+	newEstimate, found := newRemainingEstimates[issue]
+	delete(newRemainingEstimates, issue)
+	estimateMutex.Unlock()
+	if found {
+		return int(newEstimate.Seconds())
+	}
+
+	mode := getRemainingEstimateMode(issue)
+	timeTracking, err := getTimeTrackingForIssue(issue)
+	if err != nil {
+		myWarningLogger.Printf("Could not get the remaining estimate of %s: %s", issue, err.Error())
+		return nil
+	}
+	remaining := timeTracking.Fields.Timetracking.RemainingEstimateSeconds
+	if mode == remainingEstimateLeave {
+		return remaining
+	}
+	remaining -= int(timeSpent.Seconds())
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining
+}
+
+// loadEstimateForTask fetches the estimates of task for display next to the
+// running timer.
+func loadEstimateForTask(task string) {
+	timeTracking, err := getTimeTrackingForIssue(task)
+	if err != nil {
+		timeTracking = IssueTimeTracking{}
+	}
+	estimateMutex.Lock()
+	defer estimateMutex.Unlock()
+	currentTimeTracking = timeTracking
+	currentTimeTrackingTask = task
+	estimateExceededNotified = false
+}
+
+func formatEstimateShort(seconds int) string {
+	return (time.Duration(seconds) * time.Second).Truncate(time.Minute).String()
+}
+
+// formatDurationWithEstimate appends the issue estimates to the running
+// duration and warns once when the duration runs past the remaining
+// estimate.
+func formatDurationWithEstimate(task string, elapsed time.Duration) string {
+	estimateMutex.Lock()
+	timeTracking := currentTimeTracking
+	loadedForTask := currentTimeTrackingTask == task
+	alreadyNotified := estimateExceededNotified
+	estimateMutex.Unlock()
+
+	display := formatDurationWithBooking(elapsed)
+	tracking := timeTracking.Fields.Timetracking
+	if !loadedForTask || (tracking.OriginalEstimateSeconds == 0 && tracking.RemainingEstimateSeconds == 0) {
+		return display
+	}
+	display = fmt.Sprintf("%s (est. %s, left %s)", display, formatEstimateShort(tracking.OriginalEstimateSeconds), formatEstimateShort(tracking.RemainingEstimateSeconds))
+
+	if int(elapsed.Seconds()) > tracking.RemainingEstimateSeconds && !alreadyNotified {
+		estimateMutex.Lock()
+		estimateExceededNotified = true
+		estimateMutex.Unlock()
+		myLogger.Printf("Logged time on %s exceeds the remaining estimate", task)
+		dialog.NewInformation("Estimate exceeded", fmt.Sprintf("%s had %s left, you have spent %s on it now", task, formatEstimateShort(tracking.RemainingEstimateSeconds), elapsed.Truncate(time.Minute).String()), myWindow).Show()
+	}
+	return display
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gen2brain/beeep"
+)
+
+const (
+	defaultGitKeyPattern = `\b[A-Z][A-Z0-9_]+-[0-9]+\b`
+
+	// maxGitSuggestions is how many issues of recent checkouts and commits
+	// each repository remembers for the suggestions
+	maxGitSuggestions = 10
+)
+
+var (
+	gitWatchers   []*gitWatcher
+	gitKeyPattern = regexp.MustCompile(defaultGitKeyPattern)
+)
+
+// gitWatcher follows the reflog of HEAD of a repository, which records
+// every checkout and commit with its time and message. offset is how far
+// the reflog has been read, so that every entry is handled once.
+type gitWatcher struct {
+	Path   string
+	gitDir string
+	offset int64
+
+	mutex       sync.Mutex
+	suggestions []gitSuggestion
+}
+
+type gitSuggestion struct {
+	Key     string
+	Summary string
+}
+
+type gitReflogEntry struct {
+	Time    time.Time
+	Message string
+}
+
+// resolveGitDir finds the git directory of a working copy; in worktrees and
+// submodules .git is a file pointing to it.
+func resolveGitDir(path string) (string, error) {
+	dotGit := filepath.Join(path, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return dotGit, nil
+	}
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return "", err
+	}
+	gitDir, found := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !found {
+		return "", fmt.Errorf("%s is not a git directory", dotGit)
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(path, gitDir)
+	}
+	return gitDir, nil
+}
+
+// getBranch returns the checked out branch, or "" for a detached HEAD.
+func (watcher *gitWatcher) getBranch() string {
+	data, err := os.ReadFile(filepath.Join(watcher.gitDir, "HEAD"))
+	if err != nil {
+		myWarningLogger.Printf("Could not read HEAD of %s: %s", watcher.Path, err.Error())
+		return ""
+	}
+	branch, found := strings.CutPrefix(strings.TrimSpace(string(data)), "ref: refs/heads/")
+	if !found {
+		return ""
+	}
+	return branch
+}
+
+// readReflog returns the complete entries of the reflog of HEAD written
+// since the last read. A reflog shorter than before was expired or deleted
+// and is read again from the start.
+func (watcher *gitWatcher) readReflog() ([]gitReflogEntry, error) {
+	file, err := os.Open(filepath.Join(watcher.gitDir, "logs", "HEAD"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < watcher.offset {
+		watcher.offset = 0
+	}
+	if info.Size() == watcher.offset {
+		return nil, nil
+	}
+	_, err = file.Seek(watcher.offset, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	// git may be writing the last line right now
+	complete := bytes.LastIndexByte(data, '\n') + 1
+	watcher.offset += int64(complete)
+
+	var entries []gitReflogEntry
+	for _, line := range strings.Split(string(data[:complete]), "\n") {
+		if entry, ok := parseGitReflogLine(line); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// parseGitReflogLine reads "<old> <new> <name> <<email>> <unix time> <zone>",
+// followed by a tab and the message.
+func parseGitReflogLine(line string) (gitReflogEntry, bool) {
+	header, message, found := strings.Cut(strings.TrimRight(line, "\r"), "\t")
+	if !found {
+		return gitReflogEntry{}, false
+	}
+	fields := strings.Fields(header)
+	if len(fields) < 2 {
+		return gitReflogEntry{}, false
+	}
+	seconds, err := strconv.ParseInt(fields[len(fields)-2], 10, 64)
+	if err != nil {
+		return gitReflogEntry{}, false
+	}
+	return gitReflogEntry{Time: time.Unix(seconds, 0), Message: message}, true
+}
+
+func extractIssueKeys(text string) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, key := range gitKeyPattern.FindAllString(text, -1) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// remember puts keys in front of the suggestions of the repository.
+func (watcher *gitWatcher) remember(keys []string, summary string) {
+	watcher.mutex.Lock()
+	defer watcher.mutex.Unlock()
+	for i := len(keys) - 1; i >= 0; i-- {
+		suggestions := []gitSuggestion{{Key: keys[i], Summary: summary}}
+		for _, suggestion := range watcher.suggestions {
+			if suggestion.Key != keys[i] && len(suggestions) < maxGitSuggestions {
+				suggestions = append(suggestions, suggestion)
+			}
+		}
+		watcher.suggestions = suggestions
+	}
+}
+
+// handleReflogEntry remembers the issues of checkouts and commits and, when
+// announce is set, reacts to them. Commits without a key in their message
+// belong to the key of the branch.
+func (watcher *gitWatcher) handleReflogEntry(entry gitReflogEntry, announce bool) {
+	switch {
+	case strings.HasPrefix(entry.Message, "checkout: moving from "):
+		_, branch, _ := strings.Cut(entry.Message, " to ")
+		keys := extractIssueKeys(branch)
+		if len(keys) == 0 {
+			return
+		}
+		watcher.remember(keys, branch)
+		if announce {
+			onGitCheckout(watcher, branch, keys[0])
+		}
+	// merges name the merged branch rather than the work done
+	case strings.HasPrefix(entry.Message, "commit") && !strings.HasPrefix(entry.Message, "commit (merge)"):
+		_, subject, _ := strings.Cut(entry.Message, ": ")
+		summary := subject
+		keys := extractIssueKeys(subject)
+		if len(keys) == 0 {
+			summary = watcher.getBranch()
+			keys = extractIssueKeys(summary)
+		}
+		if len(keys) == 0 {
+			return
+		}
+		watcher.remember(keys, summary)
+		if announce {
+			checkGitCommitTask(watcher, subject, keys)
+		}
+	}
+}
+
+func (watcher *gitWatcher) poll(announce bool) {
+	entries, err := watcher.readReflog()
+	if err != nil {
+		myWarningLogger.Printf("Could not read the reflog of %s: %s", watcher.Path, err.Error())
+		return
+	}
+	for _, entry := range entries {
+		watcher.handleReflogEntry(entry, announce)
+	}
+}
+
+func getCurrentTaskKey() string {
+	task, _ := currentTask.Get()
+	return getElementFromStringWithColon(task, 0)
+}
+
+// onGitCheckout switches the running task to the issue of a checked out
+// branch. Without a running task nothing is started, the branch is only
+// suggested.
+func onGitCheckout(watcher *gitWatcher, branch string, key string) {
+	myLogger.Printf("Checked out %s in %s", branch, watcher.Path)
+	if !trackerConfig.Git.SwitchOnCheckout || !working || strings.EqualFold(getCurrentTaskKey(), key) {
+		return
+	}
+	myLogger.Printf("Switching to %s after checking out %s", key, branch)
+	switchToTask(getHistoryEntryForTask(key))
+	notifyGit("Switched task", fmt.Sprintf("Switched to %s after checking out %s", key, branch))
+}
+
+// checkGitCommitTask flags a commit to other issues than the running task,
+// which usually means the wrong task is being tracked.
+func checkGitCommitTask(watcher *gitWatcher, subject string, keys []string) {
+	if !trackerConfig.Git.WarnOnCommitMismatch || !working {
+		return
+	}
+	currentKey := getCurrentTaskKey()
+	for _, key := range keys {
+		if strings.EqualFold(key, currentKey) {
+			return
+		}
+	}
+	myWarningLogger.Printf("Committed %q on %s in %s while tracking %s", subject, strings.Join(keys, ", "), watcher.Path, currentKey)
+	beeep.Beep(beeep.DefaultFreq, beeep.DefaultDuration)
+	notifyGit("Commit on another task", fmt.Sprintf("Committed on %s while tracking %s", strings.Join(keys, ", "), currentKey))
+}
+
+func notifyGit(title string, message string) {
+	err := beeep.Notify(title, message, "")
+	if err != nil {
+		myWarningLogger.Printf("Could not show notification: %s", err.Error())
+	}
+}
+
+// setupGitWatchers reads the reflogs of the configured repositories up to
+// now, for the suggestions, and watches them for new entries from then on.
+func setupGitWatchers() {
+	config := trackerConfig.Git
+	if config.KeyPattern != "" {
+		pattern, err := regexp.Compile(config.KeyPattern)
+		if err != nil {
+			myErrorLogger.Printf("Got error in git key pattern %s, using the default", err.Error())
+		} else {
+			gitKeyPattern = pattern
+		}
+	}
+
+	for _, path := range config.Repositories {
+		gitDir, err := resolveGitDir(path)
+		if err != nil {
+			myWarningLogger.Printf("Not watching git repository %s: %s", path, err.Error())
+			continue
+		}
+		watcher := &gitWatcher{Path: path, gitDir: gitDir}
+		watcher.poll(false)
+		gitWatchers = append(gitWatchers, watcher)
+		myLogger.Printf("Watching git repository %s", path)
+	}
+	if len(gitWatchers) == 0 {
+		return
+	}
+
+	go func() {
+		for range time.Tick(time.Duration(max(config.PollSeconds, 1)) * time.Second) {
+			for _, watcher := range gitWatchers {
+				watcher.poll(true)
+			}
+		}
+	}()
+}
+
+// getGitIssue names the issue of key like the cache or the history does,
+// falling back to the branch or commit it was found in.
+func getGitIssue(key string, summary string) JIRAIssue {
+	if issue, found := issueCache.lookupKey(key); found {
+		return issue
+	}
+	workLogHistoryMutex.Lock()
+	for _, entry := range worklogHistory.WorkLogHistory {
+		if entry.Task == key && entry.TaskName != "" && entry.TaskName != key {
+			summary = entry.TaskName
+			break
+		}
+	}
+	workLogHistoryMutex.Unlock()
+	return JIRAIssue{Key: key, Summary: summary, Project: getProjectKeyFromIssueKey(key), Connection: getTaskConnectionName(key)}
+}
+
+// getGitSuggestionSections suggests the issues of the checked out branches
+// first, then those of recent checkouts and commits.
+func getGitSuggestionSections() []suggestionSection {
+	var issues []JIRAIssue
+	for _, watcher := range gitWatchers {
+		branch := watcher.getBranch()
+		for _, key := range extractIssueKeys(branch) {
+			issues = append(issues, getGitIssue(key, branch))
+		}
+	}
+	for _, watcher := range gitWatchers {
+		watcher.mutex.Lock()
+		suggestions := append([]gitSuggestion(nil), watcher.suggestions...)
+		watcher.mutex.Unlock()
+		for _, suggestion := range suggestions {
+			issues = append(issues, getGitIssue(suggestion.Key, suggestion.Summary))
+		}
+	}
+	if len(issues) == 0 {
+		return nil
+	}
+	return []suggestionSection{{Name: "Git", Issues: issues}}
+}
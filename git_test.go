@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseGitReflogLine(t *testing.T) {
+	tests := []struct {
+		line   string
+		want   gitReflogEntry
+		wantOK bool
+	}{
+		{
+			line:   "3f2a9c1e0b7d4a6f8e5c2b1a0d9f8e7c6b5a4d3e 8e7c6b5a4d3e3f2a9c1e0b7d4a6f8e5c2b1a0d9f Jane Q. Developer <jane@example.com> 1709627400 +0100\tcheckout: moving from main to feature/ABC-123-login",
+			want:   gitReflogEntry{Time: time.Unix(1709627400, 0), Message: "checkout: moving from main to feature/ABC-123-login"},
+			wantOK: true,
+		},
+		{
+			line:   "0000000000000000000000000000000000000000 3f2a9c1e0b7d4a6f8e5c2b1a0d9f8e7c6b5a4d3e dev <dev@example.com> 1709627500 -0500\tcommit (initial): ABC-1 Initial commit\r",
+			want:   gitReflogEntry{Time: time.Unix(1709627500, 0), Message: "commit (initial): ABC-1 Initial commit"},
+			wantOK: true,
+		},
+		{
+			line:   "3f2a9c1e 8e7c6b5a dev <dev@example.com> 1709627600 +0000\tcommit: message with\ta tab",
+			want:   gitReflogEntry{Time: time.Unix(1709627600, 0), Message: "commit: message with\ta tab"},
+			wantOK: true,
+		},
+		{line: ""},
+		{line: "3f2a9c1e 8e7c6b5a dev <dev@example.com> 1709627600 +0000 without a tab"},
+		{line: "3f2a9c1e 8e7c6b5a dev <dev@example.com> yesterday +0000\tcommit: ABC-2"},
+		{line: "+0000\tcommit: ABC-2"},
+	}
+	for _, test := range tests {
+		got, ok := parseGitReflogLine(test.line)
+		if ok != test.wantOK || !got.Time.Equal(test.want.Time) || got.Message != test.want.Message {
+			t.Errorf("parseGitReflogLine(%q) = %+v, %t, want %+v, %t", test.line, got, ok, test.want, test.wantOK)
+		}
+	}
+}
+
+func TestExtractIssueKeys(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{text: "feature/ABC-123-login-page", want: []string{"ABC-123"}},
+		{text: "ABC-123 DEV_OPS-7: fix the build, see ABC-123 and X2-9", want: []string{"ABC-123", "DEV_OPS-7", "X2-9"}},
+		{text: "bugfix/abc-123", want: nil},
+		{text: "release-2024-03", want: nil},
+		{text: "A-1 is too short, XABC-12a is not a key", want: nil},
+		{text: "", want: nil},
+	}
+	for _, test := range tests {
+		if got := extractIssueKeys(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("extractIssueKeys(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"fyne.io/fyne/v2/dialog"
+)
+
+// workLogHistoryVersion is the schema version written to work.history. Files
+// without a version predate versioning and count as version 0; each entry of
+// workLogHistoryMigrations lifts a history by one version.
+const (
+	workLogHistoryFileName = "work.history"
+	workLogHistoryVersion  = 1
+	workLogHistoryBackups  = 5
+)
+
+var (
+	// workLogHistoryMutex guards worklogHistory, workLogHistorySaveMutex
+	// keeps the snapshots of it written in the order they were taken
+	workLogHistoryMutex      sync.Mutex
+	workLogHistorySaveMutex  sync.Mutex
+	workLogHistoryMigrations = []func(*WorkLogHistoryRoot){
+		migrateWorkLogHistoryToVersion1,
+	}
+)
+
+// migrateWorkLogHistoryToVersion1 drops the empty entries that were written
+// when a task was started with an empty text and counts entries without a
+// count as used once.
+func migrateWorkLogHistoryToVersion1(history *WorkLogHistoryRoot) {
+	var entries []WorkLogHistoryEntry
+	for _, entry := range history.WorkLogHistory {
+		if entry.Task == "" {
+			continue
+		}
+		if entry.Count < 1 {
+			entry.Count = 1
+		}
+		entries = append(entries, entry)
+	}
+	history.WorkLogHistory = entries
+}
+
+func decodeWorkLogHistory(data []byte) (WorkLogHistoryRoot, error) {
+	var history WorkLogHistoryRoot
+	err := json.Unmarshal(data, &history)
+	if err != nil {
+		return history, err
+	}
+	if history.Version > workLogHistoryVersion {
+		return history, fmt.Errorf("%s was written by a newer version (%d) of the tracker", workLogHistoryFileName, history.Version)
+	}
+	for version := history.Version; version < workLogHistoryVersion; version++ {
+		myLogger.Printf("Migrating worklog history from version %d to %d", version, version+1)
+		workLogHistoryMigrations[version](&history)
+	}
+	history.Version = workLogHistoryVersion
+	return history, nil
+}
+
+// writeFileAtomically replaces name with data so that a crash leaves either
+// the old or the new content behind, never a truncated file.
+func writeFileAtomically(name string, data []byte) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tempName := tempFile.Name()
+
+	_, err = tempFile.Write(data)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempName, name)
+	}
+	if err != nil {
+		os.Remove(tempName)
+		return err
+	}
+
+	// persist the rename itself; directories cannot be synced on Windows
+	if dir, err := os.Open(filepath.Dir(name)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+func getWorkLogHistoryBackupName(number int) string {
+	return getDataFilePath(fmt.Sprintf("%s.%d", workLogHistoryFileName, number))
+}
+
+// rotateWorkLogHistoryBackups keeps the last workLogHistoryBackups histories
+// that could be read, the newest one in work.history.1.
+func rotateWorkLogHistoryBackups(data []byte) {
+	os.Remove(getWorkLogHistoryBackupName(workLogHistoryBackups))
+	for number := workLogHistoryBackups - 1; number >= 1; number-- {
+		os.Rename(getWorkLogHistoryBackupName(number), getWorkLogHistoryBackupName(number+1))
+	}
+	err := writeFileAtomically(getWorkLogHistoryBackupName(1), data)
+	if err != nil {
+		myErrorLogger.Printf("Got error when backing up history %s", err.Error())
+	}
+}
+
+// getWorkLogHistoryEntries returns a copy of the history entries, taken
+// while holding workLogHistoryMutex, for reading them on any goroutine.
+func getWorkLogHistoryEntries() []WorkLogHistoryEntry {
+	workLogHistoryMutex.Lock()
+	defer workLogHistoryMutex.Unlock()
+	entries := make([]WorkLogHistoryEntry, len(worklogHistory.WorkLogHistory))
+	copy(entries, worklogHistory.WorkLogHistory)
+	return entries
+}
+
+// saveWorkLogHistory writes a snapshot of the history taken while holding
+// workLogHistoryMutex, so that the file is written without blocking the
+// history.
+func saveWorkLogHistory() {
+	workLogHistorySaveMutex.Lock()
+	defer workLogHistorySaveMutex.Unlock()
+
+	workLogHistoryMutex.Lock()
+	worklogHistory.Version = workLogHistoryVersion
+	data, err := json.Marshal(&worklogHistory)
+	workLogHistoryMutex.Unlock()
+
+	if err == nil {
+		err = writeFileAtomically(getDataFilePath(workLogHistoryFileName), data)
+	}
+	if err != nil {
+		myErrorLogger.Printf("Got error when writing history %s", err.Error())
+		dialog.NewError(err, myWindow).Show()
+		return
+	}
+	myLogger.Printf("wrote %d bytes to history\n", len(data))
+}
+
+func retrieveWorklogHistory() {
+	data, err := os.ReadFile(getDataFilePath(workLogHistoryFileName))
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(bytes.TrimSpace(data)) == 0) {
+		myLogger.Printf("No worklog history found")
+		if _, backupErr := os.Stat(getWorkLogHistoryBackupName(1)); backupErr == nil {
+			offerWorkLogHistoryRestore(fmt.Errorf("%s is missing or empty", workLogHistoryFileName))
+		}
+		return
+	}
+	if err != nil {
+		myErrorLogger.Printf("Got error when reading history %s", err.Error())
+		dialog.NewError(err, myWindow).Show()
+		return
+	}
+
+	history, err := decodeWorkLogHistory(data)
+	if err != nil {
+		myErrorLogger.Printf("Got error when reading history %s", err.Error())
+		// keep the damaged file around, the next save replaces work.history
+		os.WriteFile(getDataFilePath(workLogHistoryFileName+".damaged"), data, 0644)
+		offerWorkLogHistoryRestore(err)
+		return
+	}
+	workLogHistoryMutex.Lock()
+	worklogHistory = history
+	workLogHistoryMutex.Unlock()
+	myLogger.Printf("Retrieved worklog history of size %d", len(history.WorkLogHistory))
+	rotateWorkLogHistoryBackups(data)
+}
+
+func offerWorkLogHistoryRestore(cause error) {
+	dialog.NewConfirm("Worklog history", fmt.Sprintf("%s.\nRestore the latest readable backup?", cause.Error()), func(restore bool) {
+		if restore {
+			restoreWorkLogHistoryFromBackup()
+		}
+	}, myWindow).Show()
+}
+
+func restoreWorkLogHistoryFromBackup() {
+	for number := 1; number <= workLogHistoryBackups; number++ {
+		data, err := os.ReadFile(getWorkLogHistoryBackupName(number))
+		if err != nil {
+			continue
+		}
+		history, err := decodeWorkLogHistory(data)
+		if err != nil {
+			myErrorLogger.Printf("Got error when reading history backup %d %s", number, err.Error())
+			continue
+		}
+		myLogger.Printf("Restoring worklog history from backup %d", number)
+		workLogHistoryMutex.Lock()
+		worklogHistory = history
+		workLogHistoryMutex.Unlock()
+		saveWorkLogHistory()
+		return
+	}
+	err := errors.New("no readable backup of the worklog history found")
+	myErrorLogger.Printf("Got error %s", err.Error())
+	dialog.NewError(err, myWindow).Show()
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+func useTempDataDirectory(t *testing.T) {
+	previous := dataDirectory
+	dataDirectory = t.TempDir()
+	t.Cleanup(func() { dataDirectory = previous })
+}
+
+func useWorkLogHistory(t *testing.T, history WorkLogHistoryRoot) {
+	previous := worklogHistory
+	worklogHistory = history
+	t.Cleanup(func() { worklogHistory = previous })
+}
+
+func TestDecodeWorkLogHistory(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantErr   bool
+		wantTasks []string
+		wantCount []int
+	}{
+		{
+			name:      "version 0 drops empty tasks and counts entries once",
+			data:      `{"WorkLogHistory":[{"task":"ABC-1","count":0},{"task":"","count":3},{"task":"ABC-2","count":4}]}`,
+			wantTasks: []string{"ABC-1", "ABC-2"},
+			wantCount: []int{1, 4},
+		},
+		{
+			name:      "current version is not migrated",
+			data:      fmt.Sprintf(`{"version":%d,"WorkLogHistory":[{"task":"ABC-1","count":2}]}`, workLogHistoryVersion),
+			wantTasks: []string{"ABC-1"},
+			wantCount: []int{2},
+		},
+		{
+			name:    "newer version",
+			data:    fmt.Sprintf(`{"version":%d,"WorkLogHistory":[]}`, workLogHistoryVersion+1),
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON",
+			data:    `{"WorkLogHistory":[`,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			history, err := decodeWorkLogHistory([]byte(test.data))
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("decodeWorkLogHistory() = %+v, want an error", history)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeWorkLogHistory() error %v", err)
+			}
+			if history.Version != workLogHistoryVersion {
+				t.Errorf("Version = %d, want %d", history.Version, workLogHistoryVersion)
+			}
+			if len(history.WorkLogHistory) != len(test.wantTasks) {
+				t.Fatalf("got %d entries, want %d", len(history.WorkLogHistory), len(test.wantTasks))
+			}
+			for i, entry := range history.WorkLogHistory {
+				if entry.Task != test.wantTasks[i] || entry.Count != test.wantCount[i] {
+					t.Errorf("entry %d = %s/%d, want %s/%d", i, entry.Task, entry.Count, test.wantTasks[i], test.wantCount[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRotateWorkLogHistoryBackups(t *testing.T) {
+	useTempDataDirectory(t)
+
+	for i := 1; i <= workLogHistoryBackups+2; i++ {
+		rotateWorkLogHistoryBackups([]byte(fmt.Sprintf("history %d", i)))
+	}
+	for number := 1; number <= workLogHistoryBackups; number++ {
+		data, err := os.ReadFile(getWorkLogHistoryBackupName(number))
+		if err != nil {
+			t.Fatalf("backup %d: %v", number, err)
+		}
+		want := fmt.Sprintf("history %d", workLogHistoryBackups+3-number)
+		if string(data) != want {
+			t.Errorf("backup %d = %q, want %q", number, data, want)
+		}
+	}
+	if _, err := os.Stat(getWorkLogHistoryBackupName(workLogHistoryBackups + 1)); !os.IsNotExist(err) {
+		t.Errorf("backup %d exists, want at most %d backups", workLogHistoryBackups+1, workLogHistoryBackups)
+	}
+}
+
+func TestRestoreWorkLogHistoryFromBackup(t *testing.T) {
+	useTempDataDirectory(t)
+	useWorkLogHistory(t, WorkLogHistoryRoot{})
+
+	os.WriteFile(getWorkLogHistoryBackupName(1), []byte(`{"WorkLogHistory":[`), 0644)
+	os.WriteFile(getWorkLogHistoryBackupName(2), []byte(`{"WorkLogHistory":[{"task":"ABC-2","count":2}]}`), 0644)
+	restoreWorkLogHistoryFromBackup()
+
+	if len(worklogHistory.WorkLogHistory) != 1 || worklogHistory.WorkLogHistory[0].Task != "ABC-2" {
+		t.Fatalf("restored %+v, want the entry of backup 2", worklogHistory.WorkLogHistory)
+	}
+	data, err := os.ReadFile(getDataFilePath(workLogHistoryFileName))
+	if err != nil {
+		t.Fatalf("restored history was not saved: %v", err)
+	}
+	saved, err := decodeWorkLogHistory(data)
+	if err != nil || len(saved.WorkLogHistory) != 1 || saved.WorkLogHistory[0].Task != "ABC-2" {
+		t.Errorf("saved history = %+v, %v", saved.WorkLogHistory, err)
+	}
+}
+
+func TestSaveWorkLogToHistoryConcurrently(t *testing.T) {
+	useTempDataDirectory(t)
+	useWorkLogHistory(t, WorkLogHistoryRoot{WorkLogHistory: []WorkLogHistoryEntry{{Task: "ABC-0", Activity: "Development", Count: 1}}})
+	connection := newJIRAConnection(ConnectionConfig{Name: "server", BaseURL: "http://jira.invalid"})
+	connection.workAttributesFetched = true
+	useJIRAConnections(t, connection)
+
+	const tasks = 20
+	var wait sync.WaitGroup
+	for i := 1; i <= tasks; i++ {
+		wait.Add(2)
+		go func(i int) {
+			defer wait.Done()
+			saveWorkLogToHistory(fmt.Sprintf("ABC-%d", i), "", "", "", "", "", "")
+		}(i)
+		// the pickers, the palette and the suggestions of calendar, git and
+		// window tracking read the history while worklogs are saved
+		go func() {
+			defer wait.Done()
+			getHistoryEntryForTask("ABC-0")
+			getDefaultActivityForTask("ABC-0")
+			rankTaskPickerItems("abc", nil, nil)
+		}()
+	}
+	wait.Wait()
+
+	if len(worklogHistory.WorkLogHistory) != tasks+1 {
+		t.Errorf("history has %d entries, want %d", len(worklogHistory.WorkLogHistory), tasks+1)
+	}
+	data, err := os.ReadFile(getDataFilePath(workLogHistoryFileName))
+	if err != nil {
+		t.Fatalf("history was not saved: %v", err)
+	}
+	saved, err := decodeWorkLogHistory(data)
+	if err != nil || len(saved.WorkLogHistory) != tasks+1 {
+		t.Errorf("saved history has %d entries (%v), want %d", len(saved.WorkLogHistory), err, tasks+1)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	paused     bool = false
+	pausedTask WorkLogHistoryEntry
+
+	errHotkeyUnsupported = errors.New("global hotkeys are not supported on this platform")
+)
+
+type Hotkey struct {
+	Ctrl  bool
+	Alt   bool
+	Shift bool
+	Super bool
+	Key   string
+}
+
+type globalHotkey struct {
+	Hotkey Hotkey
+	Action func()
+}
+
+// parseHotkey turns a shortcut such as "Ctrl+Alt+T" into a Hotkey. Keys are
+// limited to letters, digits, F1-F12 and Space, which is what both X11 and
+// RegisterHotKey can grab without a keyboard layout lookup.
+func parseHotkey(shortcut string) (Hotkey, error) {
+	var hotkey Hotkey
+	for _, part := range strings.Split(shortcut, "+") {
+		part = strings.TrimSpace(part)
+		switch strings.ToLower(part) {
+		case "ctrl", "control":
+			hotkey.Ctrl = true
+		case "alt":
+			hotkey.Alt = true
+		case "shift":
+			hotkey.Shift = true
+		case "super", "win", "meta":
+			hotkey.Super = true
+		default:
+			if hotkey.Key != "" {
+				return Hotkey{}, fmt.Errorf("hotkey %q has more than one key", shortcut)
+			}
+			hotkey.Key = strings.ToUpper(part)
+		}
+	}
+	if hotkey.Key == "" {
+		return Hotkey{}, fmt.Errorf("hotkey %q has no key", shortcut)
+	}
+	if !hotkey.Ctrl && !hotkey.Alt && !hotkey.Super {
+		return Hotkey{}, fmt.Errorf("hotkey %q needs Ctrl, Alt or Super", shortcut)
+	}
+	if !isSupportedHotkeyKey(hotkey.Key) {
+		return Hotkey{}, fmt.Errorf("hotkey %q uses unsupported key %s", shortcut, hotkey.Key)
+	}
+	return hotkey, nil
+}
+
+func isSupportedHotkeyKey(key string) bool {
+	if len(key) == 1 {
+		return (key[0] >= 'A' && key[0] <= 'Z') || (key[0] >= '0' && key[0] <= '9')
+	}
+	if key == "SPACE" {
+		return true
+	}
+	var functionKey int
+	if _, err := fmt.Sscanf(key, "F%d", &functionKey); err == nil {
+		return functionKey >= 1 && functionKey <= 12 && key == fmt.Sprintf("F%d", functionKey)
+	}
+	return false
+}
+
+func registerGlobalHotkeys() {
+	if !trackerConfig.Hotkeys.Enabled {
+		return
+	}
+
+	shortcuts := []struct {
+		shortcut string
+		action   func()
+	}{
+		{trackerConfig.Hotkeys.QuickSwitch, showQuickSwitchPalette},
+		{trackerConfig.Hotkeys.Stop, stopWorkFromHotkey},
+		{trackerConfig.Hotkeys.Pause, togglePause},
+	}
+
+	var hotkeys []globalHotkey
+	for _, s := range shortcuts {
+		if s.shortcut == "" {
+			continue
+		}
+		hotkey, err := parseHotkey(s.shortcut)
+		if err != nil {
+			myWarningLogger.Printf("Ignoring hotkey: %s", err.Error())
+			continue
+		}
+		hotkeys = append(hotkeys, globalHotkey{Hotkey: hotkey, Action: s.action})
+	}
+	if len(hotkeys) == 0 {
+		return
+	}
+
+	go func() {
+		err := listenForGlobalHotkeys(hotkeys)
+		if err != nil {
+			myWarningLogger.Printf("Global hotkeys are not available: %s", err.Error())
+		}
+	}()
+}
+
+func stopWorkFromHotkey() {
+	if working {
+		stopWorkAndResetUI()
+	}
+}
+
+// togglePause stops the running task and remembers it, so that the next
+// toggle resumes the same task with the same account and comment.
+func togglePause() {
+	if working {
+		pausedTask.Task, _ = currentTask.Get()
+		pausedTask.TaskName, _ = currentTaskName.Get()
+		pausedTask.Account, _ = currentAccount.Get()
+		pausedTask.AccountName, _ = currentAccountName.Get()
+		pausedTask.Comment, _ = currentComment.Get()
+		pausedTask.Activity, _ = currentActivity.Get()
+		stopWorkAndResetUI()
+		paused = true
+		currentStatus.Set(fmt.Sprintf("Paused %s since %s", pausedTask.Task, time.Now().Format("15:04:05")))
+		myLogger.Printf("Pausing work on %s", pausedTask.Task)
+	} else if paused {
+		myLogger.Printf("Resuming work on %s", pausedTask.Task)
+		startWorkAndResetUI(pausedTask.Task, pausedTask.TaskName, pausedTask.Account, pausedTask.AccountName, pausedTask.Comment, pausedTask.Activity)
+	}
+}
@@ -0,0 +1,123 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+const (
+	xkSpace = 0x0020
+	xkF1    = 0xffbe
+)
+
+func keysymForKey(key string) xproto.Keysym {
+	if key == "SPACE" {
+		return xkSpace
+	}
+	if len(key) == 1 {
+		if key[0] >= 'A' && key[0] <= 'Z' {
+			return xproto.Keysym(key[0] - 'A' + 'a')
+		}
+		return xproto.Keysym(key[0])
+	}
+	var functionKey int
+	fmt.Sscanf(key, "F%d", &functionKey)
+	return xproto.Keysym(xkF1 + functionKey - 1)
+}
+
+// listenForGlobalHotkeys grabs the hotkeys on the X11 root window. Caps Lock
+// and Num Lock are reported as modifiers too, so every key is grabbed once
+// for each combination of them.
+func listenForGlobalHotkeys(hotkeys []globalHotkey) error {
+	X, err := xgb.NewConn()
+	if err != nil {
+		return err
+	}
+	defer X.Close()
+
+	setup := xproto.Setup(X)
+	root := setup.DefaultScreen(X).Root
+	minKeycode := setup.MinKeycode
+	mapping, err := xproto.GetKeyboardMapping(X, minKeycode, byte(setup.MaxKeycode-minKeycode+1)).Reply()
+	if err != nil {
+		return err
+	}
+	keysymsPerKeycode := int(mapping.KeysymsPerKeycode)
+
+	keycodeForKeysym := func(keysym xproto.Keysym) (xproto.Keycode, bool) {
+		for i := 0; i*keysymsPerKeycode < len(mapping.Keysyms); i++ {
+			if mapping.Keysyms[i*keysymsPerKeycode] == keysym {
+				return minKeycode + xproto.Keycode(i), true
+			}
+		}
+		return 0, false
+	}
+
+	lockMasks := []uint16{0, xproto.ModMaskLock, xproto.ModMask2, xproto.ModMaskLock | xproto.ModMask2}
+	keycodes := make([]xproto.Keycode, len(hotkeys))
+	modifiers := make([]uint16, len(hotkeys))
+	registered := 0
+	for i, hotkey := range hotkeys {
+		keycode, found := keycodeForKeysym(keysymForKey(hotkey.Hotkey.Key))
+		if !found {
+			myWarningLogger.Printf("Could not find a keycode for hotkey %s", hotkey.Hotkey.Key)
+			continue
+		}
+		var mask uint16
+		if hotkey.Hotkey.Ctrl {
+			mask |= xproto.ModMaskControl
+		}
+		if hotkey.Hotkey.Alt {
+			mask |= xproto.ModMask1
+		}
+		if hotkey.Hotkey.Shift {
+			mask |= xproto.ModMaskShift
+		}
+		if hotkey.Hotkey.Super {
+			mask |= xproto.ModMask4
+		}
+		grabbed := true
+		for _, lockMask := range lockMasks {
+			err := xproto.GrabKeyChecked(X, true, root, mask|lockMask, keycode, xproto.GrabModeAsync, xproto.GrabModeAsync).Check()
+			if err != nil {
+				myWarningLogger.Printf("Could not grab hotkey %s: %s", hotkey.Hotkey.Key, err.Error())
+				grabbed = false
+				break
+			}
+		}
+		if grabbed {
+			keycodes[i] = keycode
+			modifiers[i] = mask
+			registered++
+		}
+	}
+	if registered == 0 {
+		return fmt.Errorf("none of %d hotkeys could be grabbed", len(hotkeys))
+	}
+	myLogger.Printf("Grabbed %d global hotkeys", registered)
+
+	for {
+		event, err := X.WaitForEvent()
+		if event == nil && err == nil {
+			return nil
+		}
+		if err != nil {
+			myWarningLogger.Printf("X11 error while waiting for hotkeys: %s", err.Error())
+			continue
+		}
+		keyPress, ok := event.(xproto.KeyPressEvent)
+		if !ok {
+			continue
+		}
+		state := keyPress.State &^ (xproto.ModMaskLock | xproto.ModMask2)
+		for i := range hotkeys {
+			if keycodes[i] != 0 && keycodes[i] == keyPress.Detail && modifiers[i] == state {
+				hotkeys[i].Action()
+			}
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !windows && !linux
+
+package main
+
+func listenForGlobalHotkeys(hotkeys []globalHotkey) error {
+	return errHotkeyUnsupported
+}
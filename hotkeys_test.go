@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestParseHotkey(t *testing.T) {
+	tests := []struct {
+		shortcut string
+		want     Hotkey
+		wantErr  bool
+	}{
+		{shortcut: "Ctrl+Alt+T", want: Hotkey{Ctrl: true, Alt: true, Key: "T"}},
+		{shortcut: "control + shift + s", want: Hotkey{Ctrl: true, Shift: true, Key: "S"}},
+		{shortcut: "Win+F12", want: Hotkey{Super: true, Key: "F12"}},
+		{shortcut: "Meta+Space", want: Hotkey{Super: true, Key: "SPACE"}},
+		{shortcut: "Alt+7", want: Hotkey{Alt: true, Key: "7"}},
+		{shortcut: "Shift+T", wantErr: true},
+		{shortcut: "T", wantErr: true},
+		{shortcut: "Ctrl+Alt", wantErr: true},
+		{shortcut: "Ctrl+A+B", wantErr: true},
+		{shortcut: "Ctrl+F13", wantErr: true},
+		{shortcut: "Ctrl+F01", wantErr: true},
+		{shortcut: "Ctrl+Tab", wantErr: true},
+		{shortcut: "", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := parseHotkey(test.shortcut)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseHotkey(%q) error = %v, want error %t", test.shortcut, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseHotkey(%q) = %+v, want %+v", test.shortcut, got, test.want)
+		}
+	}
+}
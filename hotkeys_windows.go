@@ -0,0 +1,100 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+const (
+	modAlt      = 0x0001
+	modControl  = 0x0002
+	modShift    = 0x0004
+	modWin      = 0x0008
+	modNoRepeat = 0x4000
+	wmHotkey    = 0x0312
+	vkSpace     = 0x20
+	vkF1        = 0x70
+)
+
+var (
+	registerHotKey   = user32.MustFindProc("RegisterHotKey")
+	unregisterHotKey = user32.MustFindProc("UnregisterHotKey")
+	getMessage       = user32.MustFindProc("GetMessageW")
+)
+
+type hotkeyMessage struct {
+	hwnd    uintptr
+	message uint32
+	wParam  uintptr
+	lParam  uintptr
+	time    uint32
+	pt      struct {
+		x int32
+		y int32
+	}
+}
+
+func virtualKeyCode(key string) uint32 {
+	if key == "SPACE" {
+		return vkSpace
+	}
+	if len(key) == 1 {
+		return uint32(key[0])
+	}
+	var functionKey uint32
+	fmt.Sscanf(key, "F%d", &functionKey)
+	return vkF1 + functionKey - 1
+}
+
+// listenForGlobalHotkeys registers the hotkeys with RegisterHotKey and pumps
+// the thread message queue. WM_HOTKEY is posted to the registering thread,
+// so the goroutine has to stay on one OS thread for its whole lifetime.
+func listenForGlobalHotkeys(hotkeys []globalHotkey) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	registered := 0
+	for i, hotkey := range hotkeys {
+		var modifiers uintptr = modNoRepeat
+		if hotkey.Hotkey.Ctrl {
+			modifiers |= modControl
+		}
+		if hotkey.Hotkey.Alt {
+			modifiers |= modAlt
+		}
+		if hotkey.Hotkey.Shift {
+			modifiers |= modShift
+		}
+		if hotkey.Hotkey.Super {
+			modifiers |= modWin
+		}
+		r1, _, err := registerHotKey.Call(0, uintptr(i+1), modifiers, uintptr(virtualKeyCode(hotkey.Hotkey.Key)))
+		if r1 == 0 {
+			myWarningLogger.Printf("Could not register hotkey %s: %s", hotkey.Hotkey.Key, err.Error())
+			continue
+		}
+		defer unregisterHotKey.Call(0, uintptr(i+1))
+		registered++
+	}
+	if registered == 0 {
+		return fmt.Errorf("none of %d hotkeys could be registered", len(hotkeys))
+	}
+	myLogger.Printf("Registered %d global hotkeys", registered)
+
+	var msg hotkeyMessage
+	for {
+		r1, _, err := getMessage.Call(uintptr(unsafe.Pointer(&msg)), 0, 0, 0)
+		if int32(r1) == -1 {
+			return err
+		}
+		if r1 == 0 {
+			return nil
+		}
+		if msg.message == wmHotkey && msg.wParam >= 1 && int(msg.wParam) <= len(hotkeys) {
+			hotkeys[msg.wParam-1].Action()
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The running instance listens on a loopback port of its own choosing and
+// records it, together with a secret, in a lock file in the data directory.
+// Only the same user with the same data directory can read the secret, so
+// only they can reach the instance, and other programs or users on the same
+// machine never block it. A lock file left behind by a crash is recognized by
+// its instance not answering.
+const (
+	instanceLockFileName = "instance.lock"
+	instanceHandshake    = "GoTimeTracker"
+)
+
+var (
+	instanceListener net.Listener
+	instanceLock     InstanceLock
+	// pendingInstanceCommands holds the commands forwarded before the window
+	// is ready to run them
+	pendingInstanceCommands [][]string
+	instanceReady           bool
+	instanceMutex           sync.Mutex
+)
+
+type InstanceLock struct {
+	Port   int    `json:"port"`
+	Secret string `json:"secret"`
+	PID    int    `json:"pid"`
+}
+
+type InstanceRequest struct {
+	Handshake string   `json:"handshake"`
+	Secret    string   `json:"secret"`
+	Command   []string `json:"command"`
+}
+
+type InstanceResponse struct {
+	Handshake string `json:"handshake"`
+	Error     string `json:"error,omitempty"`
+}
+
+// acquireSingleInstance makes this process the running instance of the data
+// directory. When another instance already runs, command is forwarded to it
+// and false is returned; the caller must then exit without touching any file.
+func acquireSingleInstance(command []string) bool {
+	lockFileName := getDataFilePath(instanceLockFileName)
+	for attempt := 0; attempt < 3; attempt++ {
+		file, err := os.OpenFile(lockFileName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			err = startInstanceListener(file)
+			file.Close()
+			if err != nil {
+				os.Remove(lockFileName)
+				myErrorLogger.Printf("Got error when starting the instance listener %s", err.Error())
+				fmt.Fprintf(os.Stderr, "timetracker: cannot start, %s\n", err.Error())
+				return false
+			}
+			return true
+		}
+		if !errors.Is(err, os.ErrExist) {
+			myErrorLogger.Printf("Got error when creating %s %s", lockFileName, err.Error())
+			fmt.Fprintf(os.Stderr, "timetracker: cannot start, %s\n", err.Error())
+			return false
+		}
+
+		data, err := os.ReadFile(lockFileName)
+		var lock InstanceLock
+		if err == nil {
+			err = json.Unmarshal(data, &lock)
+		}
+		if err != nil {
+			// the running instance may be writing the file right now
+			time.Sleep(500 * time.Millisecond)
+			if current, readErr := os.ReadFile(lockFileName); readErr == nil && string(current) == string(data) {
+				myWarningLogger.Printf("Removing unreadable %s", lockFileName)
+				os.Remove(lockFileName)
+			}
+			continue
+		}
+
+		err = forwardCommandToInstance(lock, command)
+		if err == nil {
+			return false
+		}
+		var commandErr *instanceCommandError
+		if errors.As(err, &commandErr) {
+			myErrorLogger.Printf("Got error when forwarding command to the running instance %s", err.Error())
+			fmt.Fprintf(os.Stderr, "timetracker: %s\n", err.Error())
+			return false
+		}
+		myWarningLogger.Printf("The instance of %s does not answer, taking over: %s", lockFileName, err.Error())
+		if current, readErr := os.ReadFile(lockFileName); readErr == nil && string(current) == string(data) {
+			os.Remove(lockFileName)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "timetracker: cannot lock %s, remove it if no tracker is running\n", lockFileName)
+	return false
+}
+
+// startInstanceListener listens on a free loopback port and records it in
+// the newly created lock file.
+func startInstanceListener(file *os.File) error {
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	lock := InstanceLock{Port: listener.Addr().(*net.TCPAddr).Port, Secret: hex.EncodeToString(secret), PID: os.Getpid()}
+	data, err := json.Marshal(lock)
+	if err == nil {
+		_, err = file.Write(data)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	instanceListener = listener
+	instanceLock = lock
+	go serveInstanceCommands()
+	return nil
+}
+
+// releaseSingleInstance removes the lock file when the instance exits.
+func releaseSingleInstance() {
+	if instanceListener == nil {
+		return
+	}
+	instanceListener.Close()
+	os.Remove(getDataFilePath(instanceLockFileName))
+}
+
+// instanceCommandError is an error of the forwarded command itself, as
+// opposed to the running instance not being reachable.
+type instanceCommandError struct {
+	message string
+}
+
+func (err *instanceCommandError) Error() string {
+	return err.message
+}
+
+func forwardCommandToInstance(lock InstanceLock, command []string) error {
+	address := fmt.Sprintf("127.0.0.1:%d", lock.Port)
+	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	err = json.NewEncoder(conn).Encode(InstanceRequest{Handshake: instanceHandshake, Secret: lock.Secret, Command: command})
+	if err != nil {
+		return err
+	}
+	var response InstanceResponse
+	err = json.NewDecoder(conn).Decode(&response)
+	if err != nil {
+		return err
+	}
+	if response.Handshake != instanceHandshake {
+		return fmt.Errorf("%s is used by another program", address)
+	}
+	if response.Error != "" {
+		return &instanceCommandError{message: response.Error}
+	}
+	myLogger.Printf("Forwarded %q to the running instance", command)
+	return nil
+}
+
+func serveInstanceCommands() {
+	for {
+		conn, err := instanceListener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				myErrorLogger.Printf("Got error when accepting instance connection %s", err.Error())
+			}
+			return
+		}
+		go handleInstanceConnection(conn)
+	}
+}
+
+func handleInstanceConnection(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var request InstanceRequest
+	err := json.NewDecoder(conn).Decode(&request)
+	if err != nil || request.Handshake != instanceHandshake || request.Secret != instanceLock.Secret {
+		myWarningLogger.Printf("Ignoring instance connection without the secret of the lock file")
+		return
+	}
+	myLogger.Printf("Got %q from another instance", request.Command)
+
+	response := InstanceResponse{Handshake: instanceHandshake}
+	if err := checkInstanceCommand(request.Command); err != nil {
+		response.Error = err.Error()
+		json.NewEncoder(conn).Encode(response)
+		return
+	}
+	instanceMutex.Lock()
+	if !instanceReady {
+		pendingInstanceCommands = append(pendingInstanceCommands, request.Command)
+		instanceMutex.Unlock()
+		json.NewEncoder(conn).Encode(response)
+		return
+	}
+	instanceMutex.Unlock()
+	if err := runInstanceCommand(request.Command); err != nil {
+		response.Error = err.Error()
+	}
+	json.NewEncoder(conn).Encode(response)
+}
+
+// setInstanceReady runs the commands forwarded while the window was being
+// built, and from then on runs them as they come.
+func setInstanceReady() {
+	instanceMutex.Lock()
+	instanceReady = true
+	pending := pendingInstanceCommands
+	pendingInstanceCommands = nil
+	instanceMutex.Unlock()
+
+	for _, command := range pending {
+		if err := runInstanceCommand(command); err != nil {
+			myErrorLogger.Printf("Got error when running %q from another instance %s", command, err.Error())
+		}
+	}
+}
+
+func checkInstanceCommand(command []string) error {
+	if len(command) == 0 {
+		return nil
+	}
+	switch command[0] {
+	case "focus", "stop", "pause", "switch":
+		return nil
+	case "start":
+		if len(command) < 2 {
+			return errors.New("usage: start <task>")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown command %q, use focus, start <task>, stop, pause or switch", command[0])
+}
+
+// runInstanceCommand runs a command given on the command line, either to
+// this process or to a later launch that forwarded it. Without a command
+// the window is brought to the front.
+func runInstanceCommand(command []string) error {
+	if err := checkInstanceCommand(command); err != nil {
+		return err
+	}
+	if len(command) == 0 {
+		command = []string{"focus"}
+	}
+	switch command[0] {
+	case "focus":
+		myWindow.Show()
+		myWindow.RequestFocus()
+	case "stop":
+		stopWorkFromHotkey()
+	case "pause":
+		togglePause()
+	case "switch":
+		showQuickSwitchPalette()
+	case "start":
+		switchToTask(getHistoryEntryForTask(strings.Join(command[1:], " ")))
+	}
+	return nil
+}
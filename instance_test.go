@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestAcquireSingleInstance(t *testing.T) {
+	defer func(directory string) { dataDirectory = directory }(dataDirectory)
+	dataDirectory = t.TempDir()
+
+	if !acquireSingleInstance(nil) {
+		t.Fatal("the first instance did not acquire the lock")
+	}
+	if acquireSingleInstance([]string{"stop"}) {
+		t.Error("a second instance acquired the lock")
+	}
+	instanceMutex.Lock()
+	pending := len(pendingInstanceCommands)
+	pendingInstanceCommands = nil
+	instanceMutex.Unlock()
+	if pending != 1 {
+		t.Errorf("%d commands are queued before the window is ready, want 1", pending)
+	}
+	if acquireSingleInstance([]string{"unknown"}) {
+		t.Error("a second instance with an unknown command acquired the lock")
+	}
+
+	// a lock file left behind by a crash is taken over
+	releaseSingleInstance()
+	data, _ := json.Marshal(InstanceLock{Port: instanceLock.Port, Secret: "stale"})
+	if err := os.WriteFile(getDataFilePath(instanceLockFileName), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if !acquireSingleInstance(nil) {
+		t.Fatal("the stale lock file was not taken over")
+	}
+	releaseSingleInstance()
+	if _, err := os.Stat(getDataFilePath(instanceLockFileName)); !os.IsNotExist(err) {
+		t.Errorf("the lock file is left after releasing it: %v", err)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// query results are reused for a short while only, the issues themselves
+// stay in the cache for the configured TTL as an offline fallback
+const issueQueryCacheDuration = 5 * time.Minute
+
+var issueCache = issueCacheStore{
+	issues:  make(map[string]JIRAIssue),
+	queries: make(map[string]cachedIssueQuery),
+}
+
+type JIRAIssue struct {
+	Key        string    `json:"key"`
+	Summary    string    `json:"summary"`
+	Project    string    `json:"project"`
+	Connection string    `json:"connection,omitempty"`
+	Fetched    time.Time `json:"fetched"`
+}
+
+type IssueCacheRoot struct {
+	Issues []JIRAIssue `json:"issues"`
+}
+
+type cachedIssueQuery struct {
+	keys    []string
+	fetched time.Time
+}
+
+type issueCacheStore struct {
+	mutex   sync.Mutex
+	issues  map[string]JIRAIssue
+	queries map[string]cachedIssueQuery
+}
+
+// issueSearcher debounces the searches of one search field. Every call to
+// Search cancels the pending and the in-flight search of the same field.
+type issueSearcher struct {
+	mutex      sync.Mutex
+	generation int
+	timer      *time.Timer
+	cancel     context.CancelFunc
+}
+
+func getProjectKeyFromIssueKey(key string) string {
+	if i := strings.LastIndex(key, "-"); i > 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+// getIssueCacheKey tells apart the issues of different connections that
+// happen to have the same key.
+func getIssueCacheKey(issue JIRAIssue) string {
+	return issue.Connection + "/" + issue.Key
+}
+
+func formatJIRAIssue(issue JIRAIssue) string {
+	summary := issue.Summary
+	if len(summary) > 35 {
+		summary = summary[0:35] + "..."
+	}
+	return fmt.Sprintf("%s: %s", issue.Key, summary)
+}
+
+func issueCacheTTL() time.Duration {
+	return time.Duration(trackerConfig.JIRASearch.CacheTTLHours) * time.Hour
+}
+
+func (cache *issueCacheStore) lookupQuery(query string) ([]JIRAIssue, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cachedQuery, found := cache.queries[strings.ToLower(query)]
+	if !found || time.Since(cachedQuery.fetched) > issueQueryCacheDuration {
+		return nil, false
+	}
+	issues := make([]JIRAIssue, 0, len(cachedQuery.keys))
+	for _, key := range cachedQuery.keys {
+		if issue, found := cache.issues[key]; found {
+			issues = append(issues, issue)
+		}
+	}
+	return issues, true
+}
+
+func (cache *issueCacheStore) store(query string, issues []JIRAIssue) {
+	cache.mutex.Lock()
+	keys := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		cache.issues[getIssueCacheKey(issue)] = issue
+		keys = append(keys, getIssueCacheKey(issue))
+	}
+	cache.queries[strings.ToLower(query)] = cachedIssueQuery{keys: keys, fetched: time.Now()}
+	cache.mutex.Unlock()
+
+	saveIssueCache()
+}
+
+// storeIssues keeps issues found by other means than the quick search, so
+// that they can be found offline as well.
+func (cache *issueCacheStore) storeIssues(issues []JIRAIssue) {
+	cache.mutex.Lock()
+	for _, issue := range issues {
+		cache.issues[getIssueCacheKey(issue)] = issue
+	}
+	cache.mutex.Unlock()
+
+	saveIssueCache()
+}
+
+// lookupKey returns a cached issue by its key, from whichever connection.
+func (cache *issueCacheStore) lookupKey(key string) (JIRAIssue, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for _, issue := range cache.issues {
+		if strings.EqualFold(issue.Key, key) {
+			return issue, true
+		}
+	}
+	return JIRAIssue{}, false
+}
+
+// search looks through the cached issues the way the JIRA quick search
+// would, for when JIRA cannot be reached.
+func (cache *issueCacheStore) search(query string) []JIRAIssue {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	type scoredIssue struct {
+		issue JIRAIssue
+		score int
+	}
+	var matches []scoredIssue
+	for _, issue := range cache.issues {
+		if time.Since(issue.Fetched) > issueCacheTTL() {
+			continue
+		}
+		score, matched := fuzzyMatch(query, issue.Key+" "+issue.Summary)
+		if matched {
+			matches = append(matches, scoredIssue{issue: issue, score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	result := []JIRAIssue{}
+	for i := 0; i < len(matches) && i < 20; i++ {
+		result = append(result, matches[i].issue)
+	}
+	return result
+}
+
+func searchIssuesWithCache(ctx context.Context, query string) []JIRAIssue {
+	if issues, found := issueCache.lookupQuery(query); found {
+		myLogger.Printf("Using cached JIRA search results for %s", query)
+		return issues
+	}
+	issues, err := searchAllConnections(ctx, query)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		myWarningLogger.Printf("JIRA search failed, falling back to the issue cache: %s", err.Error())
+		return issueCache.search(query)
+	}
+	issueCache.store(query, issues)
+	return issues
+}
+
+func (searcher *issueSearcher) Search(query string, onResult func([]JIRAIssue)) {
+	searcher.mutex.Lock()
+	defer searcher.mutex.Unlock()
+
+	searcher.stopLocked()
+	if checkJIRA, _ := searchJIRAForTasks.Get(); !checkJIRA {
+		return
+	}
+
+	generation := searcher.generation
+	debounce := time.Duration(trackerConfig.JIRASearch.DebounceMilliseconds) * time.Millisecond
+	searcher.timer = time.AfterFunc(debounce, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(trackerConfig.JIRASearch.TimeoutSeconds)*time.Second)
+		defer cancel()
+
+		searcher.mutex.Lock()
+		if searcher.generation != generation {
+			searcher.mutex.Unlock()
+			return
+		}
+		searcher.cancel = cancel
+		searcher.mutex.Unlock()
+
+		issues := searchIssuesWithCache(ctx, query)
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return
+		}
+		onResult(issues)
+	})
+}
+
+func (searcher *issueSearcher) Stop() {
+	searcher.mutex.Lock()
+	defer searcher.mutex.Unlock()
+	searcher.stopLocked()
+}
+
+func (searcher *issueSearcher) stopLocked() {
+	searcher.generation++
+	if searcher.timer != nil {
+		searcher.timer.Stop()
+		searcher.timer = nil
+	}
+	if searcher.cancel != nil {
+		searcher.cancel()
+		searcher.cancel = nil
+	}
+}
+
+func retrieveIssueCache() {
+	file, err := os.OpenFile(getDataFilePath("issues.cache"), os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		myErrorLogger.Printf("Got error when reading issue cache %s", err.Error())
+		return
+	}
+	defer file.Close()
+
+	var issueCacheRoot IssueCacheRoot
+	err = json.NewDecoder(file).Decode(&issueCacheRoot)
+	if errors.Is(err, io.EOF) {
+		return
+	}
+	if err != nil {
+		myErrorLogger.Printf("Got error when reading issue cache %s", err.Error())
+		return
+	}
+
+	issueCache.mutex.Lock()
+	defer issueCache.mutex.Unlock()
+	for _, issue := range issueCacheRoot.Issues {
+		if time.Since(issue.Fetched) <= issueCacheTTL() {
+			issueCache.issues[getIssueCacheKey(issue)] = issue
+		}
+	}
+	myLogger.Printf("Retrieved issue cache of size %d", len(issueCache.issues))
+}
+
+func saveIssueCache() {
+	issueCache.mutex.Lock()
+	defer issueCache.mutex.Unlock()
+
+	var issueCacheRoot IssueCacheRoot
+	for _, issue := range issueCache.issues {
+		if time.Since(issue.Fetched) <= issueCacheTTL() {
+			issueCacheRoot.Issues = append(issueCacheRoot.Issues, issue)
+		}
+	}
+	sort.SliceStable(issueCacheRoot.Issues, func(i, j int) bool {
+		return issueCacheRoot.Issues[i].Key < issueCacheRoot.Issues[j].Key
+	})
+
+	data, err := json.Marshal(&issueCacheRoot)
+	if err == nil {
+		err = writeFileAtomically(getDataFilePath("issues.cache"), data)
+	}
+	if err != nil {
+		myErrorLogger.Printf("Got error when writing issue cache %s", err.Error())
+		return
+	}
+	myLogger.Printf("wrote %d bytes to issue cache\n", len(data))
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+// The printf style loggers all write to trackerLogger, each on its own
+// level, so that the existing log calls stay as they are.
+var (
+	trackerLogger   *slog.Logger
+	myErrorLogger   *log.Logger
+	myWarningLogger *log.Logger
+	logLevel        = new(slog.LevelVar)
+	logOutput       io.Writer
+	debugLogging    bool
+)
+
+var redactedQueryParameters = []string{"token", "apitoken", "access_token", "refresh_token", "key", "secret", "password", "signature", "verifier"}
+
+// rotatingLogFile starts a new log file once the current one reaches the
+// configured size, and removes old log files beyond the configured number
+// and age.
+type rotatingLogFile struct {
+	mutex sync.Mutex
+	name  string
+	file  *os.File
+	size  int64
+}
+
+type debugTransport struct {
+	base http.RoundTripper
+}
+
+func openRotatingLogFile(name string) (*rotatingLogFile, error) {
+	logFile := &rotatingLogFile{name: name}
+	err := logFile.open()
+	return logFile, err
+}
+
+func (logFile *rotatingLogFile) open() error {
+	file, err := os.OpenFile(logFile.name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	logFile.file = file
+	logFile.size = fileInfo.Size()
+	return nil
+}
+
+func (logFile *rotatingLogFile) Write(p []byte) (int, error) {
+	logFile.mutex.Lock()
+	defer logFile.mutex.Unlock()
+
+	maxSize := int64(trackerConfig.Logging.MaxSizeMB) * 1024 * 1024
+	if maxSize > 0 && logFile.size+int64(len(p)) > maxSize {
+		if err := logFile.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to rotate log file: %s\n", err.Error())
+		}
+	}
+	if logFile.file == nil {
+		return os.Stderr.Write(p)
+	}
+	n, err := logFile.file.Write(p)
+	logFile.size += int64(n)
+	return n, err
+}
+
+func getLogBackupName(name string, number int) string {
+	return fmt.Sprintf("%s.%d", name, number)
+}
+
+func (logFile *rotatingLogFile) rotate() error {
+	logFile.file.Close()
+	logFile.file = nil
+
+	maxBackups := trackerConfig.Logging.MaxBackups
+	if maxBackups < 1 {
+		maxBackups = 1
+	}
+	os.Remove(getLogBackupName(logFile.name, maxBackups))
+	for number := maxBackups - 1; number >= 1; number-- {
+		os.Rename(getLogBackupName(logFile.name, number), getLogBackupName(logFile.name, number+1))
+	}
+	err := os.Rename(logFile.name, getLogBackupName(logFile.name, 1))
+	if err != nil {
+		return err
+	}
+
+	if maxAge := time.Duration(trackerConfig.Logging.MaxAgeDays) * 24 * time.Hour; maxAge > 0 {
+		for number := 1; number <= maxBackups; number++ {
+			backupInfo, err := os.Stat(getLogBackupName(logFile.name, number))
+			if err == nil && time.Since(backupInfo.ModTime()) > maxAge {
+				os.Remove(getLogBackupName(logFile.name, number))
+			}
+		}
+	}
+	return logFile.open()
+}
+
+func newLogHandler(output io.Writer) slog.Handler {
+	options := &slog.HandlerOptions{Level: logLevel, AddSource: true}
+	if strings.EqualFold(trackerConfig.Logging.Format, "json") {
+		return slog.NewJSONHandler(output, options)
+	}
+	return slog.NewTextHandler(output, options)
+}
+
+func setLoggers(output io.Writer) {
+	logOutput = output
+	trackerLogger = slog.New(newLogHandler(output))
+	myLogger = slog.NewLogLogger(trackerLogger.Handler(), slog.LevelInfo)
+	myErrorLogger = slog.NewLogLogger(trackerLogger.Handler(), slog.LevelError)
+	myWarningLogger = slog.NewLogLogger(trackerLogger.Handler(), slog.LevelWarn)
+}
+
+func openTrackerLog() {
+	var output io.Writer = os.Stderr
+	logFile, err := openRotatingLogFile(getDataFilePath("tracker.log"))
+	if err != nil {
+		log.Printf("Failed to open log file: %s", err.Error())
+	} else {
+		output = logFile
+	}
+	setLoggers(output)
+	http.DefaultTransport = &debugTransport{base: http.DefaultTransport}
+}
+
+// configureLogging applies the logging settings of tracker.config, which is
+// read only after the log has been opened.
+func configureLogging() {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(trackerConfig.Logging.Level))
+	if err != nil {
+		myWarningLogger.Printf("Ignoring log level %q: %s", trackerConfig.Logging.Level, err.Error())
+		level = slog.LevelInfo
+	}
+	if debugLogging {
+		level = slog.LevelDebug
+	}
+	logLevel.Set(level)
+	setLoggers(logOutput)
+	myLogger.Printf("Logging at level %s", level.String())
+}
+
+func redactURL(requestURL *url.URL) string {
+	redacted := *requestURL
+	redacted.User = nil
+	query := redacted.Query()
+	for parameter := range query {
+		for _, secret := range redactedQueryParameters {
+			if strings.Contains(strings.ToLower(parameter), secret) {
+				query.Set(parameter, "REDACTED")
+			}
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
+
+func redactHeaders(header http.Header) map[string]string {
+	redacted := make(map[string]string)
+	for name, values := range header {
+		value := strings.Join(values, ", ")
+		switch strings.ToLower(name) {
+		case "authorization", "proxy-authorization":
+			if scheme, _, found := strings.Cut(value, " "); found {
+				value = scheme + " REDACTED"
+			} else {
+				value = "REDACTED"
+			}
+		case "cookie", "set-cookie", "x-api-key":
+			value = "REDACTED"
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+// RoundTrip logs the metadata, never the bodies, of all HTTP requests when
+// logging at debug level.
+func (transport *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !trackerLogger.Enabled(req.Context(), slog.LevelDebug) {
+		return transport.base.RoundTrip(req)
+	}
+
+	started := time.Now()
+	resp, err := transport.base.RoundTrip(req)
+	attributes := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", redactURL(req.URL)),
+		slog.Any("requestHeaders", redactHeaders(req.Header)),
+		slog.Duration("duration", time.Since(started)),
+	}
+	if err != nil {
+		attributes = append(attributes, slog.String("error", err.Error()))
+	} else {
+		attributes = append(attributes,
+			slog.String("status", resp.Status),
+			slog.Int64("contentLength", resp.ContentLength),
+			slog.Any("responseHeaders", redactHeaders(resp.Header)))
+	}
+	trackerLogger.LogAttrs(context.Background(), slog.LevelDebug, "HTTP request", attributes...)
+	return resp, err
+}
+
+// readLogTail returns the end of the current log file, starting at a line.
+func readLogTail(maxBytes int64) (string, error) {
+	file, err := os.Open(getDataFilePath("tracker.log"))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	offset := fileInfo.Size() - maxBytes
+	if offset < 0 {
+		offset = 0
+	}
+	data := make([]byte, fileInfo.Size()-offset)
+	_, err = file.ReadAt(data, offset)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	text := string(data)
+	if offset > 0 {
+		if newline := strings.Index(text, "\n"); newline >= 0 {
+			text = text[newline+1:]
+		}
+	}
+	return text, nil
+}
+
+func showLogsWindow() {
+	logsWindow := fyne.CurrentApp().NewWindow("Logs")
+
+	logEntry := widget.NewMultiLineEntry()
+	logEntry.Wrapping = fyne.TextWrapOff
+	loadLog := func() {
+		text, err := readLogTail(256 * 1024)
+		if err != nil {
+			myErrorLogger.Printf("Got error when reading log %s", err.Error())
+			dialog.NewError(err, logsWindow).Show()
+			return
+		}
+		logEntry.SetText(text)
+		logEntry.CursorRow = strings.Count(text, "\n")
+		logEntry.Refresh()
+	}
+
+	refreshButton := widget.NewButton("Refresh", loadLog)
+	openFolderButton := widget.NewButton("Open Folder", func() {
+		folderURL, err := url.Parse(storage.NewFileURI(filepath.Clean(dataDirectory)).String())
+		if err == nil {
+			err = fyne.CurrentApp().OpenURL(folderURL)
+		}
+		if err != nil {
+			myErrorLogger.Printf("Got error when opening the data directory %s", err.Error())
+			dialog.NewError(err, logsWindow).Show()
+		}
+	})
+
+	logsWindow.SetContent(container.NewBorder(nil, container.NewGridWithColumns(2, refreshButton, openFolderButton), nil, nil, logEntry))
+	logsWindow.Resize(fyne.NewSize(1000, 600))
+	loadLog()
+	logsWindow.Show()
+}
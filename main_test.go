@@ -0,0 +1,12 @@
+package main
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	setLoggers(io.Discard)
+	os.Exit(m.Run())
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/gen2brain/beeep"
+)
+
+var (
+	meetings      []Meeting
+	meetingsFetch time.Time
+	meetingsMutex sync.Mutex
+	// announcedMeetings remembers the meetings whose start or end was
+	// announced already, so that every meeting is announced once
+	announcedMeetings = make(map[string]bool)
+	meetingsWindow    fyne.Window
+	meetingsButton    *widget.Button
+)
+
+func hasCalendar() bool {
+	return len(trackerConfig.Calendar.Sources) > 0
+}
+
+// getMeetingsWindow returns the range in which meetings are read: from the
+// start of the first day to look back on to the end of today.
+func getMeetingsWindow(now time.Time) (time.Time, time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	lookbackDays := max(trackerConfig.Calendar.LookbackDays, 1)
+	return today.AddDate(0, 0, 1-lookbackDays), today.AddDate(0, 0, 1)
+}
+
+// refreshMeetings reads the meetings of all calendar sources. A source that
+// cannot be read keeps none of its meetings rather than failing the others.
+func refreshMeetings() {
+	from, to := getMeetingsWindow(time.Now())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var refreshed []Meeting
+	for _, source := range trackerConfig.Calendar.Sources {
+		sourceMeetings, err := fetchMeetings(ctx, source, from, to)
+		if err != nil {
+			myWarningLogger.Printf("Could not read calendar %s: %s", source.Name, err.Error())
+			continue
+		}
+		refreshed = append(refreshed, sourceMeetings...)
+	}
+	myLogger.Printf("Read %d meetings from %d calendars", len(refreshed), len(trackerConfig.Calendar.Sources))
+
+	meetingsMutex.Lock()
+	meetings = refreshed
+	meetingsMutex.Unlock()
+}
+
+func getMeetings() []Meeting {
+	meetingsMutex.Lock()
+	defer meetingsMutex.Unlock()
+	return append([]Meeting(nil), meetings...)
+}
+
+// tickCalendar is called by the idleness ticker every second. It refreshes
+// the meetings when they are due, offers to switch to the task of a meeting
+// that starts and points out meetings that ended without tracked time.
+func tickCalendar(now time.Time) {
+	if !hasCalendar() {
+		return
+	}
+	meetingsMutex.Lock()
+	refresh := time.Since(meetingsFetch) >= time.Duration(trackerConfig.Calendar.RefreshMinutes)*time.Minute
+	if refresh {
+		meetingsFetch = now
+	}
+	meetingsMutex.Unlock()
+	if refresh {
+		go refreshMeetings()
+	}
+	if now.Second() != 0 {
+		return
+	}
+
+	currentTaskBoundString, _ := currentTask.Get()
+	for _, meeting := range getMeetings() {
+		startKey := "start/" + meeting.key()
+		endKey := "end/" + meeting.key()
+		switch {
+		case trackerConfig.Calendar.OfferSwitch && !now.Before(meeting.Start) && now.Before(meeting.End) && !announcedMeetings[startKey]:
+			announcedMeetings[startKey] = true
+			if entry, mapped := getTaskForMeeting(meeting); mapped && entry.Task != currentTaskBoundString {
+				offerMeetingSwitch(meeting, entry)
+			}
+		// only meetings that just ended, not all past ones after a restart
+		case !now.Before(meeting.End) && now.Sub(meeting.End) < 2*time.Minute && !announcedMeetings[endKey]:
+			announcedMeetings[endKey] = true
+			if !isSuggestionDismissed(meeting.key()) && !isMeetingTracked(meeting, getTrackedIntervals(meeting.Start, now)) {
+				notifyUntrackedMeeting(meeting)
+			}
+		}
+	}
+}
+
+// getTaskForMeeting applies the first calendar rule matching the title of
+// meeting. The task gets the account and activity it had last, unless the
+// rule sets them, and the title of the meeting as its comment.
+func getTaskForMeeting(meeting Meeting) (WorkLogHistoryEntry, bool) {
+	for _, rule := range trackerConfig.Calendar.Rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			myWarningLogger.Printf("Ignoring calendar rule with invalid pattern %s: %s", rule.Pattern, err.Error())
+			continue
+		}
+		match := pattern.FindStringSubmatchIndex(meeting.Summary)
+		if match == nil {
+			continue
+		}
+		task := strings.TrimSpace(string(pattern.ExpandString(nil, rule.Task, meeting.Summary, match)))
+		if task == "" {
+			continue
+		}
+		entry := getHistoryEntryForTask(task)
+		if rule.Account != "" {
+			entry.Account = getElementFromStringWithColon(rule.Account, 0)
+			entry.AccountName = getElementFromStringWithColon(rule.Account, 1)
+		}
+		if rule.Activity != "" {
+			entry.Activity = getActivityValue(rule.Activity)
+		}
+		if rule.Connection != "" {
+			entry.Connection = rule.Connection
+		}
+		entry.Comment = meeting.Summary
+		return entry, true
+	}
+	return WorkLogHistoryEntry{}, false
+}
+
+func isMeetingTracked(meeting Meeting, intervals []trackedInterval) bool {
+	for _, interval := range intervals {
+		if interval.Start.Before(meeting.End) && interval.End.After(meeting.Start) {
+			return true
+		}
+	}
+	return false
+}
+
+// getUntrackedMeetings returns the past meetings without any tracked time
+// that were not dismissed.
+func getUntrackedMeetings(now time.Time) []Meeting {
+	from, _ := getMeetingsWindow(now)
+	intervals := getTrackedIntervals(from, now)
+	var untracked []Meeting
+	for _, meeting := range getMeetings() {
+		if meeting.End.After(now) || isSuggestionDismissed(meeting.key()) || isMeetingTracked(meeting, intervals) {
+			continue
+		}
+		untracked = append(untracked, meeting)
+	}
+	return untracked
+}
+
+func offerMeetingSwitch(meeting Meeting, entry WorkLogHistoryEntry) {
+	myLogger.Printf("Meeting %s started, offering to switch to %s", meeting.Summary, entry.Task)
+	beeep.Beep(beeep.DefaultFreq, beeep.DefaultDuration)
+	dialog.NewConfirm("Meeting", fmt.Sprintf("%s has started.\nSwitch to %s?", meeting.Summary, entry.Task), func(switchTask bool) {
+		if !switchTask {
+			return
+		}
+		if entry.Connection != "" {
+			bindTaskToConnection(entry.Task, entry.Connection)
+		}
+		switchToTask(entry)
+	}, myWindow).Show()
+	myWindow.RequestFocus()
+}
+
+func notifyUntrackedMeeting(meeting Meeting) {
+	myLogger.Printf("Meeting %s ended without tracked time", meeting.Summary)
+	err := beeep.Notify("Meeting without tracked time", fmt.Sprintf("%s ended without tracked time, book it under Meetings", meeting.Summary), "")
+	if err != nil {
+		myWarningLogger.Printf("Could not show notification: %s", err.Error())
+	}
+}
+
+func formatMeeting(meeting Meeting) string {
+	return fmt.Sprintf("%s %s-%s %s", meeting.Start.Format("Mon 02.01."), meeting.Start.Format("15:04"), meeting.End.Format("15:04"), meeting.Summary)
+}
+
+// showMeetingsWindow lists the past meetings without tracked time, each with
+// the task of its calendar rule, to book or dismiss them one by one.
+func showMeetingsWindow() {
+	if meetingsWindow != nil {
+		meetingsWindow.RequestFocus()
+		return
+	}
+	meetingsWindow = fyne.CurrentApp().NewWindow("Meetings")
+	window := meetingsWindow
+	window.SetOnClosed(func() {
+		meetingsWindow = nil
+	})
+
+	rows := container.NewVBox()
+	var fill func()
+	fill = func() {
+		rows.RemoveAll()
+		untracked := getUntrackedMeetings(time.Now())
+		if len(untracked) == 0 {
+			rows.Add(widget.NewLabel("All past meetings are tracked."))
+		}
+		for _, meeting := range untracked {
+			meeting := meeting
+			mapped, found := getTaskForMeeting(meeting)
+			taskEntry := widget.NewEntry()
+			taskEntry.SetPlaceHolder("Task, e.g. ABC-123")
+			if found {
+				taskEntry.SetText(mapped.Task)
+			}
+			bookButton := widget.NewButton("Book", func() {
+				task := strings.TrimSpace(taskEntry.Text)
+				if task == "" {
+					dialog.NewError(errors.New("enter the task to book the meeting on"), window).Show()
+					return
+				}
+				entry := mapped
+				if !found || task != mapped.Task {
+					entry = getHistoryEntryForTask(task)
+					entry.Comment = meeting.Summary
+				}
+				bookPastWork(entry, meeting.Start, meeting.End)
+				fill()
+			})
+			dismissButton := widget.NewButton("Dismiss", func() {
+				from, _ := getMeetingsWindow(time.Now())
+				dismissSuggestion(meeting.key(), meeting.End, from)
+				fill()
+			})
+			taskAndButtons := container.NewBorder(nil, nil, nil, container.NewHBox(bookButton, dismissButton), taskEntry)
+			rows.Add(container.NewBorder(nil, nil, nil, container.NewGridWrap(fyne.NewSize(340, 38), taskAndButtons), widget.NewLabel(formatMeeting(meeting))))
+		}
+		rows.Refresh()
+	}
+	refreshButton := widget.NewButton("Refresh", func() {
+		go func() {
+			refreshMeetings()
+			fill()
+		}()
+	})
+
+	fill()
+	window.SetContent(container.NewBorder(nil, refreshButton, nil, nil, container.NewVScroll(rows)))
+	window.Resize(fyne.NewSize(800, 400))
+	window.CenterOnScreen()
+	window.Show()
+}
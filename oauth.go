@@ -0,0 +1,637 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+const (
+	authToken  = "token"
+	authOAuth2 = "oauth2"
+	authOAuth1 = "oauth1"
+
+	atlassianAuthorizationURL = "https://auth.atlassian.com/authorize"
+	atlassianTokenURL         = "https://auth.atlassian.com/oauth/token"
+	atlassianResourcesURL     = "https://api.atlassian.com/oauth/token/accessible-resources"
+	atlassianAPIBaseURL       = "https://api.atlassian.com/ex/jira"
+	atlassianAudience         = "api.atlassian.com"
+
+	defaultOAuthRedirectPort = 47914
+	oauthFlowTimeout         = 5 * time.Minute
+	oauthFlowRetryDelay      = time.Minute
+)
+
+// oauthAuthenticator authorizes the requests of a connection with OAuth 2.0
+// or OAuth 1.0a. When no credentials are stored it runs the authorization in
+// the browser, with a listener on the loopback interface receiving the
+// redirect. OAuth 2.0 access tokens are refreshed shortly before they expire.
+// The mutex is not held while the browser is open; flow is closed when the
+// running authorization ends, and other requests wait for it.
+type oauthAuthenticator struct {
+	mutex       sync.Mutex
+	connection  ConnectionConfig
+	credentials StoredCredentials
+	privateKey  *rsa.PrivateKey
+	lastFailure time.Time
+	lastErr     error
+	flow        chan struct{}
+}
+
+type oauth2TokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	TokenType        string `json:"token_type"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type oauth2Resource struct {
+	ID   string `json:"id"`
+	URL  string `json:"url"`
+	Name string `json:"name"`
+}
+
+type oauthCallback struct {
+	values url.Values
+	err    error
+}
+
+func newOAuthAuthenticator(config ConnectionConfig) *oauthAuthenticator {
+	authenticator := &oauthAuthenticator{connection: config}
+	authenticator.credentials, _ = loadCredentials(config.Name)
+	return authenticator
+}
+
+// Authorize makes sure that there are usable credentials, refreshing or
+// authorizing again as needed. A failed authorization is not repeated for a
+// while, so that a closed browser tab does not open the next one right away.
+func (auth *oauthAuthenticator) Authorize() error {
+	auth.mutex.Lock()
+	for auth.flow != nil {
+		flow := auth.flow
+		auth.mutex.Unlock()
+		<-flow
+		auth.mutex.Lock()
+	}
+	if auth.connection.Auth == authOAuth1 && auth.privateKey == nil {
+		privateKey, err := readRSAPrivateKey(auth.connection.OAuth.PrivateKeyFile)
+		if err != nil {
+			auth.mutex.Unlock()
+			return err
+		}
+		auth.privateKey = privateKey
+	}
+	if auth.hasUsableCredentials() {
+		auth.mutex.Unlock()
+		return nil
+	}
+	if !auth.lastFailure.IsZero() && time.Since(auth.lastFailure) < oauthFlowRetryDelay {
+		defer auth.mutex.Unlock()
+		return auth.lastErr
+	}
+	flow := make(chan struct{})
+	auth.flow = flow
+	auth.mutex.Unlock()
+
+	myLogger.Printf("Authorizing %s with %s", auth.connection.Name, auth.connection.Auth)
+	var credentials StoredCredentials
+	var err error
+	if auth.connection.Auth == authOAuth1 {
+		credentials, err = auth.runOAuth1Flow()
+	} else {
+		credentials, err = auth.runOAuth2Flow()
+	}
+
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	auth.flow = nil
+	close(flow)
+	if err != nil {
+		auth.lastFailure = time.Now()
+		auth.lastErr = fmt.Errorf("authorization of %s failed: %w", auth.connection.Name, err)
+		return auth.lastErr
+	}
+	auth.lastFailure = time.Time{}
+	auth.credentials = credentials
+	auth.saveCredentials()
+	return nil
+}
+
+// hasUsableCredentials refreshes an OAuth 2.0 access token that is about to
+// expire. The mutex must be held.
+func (auth *oauthAuthenticator) hasUsableCredentials() bool {
+	if auth.connection.Auth == authOAuth1 || auth.credentials.AccessToken == "" {
+		return auth.credentials.AccessToken != ""
+	}
+	if auth.credentials.Expiry.IsZero() || time.Until(auth.credentials.Expiry) > time.Minute {
+		return true
+	}
+	if auth.credentials.RefreshToken != "" {
+		err := auth.refreshOAuth2Token()
+		if err == nil {
+			return true
+		}
+		myWarningLogger.Printf("Could not refresh the access token of %s, authorizing again: %s", auth.connection.Name, err.Error())
+	}
+	return false
+}
+
+// BaseURL returns the API gateway of a JIRA Cloud site authorized with
+// OAuth 2.0, as such tokens are not accepted by the site itself.
+func (auth *oauthAuthenticator) BaseURL() string {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	if auth.credentials.CloudID == "" || auth.connection.OAuth.APIBaseURL == "" {
+		return ""
+	}
+	return strings.TrimRight(auth.connection.OAuth.APIBaseURL, "/") + "/" + auth.credentials.CloudID
+}
+
+func (auth *oauthAuthenticator) Authenticate(req *http.Request) error {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	if auth.connection.Auth == authOAuth1 {
+		return auth.signOAuth1Request(req, auth.credentials.AccessToken, nil)
+	}
+	req.Header.Set("Authorization", "Bearer "+auth.credentials.AccessToken)
+	return nil
+}
+
+// Refresh renews OAuth 2.0 credentials that JIRA rejected before they
+// expired. OAuth 1.0a access tokens cannot be renewed.
+func (auth *oauthAuthenticator) Refresh() error {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	if auth.connection.Auth == authOAuth1 || auth.credentials.RefreshToken == "" {
+		return errors.New("the credentials cannot be refreshed")
+	}
+	return auth.refreshOAuth2Token()
+}
+
+// Invalidate forgets credentials that JIRA rejected, so that the next
+// request authorizes again.
+func (auth *oauthAuthenticator) Invalidate() {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	myWarningLogger.Printf("%s rejected the credentials, they are removed", auth.connection.Name)
+	auth.credentials = StoredCredentials{}
+	auth.saveCredentials()
+}
+
+func (auth *oauthAuthenticator) saveCredentials() {
+	err := saveCredentials(auth.connection.Name, auth.credentials)
+	if err != nil {
+		myErrorLogger.Printf("Got error when writing credentials %s", err.Error())
+	}
+}
+
+func randomToken(size int) string {
+	data := make([]byte, size)
+	rand.Read(data)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// listenForOAuthCallback opens the loopback listener the authorization
+// server redirects the browser to.
+func listenForOAuthCallback(port int) (net.Listener, string, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, "", err
+	}
+	redirectURI := fmt.Sprintf("http://127.0.0.1:%d/callback", listener.Addr().(*net.TCPAddr).Port)
+	return listener, redirectURI, nil
+}
+
+// waitForOAuthCallback serves the redirect of the authorization server and
+// returns its parameters once check accepts them.
+func waitForOAuthCallback(listener net.Listener, check func(url.Values) error) (url.Values, error) {
+	callbacks := make(chan oauthCallback, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/callback" {
+			http.NotFound(w, r)
+			return
+		}
+		values := r.URL.Query()
+		err := check(values)
+		if err != nil {
+			http.Error(w, "Authorization failed: "+err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprint(w, "MyTracker is authorized, you can close this window.")
+		}
+		select {
+		case callbacks <- oauthCallback{values: values, err: err}:
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	select {
+	case callback := <-callbacks:
+		return callback.values, callback.err
+	case <-time.After(oauthFlowTimeout):
+		return nil, errors.New("timed out waiting for the authorization in the browser")
+	}
+}
+
+// openURLInBrowser opens the authorization page, tests answer it instead.
+var openURLInBrowser = func(pageURL *url.URL) error {
+	return fyne.CurrentApp().OpenURL(pageURL)
+}
+
+func openAuthorizationPage(authorizationURL string) error {
+	parsedURL, err := url.Parse(authorizationURL)
+	if err != nil {
+		return err
+	}
+	myLogger.Printf("Opening the authorization page %s", redactURL(parsedURL))
+	return openURLInBrowser(parsedURL)
+}
+
+// runOAuth2Flow runs the authorization code flow with PKCE and, for JIRA
+// Cloud, looks up the cloud ID of the site.
+func (auth *oauthAuthenticator) runOAuth2Flow() (StoredCredentials, error) {
+	var credentials StoredCredentials
+	config := auth.connection.OAuth
+	listener, redirectURI, err := listenForOAuthCallback(config.RedirectPort)
+	if err != nil {
+		return credentials, err
+	}
+	defer listener.Close()
+
+	state := randomToken(16)
+	verifier := randomToken(32)
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{}
+	query.Set("client_id", config.ClientID)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("response_type", "code")
+	query.Set("scope", strings.Join(config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	if config.Audience != "" {
+		query.Set("audience", config.Audience)
+		query.Set("prompt", "consent")
+	}
+	err = openAuthorizationPage(config.AuthorizationURL + "?" + query.Encode())
+	if err != nil {
+		return credentials, err
+	}
+
+	values, err := waitForOAuthCallback(listener, func(values url.Values) error {
+		if values.Get("state") != state {
+			return errors.New("the state does not match")
+		}
+		if authError := values.Get("error"); authError != "" {
+			return fmt.Errorf("%s: %s", authError, values.Get("error_description"))
+		}
+		if values.Get("code") == "" {
+			return errors.New("no authorization code received")
+		}
+		return nil
+	})
+	if err != nil {
+		return credentials, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", values.Get("code"))
+	form.Set("redirect_uri", redirectURI)
+	form.Set("code_verifier", verifier)
+	credentials, err = auth.requestOAuth2Token(form)
+	if err != nil {
+		return credentials, err
+	}
+	if config.ResourcesURL != "" {
+		credentials.CloudID, err = auth.getOAuth2CloudID(credentials.AccessToken)
+	}
+	return credentials, err
+}
+
+func (auth *oauthAuthenticator) refreshOAuth2Token() error {
+	myLogger.Printf("Refreshing the access token of %s", auth.connection.Name)
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", auth.credentials.RefreshToken)
+	credentials, err := auth.requestOAuth2Token(form)
+	if err != nil {
+		return err
+	}
+	// refresh tokens may be rotated or not
+	if credentials.RefreshToken == "" {
+		credentials.RefreshToken = auth.credentials.RefreshToken
+	}
+	credentials.CloudID = auth.credentials.CloudID
+	auth.credentials = credentials
+	auth.saveCredentials()
+	return nil
+}
+
+func (auth *oauthAuthenticator) requestOAuth2Token(form url.Values) (StoredCredentials, error) {
+	var credentials StoredCredentials
+	form.Set("client_id", auth.connection.OAuth.ClientID)
+	if auth.connection.OAuth.ClientSecret != "" {
+		form.Set("client_secret", auth.connection.OAuth.ClientSecret)
+	}
+	client := &http.Client{Timeout: apiDefaultTimeout}
+	resp, err := client.PostForm(auth.connection.OAuth.TokenURL, form)
+	if err != nil {
+		return credentials, err
+	}
+	defer resp.Body.Close()
+
+	var tokenResponse oauth2TokenResponse
+	err = json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&tokenResponse)
+	if resp.StatusCode != http.StatusOK {
+		if tokenResponse.Error != "" {
+			return credentials, fmt.Errorf("token endpoint returned %s: %s %s", resp.Status, tokenResponse.Error, tokenResponse.ErrorDescription)
+		}
+		return credentials, fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+	if err != nil {
+		return credentials, err
+	}
+	if tokenResponse.AccessToken == "" {
+		return credentials, errors.New("token endpoint returned no access token")
+	}
+	credentials.AccessToken = tokenResponse.AccessToken
+	credentials.RefreshToken = tokenResponse.RefreshToken
+	if tokenResponse.ExpiresIn > 0 {
+		credentials.Expiry = time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second)
+	}
+	return credentials, nil
+}
+
+// getOAuth2CloudID finds the site of the connection among the sites the
+// token grants access to.
+func (auth *oauthAuthenticator) getOAuth2CloudID(accessToken string) (string, error) {
+	req, err := http.NewRequest("GET", auth.connection.OAuth.ResourcesURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	client := &http.Client{Timeout: apiDefaultTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("accessible resources returned %s", resp.Status)
+	}
+
+	var resources []oauth2Resource
+	err = json.NewDecoder(resp.Body).Decode(&resources)
+	if err != nil {
+		return "", err
+	}
+	siteURL := strings.TrimRight(auth.connection.BaseURL, "/")
+	for _, resource := range resources {
+		if strings.EqualFold(strings.TrimRight(resource.URL, "/"), siteURL) {
+			return resource.ID, nil
+		}
+	}
+	if len(resources) == 1 {
+		return resources[0].ID, nil
+	}
+	return "", fmt.Errorf("the authorization does not include %s", siteURL)
+}
+
+// runOAuth1Flow runs the three-legged OAuth 1.0a flow of a JIRA application
+// link: a request token, its authorization in the browser, and its exchange
+// for an access token.
+func (auth *oauthAuthenticator) runOAuth1Flow() (StoredCredentials, error) {
+	var credentials StoredCredentials
+	config := auth.connection.OAuth
+	listener, redirectURI, err := listenForOAuthCallback(config.RedirectPort)
+	if err != nil {
+		return credentials, err
+	}
+	defer listener.Close()
+
+	requestToken, err := auth.postOAuth1(config.RequestTokenURL, "", map[string]string{"oauth_callback": redirectURI})
+	if err != nil {
+		return credentials, err
+	}
+	token := requestToken.Get("oauth_token")
+	if token == "" {
+		return credentials, errors.New("no request token received")
+	}
+	err = openAuthorizationPage(config.AuthorizationURL + "?oauth_token=" + url.QueryEscape(token))
+	if err != nil {
+		return credentials, err
+	}
+
+	values, err := waitForOAuthCallback(listener, func(values url.Values) error {
+		if values.Get("oauth_token") != token {
+			return errors.New("the request token does not match")
+		}
+		if verifier := values.Get("oauth_verifier"); verifier == "" || verifier == "denied" {
+			return errors.New("the access was denied")
+		}
+		return nil
+	})
+	if err != nil {
+		return credentials, err
+	}
+
+	accessToken, err := auth.postOAuth1(config.AccessTokenURL, token, map[string]string{"oauth_verifier": values.Get("oauth_verifier")})
+	if err != nil {
+		return credentials, err
+	}
+	if accessToken.Get("oauth_token") == "" {
+		return credentials, errors.New("no access token received")
+	}
+	credentials.AccessToken = accessToken.Get("oauth_token")
+	credentials.TokenSecret = accessToken.Get("oauth_token_secret")
+	return credentials, nil
+}
+
+func (auth *oauthAuthenticator) postOAuth1(endpoint string, token string, parameters map[string]string) (url.Values, error) {
+	req, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = auth.signOAuth1Request(req, token, parameters)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: apiDefaultTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s: %s", endpoint, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return url.ParseQuery(string(body))
+}
+
+// signOAuth1Request adds an RSA-SHA1 signed OAuth authorization header.
+// With RSA-SHA1 the token secret is not part of the signature.
+func (auth *oauthAuthenticator) signOAuth1Request(req *http.Request, token string, parameters map[string]string) error {
+	oauthParameters := map[string]string{
+		"oauth_consumer_key":     auth.connection.OAuth.ConsumerKey,
+		"oauth_nonce":            randomToken(16),
+		"oauth_signature_method": "RSA-SHA1",
+		"oauth_timestamp":        strconv.FormatInt(time.Now().Unix(), 10),
+		"oauth_version":          "1.0",
+	}
+	if token != "" {
+		oauthParameters["oauth_token"] = token
+	}
+	for name, value := range parameters {
+		oauthParameters[name] = value
+	}
+
+	hash := sha1.Sum([]byte(getOAuth1SignatureBase(req.Method, req.URL, oauthParameters)))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, auth.privateKey, crypto.SHA1, hash[:])
+	if err != nil {
+		return err
+	}
+	oauthParameters["oauth_signature"] = base64.StdEncoding.EncodeToString(signature)
+
+	names := make([]string, 0, len(oauthParameters))
+	for name := range oauthParameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var header []string
+	for _, name := range names {
+		header = append(header, fmt.Sprintf(`%s="%s"`, oauthEscape(name), oauthEscape(oauthParameters[name])))
+	}
+	req.Header.Set("Authorization", "OAuth "+strings.Join(header, ", "))
+	return nil
+}
+
+// getOAuth1SignatureBase builds the signature base string of RFC 5849 from
+// the method, the URL without query and the sorted OAuth and query
+// parameters.
+func getOAuth1SignatureBase(method string, requestURL *url.URL, oauthParameters map[string]string) string {
+	var pairs [][2]string
+	for name, value := range oauthParameters {
+		pairs = append(pairs, [2]string{oauthEscape(name), oauthEscape(value)})
+	}
+	for name, values := range requestURL.Query() {
+		for _, value := range values {
+			pairs = append(pairs, [2]string{oauthEscape(name), oauthEscape(value)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] == pairs[j][0] {
+			return pairs[i][1] < pairs[j][1]
+		}
+		return pairs[i][0] < pairs[j][0]
+	})
+	var normalized []string
+	for _, pair := range pairs {
+		normalized = append(normalized, pair[0]+"="+pair[1])
+	}
+
+	scheme := strings.ToLower(requestURL.Scheme)
+	host := strings.ToLower(requestURL.Hostname())
+	if port := requestURL.Port(); port != "" && !(scheme == "http" && port == "80") && !(scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	path := requestURL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	baseURL := scheme + "://" + host + path
+	return strings.ToUpper(method) + "&" + oauthEscape(baseURL) + "&" + oauthEscape(strings.Join(normalized, "&"))
+}
+
+// oauthEscape percent-encodes everything but the unreserved characters, as
+// OAuth 1.0a requires and url.QueryEscape does not quite do.
+func oauthEscape(text string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			escaped.WriteByte(c)
+		} else {
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+// readRSAPrivateKey reads the PEM encoded PKCS #1 or PKCS #8 key of the
+// application link, relative paths from the data directory.
+func readRSAPrivateKey(name string) (*rsa.PrivateKey, error) {
+	if name == "" {
+		return nil, errors.New("no private key file configured")
+	}
+	if !filepath.IsAbs(name) {
+		name = getDataFilePath(name)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s contains no PEM encoded key", name)
+	}
+	if privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return privateKey, nil
+	}
+	parsedKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := parsedKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s contains no RSA key", name)
+	}
+	return privateKey, nil
+}
+
+// authorizeConnections runs the OAuth authorizations at startup, rather than
+// in the middle of the first search.
+func authorizeConnections() {
+	for _, connection := range jiraConnections {
+		if connection.JIRA.Authenticator == nil {
+			continue
+		}
+		go func(connection *jiraConnection) {
+			err := connection.JIRA.Authenticator.Authorize()
+			if err != nil {
+				myErrorLogger.Printf("Got error %s", err.Error())
+				dialog.NewError(err, myWindow).Show()
+			}
+		}(connection)
+	}
+}
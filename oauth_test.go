@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testOAuth2Server is an authorization server with a token endpoint that
+// checks the PKCE verifier against the challenge of the authorization page.
+type testOAuth2Server struct {
+	*httptest.Server
+	mutex        sync.Mutex
+	challenge    string
+	redirectURI  string
+	refreshToken string
+	forms        []url.Values
+}
+
+func newTestOAuth2Server(t *testing.T) *testOAuth2Server {
+	server := &testOAuth2Server{refreshToken: "refresh-1"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		server.forms = append(server.forms, r.PostForm)
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("client_id") != "client" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(oauth2TokenResponse{Error: "invalid_client"})
+			return
+		}
+		switch r.PostForm.Get("grant_type") {
+		case "authorization_code":
+			verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+			if r.PostForm.Get("code") != "code-1" || r.PostForm.Get("redirect_uri") != server.redirectURI || base64.RawURLEncoding.EncodeToString(verifier[:]) != server.challenge {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(oauth2TokenResponse{Error: "invalid_grant", ErrorDescription: "code or verifier rejected"})
+				return
+			}
+			json.NewEncoder(w).Encode(oauth2TokenResponse{AccessToken: "access-1", RefreshToken: "refresh-1", ExpiresIn: 3600})
+		case "refresh_token":
+			if r.PostForm.Get("refresh_token") == "revoked" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(oauth2TokenResponse{Error: "invalid_grant"})
+				return
+			}
+			json.NewEncoder(w).Encode(oauth2TokenResponse{AccessToken: "access-2", RefreshToken: server.refreshToken, ExpiresIn: 3600})
+		}
+	})
+	mux.HandleFunc("/oauth/token/accessible-resources", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode([]oauth2Resource{
+			{ID: "cloud-2", URL: "https://other.atlassian.net"},
+			{ID: "cloud-1", URL: "https://example.atlassian.net/"},
+		})
+	})
+	server.Server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (server *testOAuth2Server) newAuthenticator() *oauthAuthenticator {
+	return &oauthAuthenticator{connection: ConnectionConfig{
+		Name:    "Test",
+		Type:    connectionTypeCloud,
+		BaseURL: "https://example.atlassian.net",
+		Auth:    authOAuth2,
+		OAuth: OAuthConfig{
+			ClientID:         "client",
+			Scopes:           []string{"read:jira-work", "offline_access"},
+			AuthorizationURL: server.URL + "/authorize",
+			TokenURL:         server.URL + "/oauth/token",
+			ResourcesURL:     server.URL + "/oauth/token/accessible-resources",
+			APIBaseURL:       server.URL + "/ex/jira",
+		},
+	}}
+}
+
+// useTestBrowser answers the authorization page with the callback that
+// answer derives from its parameters.
+func useTestBrowser(t *testing.T, server *testOAuth2Server, answer func(page url.Values) url.Values) {
+	previous := openURLInBrowser
+	t.Cleanup(func() { openURLInBrowser = previous })
+	openURLInBrowser = func(pageURL *url.URL) error {
+		page := pageURL.Query()
+		if page.Get("code_challenge_method") != "S256" || page.Get("response_type") != "code" {
+			t.Errorf("authorization page %s does not ask for a code with PKCE", pageURL)
+		}
+		server.mutex.Lock()
+		server.challenge = page.Get("code_challenge")
+		server.redirectURI = page.Get("redirect_uri")
+		server.mutex.Unlock()
+		go func() {
+			resp, err := http.Get(page.Get("redirect_uri") + "?" + answer(page).Encode())
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+		return nil
+	}
+}
+
+func TestRunOAuth2Flow(t *testing.T) {
+	tests := []struct {
+		name    string
+		answer  func(page url.Values) url.Values
+		wantErr string
+	}{
+		{
+			name: "authorized",
+			answer: func(page url.Values) url.Values {
+				return url.Values{"code": {"code-1"}, "state": {page.Get("state")}}
+			},
+		},
+		{
+			name: "state of another flow",
+			answer: func(page url.Values) url.Values {
+				return url.Values{"code": {"code-1"}, "state": {"forged"}}
+			},
+			wantErr: "the state does not match",
+		},
+		{
+			name: "access denied",
+			answer: func(page url.Values) url.Values {
+				return url.Values{"error": {"access_denied"}, "error_description": {"User did not authorize the request"}, "state": {page.Get("state")}}
+			},
+			wantErr: "access_denied: User did not authorize the request",
+		},
+		{
+			name: "code rejected",
+			answer: func(page url.Values) url.Values {
+				return url.Values{"code": {"code-2"}, "state": {page.Get("state")}}
+			},
+			wantErr: "invalid_grant",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestOAuth2Server(t)
+			useTestBrowser(t, server, test.answer)
+			auth := server.newAuthenticator()
+
+			credentials, err := auth.runOAuth2Flow()
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("runOAuth2Flow() error %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("runOAuth2Flow() error %v", err)
+			}
+			if credentials.AccessToken != "access-1" || credentials.RefreshToken != "refresh-1" || credentials.CloudID != "cloud-1" {
+				t.Errorf("runOAuth2Flow() = %+v", credentials)
+			}
+			if until := time.Until(credentials.Expiry); until < 59*time.Minute || until > time.Hour {
+				t.Errorf("access token expires in %s, want an hour", until)
+			}
+		})
+	}
+}
+
+func TestRefreshOAuth2Token(t *testing.T) {
+	tests := []struct {
+		name             string
+		refreshToken     string
+		wantRefreshToken string
+	}{
+		{name: "rotated", refreshToken: "refresh-2", wantRefreshToken: "refresh-2"},
+		{name: "not rotated", refreshToken: "", wantRefreshToken: "refresh-1"},
+	}
+	useTempDataDirectory(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestOAuth2Server(t)
+			server.refreshToken = test.refreshToken
+			auth := server.newAuthenticator()
+			auth.credentials = StoredCredentials{AccessToken: "access-1", RefreshToken: "refresh-1", CloudID: "cloud-1"}
+
+			if err := auth.refreshOAuth2Token(); err != nil {
+				t.Fatalf("refreshOAuth2Token() error %v", err)
+			}
+			if form := server.forms[0]; form.Get("grant_type") != "refresh_token" || form.Get("refresh_token") != "refresh-1" {
+				t.Errorf("token request %v does not refresh refresh-1", form)
+			}
+			want := StoredCredentials{AccessToken: "access-2", RefreshToken: test.wantRefreshToken, CloudID: "cloud-1"}
+			got := auth.credentials
+			got.Expiry = time.Time{}
+			if got != want {
+				t.Errorf("credentials = %+v, want %+v", got, want)
+			}
+			if stored, _ := loadCredentials("Test"); stored.RefreshToken != test.wantRefreshToken {
+				t.Errorf("stored refresh token %q, want %q", stored.RefreshToken, test.wantRefreshToken)
+			}
+		})
+	}
+}
+
+func TestAuthorizeReleasesMutexDuringFlow(t *testing.T) {
+	useTempDataDirectory(t)
+	server := newTestOAuth2Server(t)
+	auth := server.newAuthenticator()
+
+	opened := make(chan url.Values, 2)
+	answer := make(chan struct{})
+	previous := openURLInBrowser
+	defer func() { openURLInBrowser = previous }()
+	openURLInBrowser = func(pageURL *url.URL) error {
+		page := pageURL.Query()
+		server.mutex.Lock()
+		server.challenge = page.Get("code_challenge")
+		server.redirectURI = page.Get("redirect_uri")
+		server.mutex.Unlock()
+		opened <- page
+		go func() {
+			<-answer
+			resp, err := http.Get(page.Get("redirect_uri") + "?" + url.Values{"code": {"code-1"}, "state": {page.Get("state")}}.Encode())
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+		return nil
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- auth.Authorize() }()
+	<-opened
+
+	// while the browser is open, requests neither block nor open another page
+	done := make(chan struct{})
+	go func() {
+		auth.BaseURL()
+		auth.Authenticate(httptest.NewRequest("GET", "https://example.atlassian.net", nil))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the authenticator stays locked while waiting for the browser")
+	}
+	go func() { errs <- auth.Authorize() }()
+	time.Sleep(100 * time.Millisecond)
+	close(answer)
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Authorize() error %v", err)
+		}
+	}
+	if len(opened) != 0 {
+		t.Error("a second authorization page was opened")
+	}
+	if auth.BaseURL() != server.URL+"/ex/jira/cloud-1" {
+		t.Errorf("BaseURL() = %q", auth.BaseURL())
+	}
+	if stored, _ := loadCredentials("Test"); stored.AccessToken != "access-1" {
+		t.Errorf("stored access token %q, want access-1", stored.AccessToken)
+	}
+}
+
+func TestAPIClientRefreshesRejectedCredentials(t *testing.T) {
+	tests := []struct {
+		name          string
+		refreshToken  string
+		wantErr       bool
+		wantAccess    string
+		wantRequests  int
+		wantRefreshes int
+	}{
+		{name: "refreshed", refreshToken: "refresh-1", wantAccess: "access-2", wantRequests: 2, wantRefreshes: 1},
+		{name: "refresh token revoked", refreshToken: "revoked", wantErr: true, wantAccess: "", wantRequests: 1, wantRefreshes: 1},
+	}
+	useTempDataDirectory(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestOAuth2Server(t)
+			requests := 0
+			jira := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if r.Header.Get("Authorization") != "Bearer access-2" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.Write([]byte(`{"key":"JIRAUSER1"}`))
+			}))
+			defer jira.Close()
+
+			auth := server.newAuthenticator()
+			auth.credentials = StoredCredentials{AccessToken: "access-1", RefreshToken: test.refreshToken, Expiry: time.Now().Add(time.Hour)}
+			client := newAPIClient(jira.URL, "")
+			client.Authenticator = auth
+			client.Trace = nil
+
+			var user JIRAUser
+			err := client.Do(context.Background(), "POST", "/rest/api/2/myself", nil, &user)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Do() error %v", err)
+			}
+			if !test.wantErr && user.Key != "JIRAUSER1" {
+				t.Errorf("Do() decoded %+v", user)
+			}
+			if auth.credentials.AccessToken != test.wantAccess {
+				t.Errorf("access token %q, want %q", auth.credentials.AccessToken, test.wantAccess)
+			}
+			if requests != test.wantRequests || len(server.forms) != test.wantRefreshes {
+				t.Errorf("sent %d requests and %d refreshes, want %d and %d", requests, len(server.forms), test.wantRequests, test.wantRefreshes)
+			}
+		})
+	}
+}
+
+func TestGetOAuth1SignatureBase(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		parameters map[string]string
+		want       string
+	}{
+		{
+			// RFC 5849 section 1.2
+			name:   "photos example",
+			method: "GET",
+			url:    "http://photos.example.net/photos?file=vacation.jpg&size=original",
+			parameters: map[string]string{
+				"oauth_consumer_key":     "dpf43f3p2l4k3l03",
+				"oauth_token":            "nnch734d00sl2jdk",
+				"oauth_signature_method": "HMAC-SHA1",
+				"oauth_timestamp":        "137131202",
+				"oauth_nonce":            "chapoH",
+			},
+			want: "GET&http%3A%2F%2Fphotos.example.net%2Fphotos&file%3Dvacation.jpg%26oauth_consumer_key%3Ddpf43f3p2l4k3l03%26oauth_nonce%3DchapoH%26oauth_signature_method%3DHMAC-SHA1%26oauth_timestamp%3D137131202%26oauth_token%3Dnnch734d00sl2jdk%26size%3Doriginal",
+		},
+		{
+			// RFC 5849 section 3.4.1.1, with the body parameters c2 and a3
+			name:   "repeated and encoded parameters",
+			method: "post",
+			url:    "http://example.com/request?b5=%3D%253D&a3=a&c%40=&a2=r%20b",
+			parameters: map[string]string{
+				"oauth_consumer_key":     "9djdj82h48djs9d2",
+				"oauth_token":            "kkk9d7dh3k39sjv7",
+				"oauth_signature_method": "HMAC-SHA1",
+				"oauth_timestamp":        "137131201",
+				"oauth_nonce":            "7d8f3e4a",
+				"c2":                     "",
+				"a3":                     "2 q",
+			},
+			want: "POST&http%3A%2F%2Fexample.com%2Frequest&a2%3Dr%2520b%26a3%3D2%2520q%26a3%3Da%26b5%3D%253D%25253D%26c%2540%3D%26c2%3D%26oauth_consumer_key%3D9djdj82h48djs9d2%26oauth_nonce%3D7d8f3e4a%26oauth_signature_method%3DHMAC-SHA1%26oauth_timestamp%3D137131201%26oauth_token%3Dkkk9d7dh3k39sjv7",
+		},
+		{
+			// RFC 5849 section 3.4.1.2
+			name:   "default port and case of the base URI",
+			method: "GET",
+			url:    "HTTP://EXAMPLE.COM:80/r%20v/X?id=123",
+			want:   "GET&http%3A%2F%2Fexample.com%2Fr%2520v%2FX&id%3D123",
+		},
+		{
+			name:   "other port of the base URI",
+			method: "GET",
+			url:    "https://www.example.net:8080/?q=1",
+			want:   "GET&https%3A%2F%2Fwww.example.net%3A8080%2F&q%3D1",
+		},
+		{
+			name:   "default port of https",
+			method: "GET",
+			url:    "https://jira.example.com:443",
+			want:   "GET&https%3A%2F%2Fjira.example.com%2F&",
+		},
+	}
+	for _, test := range tests {
+		requestURL, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatalf("url.Parse(%q) error %v", test.url, err)
+		}
+		if got := getOAuth1SignatureBase(test.method, requestURL, test.parameters); got != test.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSignOAuth1Request(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := &oauthAuthenticator{
+		connection: ConnectionConfig{Auth: authOAuth1, OAuth: OAuthConfig{ConsumerKey: "timetracker"}},
+		privateKey: privateKey,
+	}
+	req := httptest.NewRequest("POST", "https://jira.example.com/plugins/servlet/oauth/access-token?a=b%20c", nil)
+	err = auth.signOAuth1Request(req, "request token", map[string]string{"oauth_verifier": "v/1"})
+	if err != nil {
+		t.Fatalf("signOAuth1Request() error %v", err)
+	}
+
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "OAuth ") {
+		t.Fatalf("Authorization = %q", header)
+	}
+	parameters := make(map[string]string)
+	for _, field := range strings.Split(strings.TrimPrefix(header, "OAuth "), ", ") {
+		name, quoted, found := strings.Cut(field, "=")
+		value, err := url.PathUnescape(strings.Trim(quoted, `"`))
+		if !found || err != nil {
+			t.Fatalf("malformed field %q in %q", field, header)
+		}
+		parameters[name] = value
+	}
+	want := map[string]string{
+		"oauth_consumer_key":     "timetracker",
+		"oauth_token":            "request token",
+		"oauth_verifier":         "v/1",
+		"oauth_signature_method": "RSA-SHA1",
+		"oauth_version":          "1.0",
+	}
+	for name, value := range want {
+		if parameters[name] != value {
+			t.Errorf("%s = %q, want %q", name, parameters[name], value)
+		}
+	}
+	if !strings.Contains(header, `oauth_token="request%20token"`) || !strings.Contains(header, `oauth_verifier="v%2F1"`) {
+		t.Errorf("Authorization %q is not percent-encoded", header)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(parameters["oauth_signature"])
+	if err != nil {
+		t.Fatalf("oauth_signature is not base64: %v", err)
+	}
+	delete(parameters, "oauth_signature")
+	hash := sha1.Sum([]byte(getOAuth1SignatureBase(req.Method, req.URL, parameters)))
+	if err := rsa.VerifyPKCS1v15(&privateKey.PublicKey, crypto.SHA1, hash[:], signature); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+}
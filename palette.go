@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+)
+
+var quickSwitchWindow fyne.Window
+
+func switchToTask(entry WorkLogHistoryEntry) {
+	if working {
+		stopWorkAndResetUI()
+	}
+	myLogger.Printf("Quick switching to %s", entry.Task)
+	startWorkAndResetUI(entry.Task, entry.TaskName, entry.Account, entry.AccountName, entry.Comment, entry.Activity)
+}
+
+// newHistoryEntryForIssue builds the entry to start an issue that is not in
+// the history yet, with the preselected account and activity of the issue.
+func newHistoryEntryForIssue(issue string, summary string) WorkLogHistoryEntry {
+	_, standardAccount, _ := getAccountOptionsForIssue(getConnectionForTask(issue), issue)
+	entry := WorkLogHistoryEntry{
+		Task:       issue,
+		TaskName:   summary,
+		Activity:   getDefaultActivityForTask(issue),
+		Connection: getTaskConnectionName(issue),
+	}
+	if standardAccount != "" {
+		entry.Account = getElementFromStringWithColon(standardAccount, 0)
+		entry.AccountName = getElementFromStringWithColon(standardAccount, 1)
+	}
+	return entry
+}
+
+// getHistoryEntryForTask returns the most recently used history entry of
+// task, or a new entry for it when it was never worked on.
+func getHistoryEntryForTask(task string) WorkLogHistoryEntry {
+	var found *WorkLogHistoryEntry
+	history := getWorkLogHistoryEntries()
+	for i, entry := range history {
+		if entry.Task == task && (found == nil || entry.LastUsage.After(found.LastUsage)) {
+			found = &history[i]
+		}
+	}
+	if found != nil {
+		return *found
+	}
+	return newHistoryEntryForIssue(task, task)
+}
+
+// showQuickSwitchPalette opens a small window with a single search field over
+// the history and JIRA. Enter switches to the best match, so a task switch
+// from the global hotkey is three keystrokes plus the search text.
+func showQuickSwitchPalette() {
+	if quickSwitchWindow != nil {
+		quickSwitchWindow.RequestFocus()
+		return
+	}
+
+	quickSwitchWindow = fyne.CurrentApp().NewWindow("Quick Switch")
+	paletteWindow := quickSwitchWindow
+
+	picker := newTaskPicker()
+	picker.Entry.SetPlaceHolder("Switch to task...")
+	picker.OnHistoryChosen = func(entry WorkLogHistoryEntry) {
+		paletteWindow.Close()
+		switchToTask(entry)
+	}
+	picker.OnIssueChosen = func(issue string) {
+		paletteWindow.Close()
+		switchToTask(newHistoryEntryForIssue(getElementFromStringWithColon(issue, 0), getElementFromStringWithColon(issue, 1)))
+	}
+	picker.Entry.OnSubmitted = func(query string) {
+		picker.ChooseBest()
+	}
+
+	paletteWindow.SetOnClosed(func() {
+		picker.Stop()
+		quickSwitchWindow = nil
+	})
+	paletteWindow.SetContent(container.NewBorder(picker.Entry, nil, nil, nil, picker.List))
+	paletteWindow.Resize(fyne.NewSize(500, 300))
+	paletteWindow.CenterOnScreen()
+	paletteWindow.Show()
+	paletteWindow.RequestFocus()
+	paletteWindow.Canvas().Focus(picker.Entry)
+}
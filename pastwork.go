@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2/dialog"
+)
+
+const dismissedSuggestionsFileName = "suggestions.dismissed"
+
+var (
+	// dismissedSuggestions maps the suggestions of past work the user does
+	// not want to book, meetings and window activity, to their end
+	dismissedSuggestions      = make(map[string]time.Time)
+	dismissedSuggestionsMutex sync.Mutex
+)
+
+type trackedInterval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// workLogLine is one line of work.log, the local record of all worklogs.
+type workLogLine struct {
+	Task     string
+	Start    time.Time
+	End      time.Time
+	Minutes  float64
+	Activity string
+}
+
+// readWorkLog reads the lines of work.log that end after from. Lines written
+// before the activity was recorded have none.
+func readWorkLog(from time.Time) ([]workLogLine, error) {
+	data, err := os.ReadFile(getDataFilePath("work.log"))
+	if err != nil {
+		return nil, err
+	}
+	var lines []workLogLine
+	// work.log separates its lines with a carriage return only
+	for _, line := range strings.FieldsFunc(string(data), func(char rune) bool { return char == '\r' || char == '\n' }) {
+		fields := strings.Split(line, ";")
+		if len(fields) < 7 {
+			continue
+		}
+		start, startErr := time.ParseInLocation("2006-01-02 15:04:05", fields[2]+" "+fields[3], time.Local)
+		end, endErr := time.ParseInLocation("2006-01-02 15:04:05", fields[4]+" "+fields[5], time.Local)
+		if startErr != nil || endErr != nil || end.Before(from) {
+			continue
+		}
+		minutes, _ := strconv.ParseFloat(fields[6], 64)
+		workLog := workLogLine{Task: strings.TrimSpace(fields[1]), Start: start, End: end, Minutes: minutes}
+		if len(fields) > 7 {
+			workLog.Activity = fields[7]
+		}
+		lines = append(lines, workLog)
+	}
+	return lines, nil
+}
+
+// getTrackedIntervals reads the worklogs that end after from from work.log,
+// plus the running task. Breaks do not count as tracked.
+func getTrackedIntervals(from time.Time, now time.Time) []trackedInterval {
+	var intervals []trackedInterval
+	if working {
+		intervals = append(intervals, trackedInterval{Start: currentTaskStartInstant, End: now})
+	}
+
+	lines, err := readWorkLog(from)
+	if err != nil {
+		myErrorLogger.Printf("Got error when reading work.log %s", err.Error())
+		return intervals
+	}
+	for _, line := range lines {
+		if line.Task != pomodoroBreakTask {
+			intervals = append(intervals, trackedInterval{Start: line.Start, End: line.End})
+		}
+	}
+	return intervals
+}
+
+// subtractTrackedIntervals returns the parts of interval that none of
+// tracked covers.
+func subtractTrackedIntervals(interval trackedInterval, tracked []trackedInterval) []trackedInterval {
+	pieces := []trackedInterval{interval}
+	for _, trackedPart := range tracked {
+		var remaining []trackedInterval
+		for _, piece := range pieces {
+			if !trackedPart.Start.Before(piece.End) || !trackedPart.End.After(piece.Start) {
+				remaining = append(remaining, piece)
+				continue
+			}
+			if trackedPart.Start.After(piece.Start) {
+				remaining = append(remaining, trackedInterval{Start: piece.Start, End: trackedPart.Start})
+			}
+			if trackedPart.End.Before(piece.End) {
+				remaining = append(remaining, trackedInterval{Start: trackedPart.End, End: piece.End})
+			}
+		}
+		pieces = remaining
+	}
+	return pieces
+}
+
+// bookPastWork writes work that was not tracked when it happened, such as a
+// meeting, to work.log and books it in Tempo with its own times. It runs on
+// the UI thread, so work.log gets the location already looked up and the
+// worklog is posted in the background.
+func bookPastWork(entry WorkLogHistoryEntry, start time.Time, end time.Time) {
+	if entry.Connection != "" {
+		bindTaskToConnection(entry.Task, entry.Connection)
+	}
+	myLogger.Printf("Booking %s to %s on %s", start.Format("15:04:05"), end.Format("15:04:05"), entry.Task)
+	bookedDuration, book := getBookedPastDuration(entry.Task, end.Sub(start))
+	if !book {
+		return
+	}
+	writtenBytes, err := fmt.Fprintf(workLogWriter, "%s;%s;%s;%s;%s;%s;%g;%s\r", getCurrentLocation(), entry.Task, start.Format("2006-01-02"), start.Format("15:04:05"), end.Format("2006-01-02"), end.Format("15:04:05"), math.Round(bookedDuration.Minutes()), entry.Activity)
+	if err != nil {
+		myErrorLogger.Printf("Got error when booking past work %s", err.Error())
+		dialog.NewError(err, myWindow).Show()
+		return
+	}
+	workLogWriter.Flush()
+	myLogger.Printf("wrote %d bytes\n", writtenBytes)
+	go postWorkLogStartedAt(entry.Task, getTaskConnectionName(entry.Task), entry.TaskName, entry.Account, entry.AccountName, entry.Comment, entry.Activity, getCurrentLocation(), start, bookedDuration)
+}
+
+func isSuggestionDismissed(key string) bool {
+	dismissedSuggestionsMutex.Lock()
+	defer dismissedSuggestionsMutex.Unlock()
+	_, dismissed := dismissedSuggestions[key]
+	return dismissed
+}
+
+// dismissSuggestion keeps a suggestion from being made again. Dismissals of
+// work that ended before forgetBefore are forgotten.
+func dismissSuggestion(key string, end time.Time, forgetBefore time.Time) {
+	dismissedSuggestionsMutex.Lock()
+	defer dismissedSuggestionsMutex.Unlock()
+
+	dismissedSuggestions[key] = end
+	for dismissedKey, dismissedEnd := range dismissedSuggestions {
+		if dismissedEnd.Before(forgetBefore) {
+			delete(dismissedSuggestions, dismissedKey)
+		}
+	}
+	data, err := json.Marshal(dismissedSuggestions)
+	if err == nil {
+		err = writeFileAtomically(getDataFilePath(dismissedSuggestionsFileName), data)
+	}
+	if err != nil {
+		myErrorLogger.Printf("Got error when saving dismissed suggestions %s", err.Error())
+	}
+}
+
+func retrieveDismissedSuggestions() {
+	data, err := os.ReadFile(getDataFilePath(dismissedSuggestionsFileName))
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(bytes.TrimSpace(data)) == 0) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &dismissedSuggestions)
+	}
+	if err != nil {
+		myErrorLogger.Printf("Got error when reading dismissed suggestions %s", err.Error())
+	}
+}
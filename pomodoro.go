@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2/widget"
+	"github.com/gen2brain/beeep"
+)
+
+const (
+	pomodoroWork       = "Focus"
+	pomodoroShortBreak = "Short break"
+	pomodoroLongBreak  = "Long break"
+
+	// pomodoroBreakTask is the task of the breaks in work.log, they are
+	// never booked in Tempo
+	pomodoroBreakTask = "Break"
+)
+
+var (
+	pomodoro       pomodoroSession
+	pomodoroMutex  sync.Mutex
+	pomodoroButton *widget.Button
+)
+
+// pomodoroSession is the state of focus mode, advanced by the idleness
+// ticker every second. pausedForBreak remembers that the running task was
+// paused by focus mode, so that only then it is resumed after the break.
+type pomodoroSession struct {
+	active         bool
+	phase          string
+	phaseStart     time.Time
+	phaseEnd       time.Time
+	completed      int
+	pausedForBreak bool
+}
+
+func getPomodoroPhaseLength(phase string) time.Duration {
+	config := trackerConfig.Pomodoro
+	minutes := config.WorkMinutes
+	switch phase {
+	case pomodoroShortBreak:
+		minutes = config.ShortBreakMinutes
+	case pomodoroLongBreak:
+		minutes = config.LongBreakMinutes
+	}
+	return time.Duration(max(minutes, 1)) * time.Minute
+}
+
+func getSessionsBeforeLongBreak() int {
+	return max(trackerConfig.Pomodoro.SessionsBeforeLongBreak, 1)
+}
+
+// pomodoroEffects is what a change of phase does besides changing the
+// session. It is carried out once pomodoroMutex is released, because pausing
+// and resuming the task stop and start work.
+type pomodoroEffects struct {
+	notifyTitle   string
+	notifyMessage string
+	pause         bool
+	resume        bool
+	breakKind     string
+	breakStart    time.Time
+	breakEnd      time.Time
+}
+
+func (effects pomodoroEffects) apply() {
+	if effects.breakKind != "" {
+		recordBreak(effects.breakKind, effects.breakStart, effects.breakEnd)
+	}
+	if effects.notifyTitle != "" {
+		notifyPomodoro(effects.notifyTitle, effects.notifyMessage)
+	}
+	if effects.pause && working || effects.resume && paused {
+		togglePause()
+	}
+}
+
+func togglePomodoro() {
+	pomodoroMutex.Lock()
+	now := time.Now()
+	var effects pomodoroEffects
+	if pomodoro.active {
+		myLogger.Printf("Stopping focus mode in %s", pomodoro.phase)
+		if pomodoro.phase != pomodoroWork {
+			effects.breakKind, effects.breakStart, effects.breakEnd = pomodoro.phase, pomodoro.phaseStart, now
+			effects.resume = pomodoro.pausedForBreak
+		}
+		pomodoro = pomodoroSession{}
+		pomodoroButton.SetText("\r\nFocus\r\n")
+	} else {
+		myLogger.Printf("Starting focus mode")
+		pomodoro = pomodoroSession{active: true}
+		startPomodoroPhase(pomodoroWork, now)
+		pomodoroButton.SetText("\r\nStop Focus\r\n")
+	}
+	pomodoroMutex.Unlock()
+	effects.apply()
+}
+
+func startPomodoroPhase(phase string, now time.Time) {
+	pomodoro.phase = phase
+	pomodoro.phaseStart = now
+	pomodoro.phaseEnd = now.Add(getPomodoroPhaseLength(phase))
+}
+
+// tickPomodoro moves on to the next phase once the current one is over and
+// returns the countdown to show instead of the duration of the task.
+func tickPomodoro(now time.Time) (string, bool) {
+	pomodoroMutex.Lock()
+	if !pomodoro.active {
+		pomodoroMutex.Unlock()
+		return "", false
+	}
+	var effects pomodoroEffects
+	if !now.Before(pomodoro.phaseEnd) {
+		effects = advancePomodoro(now)
+	}
+	remaining := time.Duration(math.Ceil(pomodoro.phaseEnd.Sub(now).Seconds())) * time.Second
+	countdown := fmt.Sprintf("%s %02d:%02d left", pomodoro.phase, int(remaining.Minutes()), int(remaining.Seconds())%60)
+	if pomodoro.phase == pomodoroWork {
+		countdown += fmt.Sprintf(" (%d/%d)", pomodoro.completed%getSessionsBeforeLongBreak()+1, getSessionsBeforeLongBreak())
+	}
+	pomodoroMutex.Unlock()
+	effects.apply()
+	return countdown, true
+}
+
+// advancePomodoro moves the session on to the phase after the current one,
+// pomodoroMutex must be held. The task is paused for a break only when it is
+// running, and resumed after it only when focus mode paused it.
+func advancePomodoro(now time.Time) pomodoroEffects {
+	var effects pomodoroEffects
+	if pomodoro.phase == pomodoroWork {
+		pomodoro.completed++
+		next := pomodoroShortBreak
+		if pomodoro.completed%getSessionsBeforeLongBreak() == 0 {
+			next = pomodoroLongBreak
+		}
+		myLogger.Printf("Focus session %d over, taking a %s", pomodoro.completed, next)
+		effects.notifyTitle = next
+		effects.notifyMessage = fmt.Sprintf("Focus session %d is over, take a break of %d minutes", pomodoro.completed, int(getPomodoroPhaseLength(next).Minutes()))
+		if trackerConfig.Pomodoro.PauseDuringBreaks && working {
+			effects.pause = true
+			pomodoro.pausedForBreak = true
+		}
+		startPomodoroPhase(next, now)
+		return effects
+	}
+
+	effects.breakKind, effects.breakStart, effects.breakEnd = pomodoro.phase, pomodoro.phaseStart, now
+	myLogger.Printf("%s over, focusing again", pomodoro.phase)
+	effects.notifyTitle = pomodoroWork
+	if pomodoro.pausedForBreak && paused {
+		effects.notifyMessage = fmt.Sprintf("Break is over, resuming %s", pausedTask.Task)
+		effects.resume = true
+	} else {
+		effects.notifyMessage = "Break is over, time to focus"
+	}
+	pomodoro.pausedForBreak = false
+	startPomodoroPhase(pomodoroWork, now)
+	return effects
+}
+
+func notifyPomodoro(title string, message string) {
+	beeep.Beep(beeep.DefaultFreq, beeep.DefaultDuration)
+	err := beeep.Notify(title, message, "")
+	if err != nil {
+		myWarningLogger.Printf("Could not show notification: %s", err.Error())
+	}
+}
+
+// recordBreak writes a break to work.log like a worklog, with the kind of
+// break as its activity. It runs on the ticker, so it takes the location
+// already looked up.
+func recordBreak(kind string, start time.Time, end time.Time) {
+	minutes := math.Round(end.Sub(start).Minutes())
+	writtenBytes, err := fmt.Fprintf(workLogWriter, "%s;%s;%s;%s;%s;%s;%g;%s\r", getCurrentLocation(), pomodoroBreakTask, start.Format("2006-01-02"), start.Format("15:04:05"), end.Format("2006-01-02"), end.Format("15:04:05"), minutes, kind)
+	if err != nil {
+		myErrorLogger.Printf("Got error when recording break %s", err.Error())
+		return
+	}
+	workLogWriter.Flush()
+	myLogger.Printf("Recorded %s of %g minutes, wrote %d bytes", kind, minutes, writtenBytes)
+}
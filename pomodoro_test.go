@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdvancePomodoro(t *testing.T) {
+	now := time.Date(2024, 3, 11, 10, 0, 0, 0, time.Local)
+	tests := []struct {
+		name              string
+		session           pomodoroSession
+		pauseDuringBreaks bool
+		working           bool
+		paused            bool
+		wantPhase         string
+		wantCompleted     int
+		wantPausedBreak   bool
+		wantEffects       pomodoroEffects
+	}{
+		{
+			name:              "focus to short break pauses the running task",
+			session:           pomodoroSession{active: true, phase: pomodoroWork, phaseStart: now.Add(-25 * time.Minute)},
+			pauseDuringBreaks: true,
+			working:           true,
+			wantPhase:         pomodoroShortBreak,
+			wantCompleted:     1,
+			wantPausedBreak:   true,
+			wantEffects:       pomodoroEffects{notifyTitle: pomodoroShortBreak, notifyMessage: "Focus session 1 is over, take a break of 5 minutes", pause: true},
+		},
+		{
+			name:              "fourth focus session to long break",
+			session:           pomodoroSession{active: true, phase: pomodoroWork, phaseStart: now.Add(-25 * time.Minute), completed: 3},
+			pauseDuringBreaks: true,
+			wantPhase:         pomodoroLongBreak,
+			wantCompleted:     4,
+			wantEffects:       pomodoroEffects{notifyTitle: pomodoroLongBreak, notifyMessage: "Focus session 4 is over, take a break of 15 minutes"},
+		},
+		{
+			name:          "breaks without pausing",
+			session:       pomodoroSession{active: true, phase: pomodoroWork, phaseStart: now.Add(-25 * time.Minute)},
+			working:       true,
+			wantPhase:     pomodoroShortBreak,
+			wantCompleted: 1,
+			wantEffects:   pomodoroEffects{notifyTitle: pomodoroShortBreak, notifyMessage: "Focus session 1 is over, take a break of 5 minutes"},
+		},
+		{
+			name:          "break over resumes the task paused for it",
+			session:       pomodoroSession{active: true, phase: pomodoroShortBreak, phaseStart: now.Add(-5 * time.Minute), completed: 1, pausedForBreak: true},
+			paused:        true,
+			wantPhase:     pomodoroWork,
+			wantCompleted: 1,
+			wantEffects: pomodoroEffects{notifyTitle: pomodoroWork, notifyMessage: "Break is over, resuming ABC-1", resume: true,
+				breakKind: pomodoroShortBreak, breakStart: now.Add(-5 * time.Minute), breakEnd: now},
+		},
+		{
+			name:          "break over after the task was resumed by hand",
+			session:       pomodoroSession{active: true, phase: pomodoroLongBreak, phaseStart: now.Add(-15 * time.Minute), completed: 4, pausedForBreak: true},
+			working:       true,
+			wantPhase:     pomodoroWork,
+			wantCompleted: 4,
+			wantEffects: pomodoroEffects{notifyTitle: pomodoroWork, notifyMessage: "Break is over, time to focus",
+				breakKind: pomodoroLongBreak, breakStart: now.Add(-15 * time.Minute), breakEnd: now},
+		},
+	}
+
+	previousConfig, previousSession, previousWorking, previousPaused, previousTask := trackerConfig, pomodoro, working, paused, pausedTask
+	t.Cleanup(func() {
+		trackerConfig, pomodoro, working, paused, pausedTask = previousConfig, previousSession, previousWorking, previousPaused, previousTask
+	})
+	pausedTask = WorkLogHistoryEntry{Task: "ABC-1"}
+	for _, test := range tests {
+		trackerConfig.Pomodoro = PomodoroConfig{WorkMinutes: 25, ShortBreakMinutes: 5, LongBreakMinutes: 15, SessionsBeforeLongBreak: 4, PauseDuringBreaks: test.pauseDuringBreaks}
+		pomodoro, working, paused = test.session, test.working, test.paused
+
+		effects := advancePomodoro(now)
+		if effects != test.wantEffects {
+			t.Errorf("%s: effects %+v, want %+v", test.name, effects, test.wantEffects)
+		}
+		if pomodoro.phase != test.wantPhase || pomodoro.completed != test.wantCompleted || pomodoro.pausedForBreak != test.wantPausedBreak {
+			t.Errorf("%s: session %+v, want phase %s, %d completed and paused for break %t", test.name, pomodoro, test.wantPhase, test.wantCompleted, test.wantPausedBreak)
+		}
+		if !pomodoro.phaseStart.Equal(now) || !pomodoro.phaseEnd.Equal(now.Add(getPomodoroPhaseLength(test.wantPhase))) {
+			t.Errorf("%s: phase runs from %s to %s", test.name, pomodoro.phaseStart, pomodoro.phaseEnd)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/gen2brain/beeep"
+)
+
+// minimumReviewGap leaves out the gaps between drafts that rounding the
+// booked durations leaves behind
+const minimumReviewGap = 5 * time.Minute
+
+var (
+	reviewWindow  fyne.Window
+	reviewButton  *widget.Button
+	refreshReview func()
+	// reviewRemindedDay is the day on which the review was last opened at
+	// ReviewTime, so that it opens once a day
+	reviewRemindedDay string
+)
+
+// tickReview is called by the idleness ticker every second. In draft mode it
+// opens the review once a day from ReviewTime on while drafts are pending.
+func tickReview(now time.Time) {
+	config := trackerConfig.Submission
+	if config.Mode != submissionDraft || config.ReviewTime == "" || now.Format("15:04") < config.ReviewTime {
+		return
+	}
+	today := now.Format("2006-01-02")
+	if reviewRemindedDay == today {
+		return
+	}
+	reviewRemindedDay = today
+	pending := len(getDrafts())
+	if pending == 0 {
+		return
+	}
+	myLogger.Printf("Reminding of %d drafts to review", pending)
+	err := beeep.Notify("Timesheet review", fmt.Sprintf("%d worklogs are waiting to be reviewed and submitted", pending), "")
+	if err != nil {
+		myWarningLogger.Printf("Could not show notification: %s", err.Error())
+	}
+	showReviewWindow()
+}
+
+func onDraftsChanged() {
+	if reviewButton != nil && len(getDrafts()) > 0 {
+		reviewButton.Enable()
+	}
+	if refreshReview != nil {
+		refreshReview()
+	}
+}
+
+func getDailyTarget() time.Duration {
+	return time.Duration(trackerConfig.Submission.DailyTargetHours * float64(time.Hour))
+}
+
+// formatDraftAccount names the account of a draft. Drafts without an account
+// are booked on the internal issue and account of their connection, which is
+// spelled out so that it is not booked there by mistake.
+func formatDraftAccount(draft DraftWorkLog) string {
+	if draft.Account == "" {
+		connection := getConnection(draft.Connection)
+		if connection.Config.InternalIssue == "" {
+			return "no account, cannot be booked"
+		}
+		return fmt.Sprintf("no account, books on %s/%s", connection.Config.InternalIssue, connection.Config.InternalAccount)
+	}
+	return draft.Account + ":" + draft.AccountName
+}
+
+// getTaskForGap proposes the task of a gap between drafts: the task of a
+// meeting or of window activity in the gap, else the task before the gap.
+func getTaskForGap(start time.Time, end time.Time, previous DraftWorkLog) string {
+	for _, meeting := range getMeetings() {
+		if meeting.Start.Before(end) && meeting.End.After(start) {
+			if entry, found := getTaskForMeeting(meeting); found {
+				return entry.Task
+			}
+		}
+	}
+	if trackerConfig.WindowTracking.Enabled {
+		for _, suggestion := range getWindowSuggestions(start) {
+			if suggestion.Start.Before(end) && suggestion.End.After(start) {
+				return suggestion.Entry.Task
+			}
+		}
+	}
+	return previous.Task
+}
+
+// showReviewWindow lists the drafts day by day against the daily target. The
+// drafts can be edited, split, merged with the next one of the same task or
+// deleted, gaps between them filled, and everything submitted at once.
+func showReviewWindow() {
+	if reviewWindow != nil {
+		reviewWindow.RequestFocus()
+		return
+	}
+	reviewWindow = fyne.CurrentApp().NewWindow("Timesheet Review")
+	window := reviewWindow
+	window.SetOnClosed(func() {
+		reviewWindow = nil
+		refreshReview = nil
+	})
+
+	showError := func(err error) {
+		if err != nil {
+			myErrorLogger.Printf("Got error when reviewing drafts %s", err.Error())
+			dialog.NewError(err, window).Show()
+		}
+	}
+
+	rows := container.NewVBox()
+	fill := func() {
+		rows.RemoveAll()
+		reviewed := getDrafts()
+		if len(reviewed) == 0 {
+			rows.Add(widget.NewLabel("No drafts to submit."))
+		}
+		for i, draft := range reviewed {
+			draft := draft
+			day := draft.Start.Format("2006-01-02")
+			if i == 0 || reviewed[i-1].Start.Format("2006-01-02") != day {
+				var total time.Duration
+				for _, other := range reviewed {
+					if other.Start.Format("2006-01-02") == day {
+						total += other.duration()
+					}
+				}
+				header := fmt.Sprintf("%s  %s of %s", draft.Start.Format("Mon 02.01.2006"), formatEstimateShort(int(total.Seconds())), formatEstimateShort(int(getDailyTarget().Seconds())))
+				if total < getDailyTarget() {
+					header += ", below target"
+				}
+				rows.Add(widget.NewSeparator())
+				rows.Add(widget.NewLabelWithStyle(header, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+			}
+
+			label := widget.NewLabel(fmt.Sprintf("%s-%s %s  %s %s · %s · %s", draft.Start.Format("15:04"), draft.end().Format("15:04"), formatEstimateShort(draft.Seconds), draft.Task, draft.TaskName, formatDraftAccount(draft), draft.Comment))
+			label.Wrapping = fyne.TextTruncate
+			if draft.Account == "" {
+				label.TextStyle = fyne.TextStyle{Italic: true}
+			}
+			editButton := widget.NewButton("Edit", func() {
+				showEditDraftDialog(draft, window)
+			})
+			splitButton := widget.NewButton("Split", func() {
+				showSplitDraftDialog(draft, window)
+			})
+			mergeButton := widget.NewButton("Merge", nil)
+			mergeButton.Disable()
+			if i+1 < len(reviewed) && reviewed[i+1].Task == draft.Task && reviewed[i+1].Connection == draft.Connection && reviewed[i+1].Start.Format("2006-01-02") == day {
+				next := reviewed[i+1]
+				mergeButton.OnTapped = func() {
+					showError(mergeDrafts(draft.ID, next.ID))
+				}
+				mergeButton.Enable()
+			}
+			deleteButton := widget.NewButton("Delete", func() {
+				dialog.NewConfirm("Delete draft", fmt.Sprintf("Delete %s of %s?", formatEstimateShort(draft.Seconds), draft.Task), func(confirmed bool) {
+					if confirmed {
+						showError(removeDraft(draft.ID))
+					}
+				}, window).Show()
+			})
+			rows.Add(container.NewBorder(nil, nil, nil, container.NewHBox(editButton, splitButton, mergeButton, deleteButton), label))
+
+			if i+1 < len(reviewed) && reviewed[i+1].Start.Format("2006-01-02") == day && reviewed[i+1].Start.Sub(draft.end()) >= minimumReviewGap {
+				gapStart, gapEnd := draft.end(), reviewed[i+1].Start
+				taskEntry := widget.NewEntry()
+				taskEntry.SetText(getTaskForGap(gapStart, gapEnd, draft))
+				fillButton := widget.NewButton("Fill", func() {
+					task := strings.TrimSpace(taskEntry.Text)
+					if task == "" {
+						showError(errors.New("enter the task to fill the gap with"))
+						return
+					}
+					bookPastWork(getHistoryEntryForTask(task), gapStart, gapEnd)
+				})
+				gapLabel := widget.NewLabel(fmt.Sprintf("%s-%s %s not tracked", gapStart.Format("15:04"), gapEnd.Format("15:04"), formatEstimateShort(int(gapEnd.Sub(gapStart).Seconds()))))
+				taskAndButton := container.NewBorder(nil, nil, nil, fillButton, taskEntry)
+				rows.Add(container.NewBorder(nil, nil, nil, container.NewGridWrap(fyne.NewSize(300, 38), taskAndButton), gapLabel))
+			}
+		}
+		rows.Refresh()
+	}
+	refreshReview = fill
+
+	var submitButton *widget.Button
+	submitButton = widget.NewButton("Submit all", func() {
+		toSubmit := getDrafts()
+		if len(toSubmit) == 0 {
+			return
+		}
+		var warnings []string
+		totals := make(map[string]time.Duration)
+		withoutAccount := 0
+		for _, draft := range toSubmit {
+			totals[draft.Start.Format("Mon 02.01.")] += draft.duration()
+			if draft.Account == "" {
+				withoutAccount++
+			}
+		}
+		for _, draft := range toSubmit {
+			day := draft.Start.Format("Mon 02.01.")
+			if total, found := totals[day]; found && total < getDailyTarget() {
+				warnings = append(warnings, fmt.Sprintf("%s has %s of %s", day, formatEstimateShort(int(total.Seconds())), formatEstimateShort(int(getDailyTarget().Seconds()))))
+			}
+			delete(totals, day)
+		}
+		if withoutAccount > 0 {
+			warnings = append(warnings, fmt.Sprintf("%d worklogs have no account and are booked on the internal account", withoutAccount))
+		}
+		// the button stays disabled until the drafts are booked, so that
+		// they are not submitted twice
+		submit := func() {
+			submitButton.Disable()
+			go func() {
+				if err := submitDrafts(toSubmit); err != nil {
+					showError(err)
+				}
+				submitButton.Enable()
+				fill()
+			}()
+		}
+		if len(warnings) == 0 {
+			submit()
+			return
+		}
+		dialog.NewConfirm("Submit all", strings.Join(warnings, "\n")+"\n\nSubmit anyway?", func(confirmed bool) {
+			if confirmed {
+				submit()
+			}
+		}, window).Show()
+	})
+
+	fill()
+	window.SetContent(container.NewBorder(nil, submitButton, nil, nil, container.NewVScroll(rows)))
+	window.Resize(fyne.NewSize(1000, 500))
+	window.CenterOnScreen()
+	window.Show()
+}
+
+// showEditDraftDialog fixes the task, account, comment and activity of a
+// draft. Choosing another task, e.g. for the second part of a split draft,
+// moves the draft to the connection of that task and offers its accounts.
+func showEditDraftDialog(draft DraftWorkLog, parent fyne.Window) {
+	draft.Connection = getConnectionName(draft.Connection)
+	accountEntry := widget.NewSelectEntry(nil)
+	accountEntry.Validator = func(text string) error {
+		if text != "" && !strings.Contains(text, ":") {
+			return errors.New("no account selected")
+		}
+		return nil
+	}
+	setAccounts := func(account string) {
+		accountOptions, standardAccount, _ := getAccountOptionsForIssue(getConnection(draft.Connection), getElementFromStringWithColon(draft.Task, 0))
+		accountEntry.SetOptions(accountOptions)
+		if account == "" {
+			account = standardAccount
+		}
+		accountEntry.SetText(account)
+	}
+	if draft.Account != "" {
+		setAccounts(draft.Account + ":" + draft.AccountName)
+	} else {
+		setAccounts("")
+	}
+	commentEntry := widget.NewEntry()
+	commentEntry.SetText(draft.Comment)
+	activitySelected := draft.Activity
+	activitySelect := newActivitySelect(func(activity string) {
+		activitySelected = activity
+	})
+	activitySelect.SetSelected(getActivityName(draft.Activity))
+
+	var connectionNames []string
+	for _, connection := range jiraConnections {
+		connectionNames = append(connectionNames, connection.Config.Name)
+	}
+	connectionSelect := widget.NewSelect(connectionNames, nil)
+	connectionSelect.SetSelected(draft.Connection)
+	connectionSelect.OnChanged = func(name string) {
+		if name != draft.Connection {
+			draft.Connection = name
+			setAccounts("")
+		}
+	}
+
+	picker := newTaskPicker()
+	picker.SetText(draft.Task)
+	picker.OnHistoryChosen = func(entry WorkLogHistoryEntry) {
+		draft.Task = entry.Task
+		draft.TaskName = entry.TaskName
+		draft.Connection = getConnectionName(entry.Connection)
+		picker.SetText(entry.Task)
+		connectionSelect.SetSelected(draft.Connection)
+		if entry.Account != "" {
+			setAccounts(entry.Account + ":" + entry.AccountName)
+		} else {
+			setAccounts("")
+		}
+		if entry.Activity != "" {
+			activitySelect.SetSelected(getActivityName(entry.Activity))
+		}
+	}
+	picker.OnIssueChosen = func(issue string) {
+		draft.Task = getElementFromStringWithColon(issue, 0)
+		draft.TaskName = getElementFromStringWithColon(issue, 1)
+		draft.Connection = getConnectionName(getTaskConnectionName(draft.Task))
+		connectionSelect.SetSelected(draft.Connection)
+		setAccounts("")
+		activitySelect.SetSelected(getActivityName(getDefaultActivityForTask(draft.Task)))
+	}
+
+	formItems := []*widget.FormItem{
+		widget.NewFormItem("Task", picker.Entry),
+		widget.NewFormItem("", picker.Container(fyne.NewSize(600, 180))),
+	}
+	if hasMultipleConnections() {
+		formItems = append(formItems, widget.NewFormItem("Connection", connectionSelect))
+	}
+	formItems = append(formItems,
+		widget.NewFormItem("Account", accountEntry),
+		widget.NewFormItem("Comment", commentEntry),
+		widget.NewFormItem("Activity", activitySelect),
+	)
+	editDialog := dialog.NewForm(fmt.Sprintf("Editing %s %s", draft.Task, draft.Start.Format("02.01. 15:04")), "Save", "Cancel", formItems, func(save bool) {
+		if !save {
+			return
+		}
+		// a task typed in rather than chosen from the list
+		if text := strings.TrimSpace(picker.Entry.Text); text != "" && getElementFromStringWithColon(text, 0) != draft.Task {
+			draft.Task = getElementFromStringWithColon(text, 0)
+			draft.TaskName = getElementFromStringWithColon(text, 1)
+		}
+		draft.Account = getElementFromStringWithColon(accountEntry.Text, 0)
+		draft.AccountName = getElementFromStringWithColon(accountEntry.Text, 1)
+		draft.Comment = commentEntry.Text
+		draft.Activity = activitySelected
+		if err := updateDraft(draft); err != nil {
+			myErrorLogger.Printf("Got error when editing draft %s", err.Error())
+			dialog.NewError(err, parent).Show()
+		}
+	}, parent)
+	editDialog.SetOnClosed(picker.Stop)
+	editDialog.Resize(fyne.NewSize(700, 550))
+	editDialog.Show()
+}
+
+// showSplitDraftDialog asks for the minutes the first part of a draft keeps.
+func showSplitDraftDialog(draft DraftWorkLog, parent fyne.Window) {
+	minutesEntry := widget.NewEntry()
+	minutesEntry.SetText(strconv.Itoa(draft.Seconds / 120))
+	minutesEntry.Validator = func(text string) error {
+		minutes, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil || minutes <= 0 || minutes*60 >= draft.Seconds {
+			return fmt.Errorf("enter minutes between 1 and %d", (draft.Seconds-1)/60)
+		}
+		return nil
+	}
+	formItems := []*widget.FormItem{widget.NewFormItem("Minutes of the first part", minutesEntry)}
+	dialog.NewForm(fmt.Sprintf("Splitting %s %s", draft.Task, formatEstimateShort(draft.Seconds)), "Split", "Cancel", formItems, func(split bool) {
+		if !split {
+			return
+		}
+		minutes, _ := strconv.Atoi(strings.TrimSpace(minutesEntry.Text))
+		if err := splitDraft(draft.ID, time.Duration(minutes)*time.Minute); err != nil {
+			myErrorLogger.Printf("Got error when splitting draft %s", err.Error())
+			dialog.NewError(err, parent).Show()
+		}
+	}, parent).Show()
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	roundingNone    = "none"
+	roundingNearest = "nearest"
+	roundingUp      = "up"
+	roundingDown    = "down"
+
+	shortEntryKeep  = "keep"
+	shortEntryMerge = "merge"
+	shortEntryDrop  = "drop"
+)
+
+const shortEntryCarryFileName = "rounding.carry"
+
+// shortEntryCarry is the time of short entries kept back to be merged into
+// the entry that follows them on the same day. It is saved so that it
+// survives a restart.
+type shortEntryCarry struct {
+	Duration time.Duration `json:"duration"`
+	End      time.Time     `json:"end"`
+}
+
+var (
+	mergedShortEntries      shortEntryCarry
+	mergedShortEntriesMutex sync.Mutex
+)
+
+// roundDuration applies the rounding mode, the granularity and the minimum
+// booking size of the rounding policy to a duration.
+func roundDuration(duration time.Duration) time.Duration {
+	config := trackerConfig.Rounding
+	granularity := time.Duration(config.GranularityMinutes) * time.Minute
+	if granularity > 0 {
+		switch config.Mode {
+		case roundingNearest:
+			duration = duration.Round(granularity)
+		case roundingUp:
+			duration = time.Duration(math.Ceil(float64(duration)/float64(granularity))) * granularity
+		case roundingDown:
+			duration = duration.Truncate(granularity)
+		}
+	}
+	minimum := time.Duration(config.MinimumMinutes) * time.Minute
+	if duration > 0 && duration < minimum {
+		duration = minimum
+	}
+	return duration
+}
+
+func isSameDay(first time.Time, second time.Time) bool {
+	return first.Format("2006-01-02") == second.Format("2006-01-02")
+}
+
+// getBookedDuration returns the duration to book for the tracked entry from
+// start to end. It returns false when nothing is to be booked, because the
+// entry is shorter than the short entry threshold and was dropped or kept
+// back to be merged into the entry that follows it on the same day.
+func getBookedDuration(task string, start time.Time, end time.Time) (time.Duration, bool) {
+	config := trackerConfig.Rounding
+	threshold := time.Duration(config.ShortEntryMinutes) * time.Minute
+	worked := end.Sub(start)
+
+	mergedShortEntriesMutex.Lock()
+	defer mergedShortEntriesMutex.Unlock()
+
+	if mergedShortEntries.Duration > 0 && !isSameDay(mergedShortEntries.End, start) && start.After(mergedShortEntries.End) {
+		myWarningLogger.Printf("Dropping %s of short entries of %s, no entry followed them that day", mergedShortEntries.Duration.String(), mergedShortEntries.End.Format("2006-01-02"))
+		mergedShortEntries = shortEntryCarry{}
+		saveShortEntryCarry()
+	}
+
+	if worked < threshold {
+		switch config.ShortEntryAction {
+		case shortEntryDrop:
+			myLogger.Printf("Dropping short entry of %s on %s", worked.String(), task)
+			return 0, false
+		case shortEntryMerge:
+			mergedShortEntries.Duration += worked
+			mergedShortEntries.End = end
+			saveShortEntryCarry()
+			myLogger.Printf("Merging short entry of %s on %s into the next entry", worked.String(), task)
+			return 0, false
+		}
+	}
+
+	if mergedShortEntries.Duration > 0 && !start.Before(mergedShortEntries.End) {
+		myLogger.Printf("Adding %s of merged short entries to %s", mergedShortEntries.Duration.String(), task)
+		worked += mergedShortEntries.Duration
+		mergedShortEntries = shortEntryCarry{}
+		saveShortEntryCarry()
+	}
+	booked := roundDuration(worked)
+	if booked <= 0 {
+		myWarningLogger.Printf("Nothing to book on %s for %s", task, worked.String())
+		return 0, false
+	}
+	return booked, true
+}
+
+// getBookedPastDuration returns the duration to book for work that is booked
+// afterwards, such as a meeting. It is rounded, but as it is chosen by hand
+// it is never dropped, and it neither takes up nor leaves behind merged short
+// entries.
+func getBookedPastDuration(task string, worked time.Duration) (time.Duration, bool) {
+	booked := roundDuration(worked)
+	if booked <= 0 {
+		myWarningLogger.Printf("Nothing to book on %s for %s", task, worked.String())
+		return 0, false
+	}
+	return booked, true
+}
+
+// saveShortEntryCarry writes the merged short entries to their file,
+// mergedShortEntriesMutex must be held.
+func saveShortEntryCarry() {
+	data, err := json.Marshal(mergedShortEntries)
+	if err == nil {
+		err = writeFileAtomically(getDataFilePath(shortEntryCarryFileName), data)
+	}
+	if err != nil {
+		myErrorLogger.Printf("Got error when saving merged short entries %s", err.Error())
+	}
+}
+
+func retrieveShortEntryCarry() {
+	data, err := os.ReadFile(getDataFilePath(shortEntryCarryFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &mergedShortEntries)
+	}
+	if err != nil {
+		myErrorLogger.Printf("Got error when reading merged short entries %s", err.Error())
+	}
+}
+
+// formatDurationWithBooking shows next to a running duration what would be
+// booked for it, unless the policy only rounds to whole minutes.
+func formatDurationWithBooking(elapsed time.Duration) string {
+	config := trackerConfig.Rounding
+	if (config.Mode == roundingNone || config.GranularityMinutes <= 1) && config.MinimumMinutes == 0 {
+		return elapsed.String()
+	}
+	return fmt.Sprintf("%s → %s", elapsed.String(), roundDuration(elapsed).String())
+}
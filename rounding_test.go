@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRoundDuration(t *testing.T) {
+	tests := []struct {
+		mode        string
+		granularity int
+		minimum     int
+		duration    time.Duration
+		want        time.Duration
+	}{
+		{mode: roundingNone, granularity: 15, duration: 7 * time.Minute, want: 7 * time.Minute},
+		{mode: roundingNearest, granularity: 15, duration: 7 * time.Minute, want: 0},
+		{mode: roundingNearest, granularity: 15, duration: 8 * time.Minute, want: 15 * time.Minute},
+		{mode: roundingNearest, granularity: 15, duration: 37*time.Minute + 29*time.Second, want: 30 * time.Minute},
+		{mode: roundingUp, granularity: 15, duration: 16 * time.Minute, want: 30 * time.Minute},
+		{mode: roundingUp, granularity: 15, duration: 30 * time.Minute, want: 30 * time.Minute},
+		{mode: roundingDown, granularity: 15, duration: 29 * time.Minute, want: 15 * time.Minute},
+		{mode: roundingNearest, granularity: 1, duration: 90 * time.Second, want: 2 * time.Minute},
+		{mode: roundingNearest, granularity: 0, duration: 90 * time.Second, want: 90 * time.Second},
+		{mode: roundingNone, granularity: 15, minimum: 15, duration: 3 * time.Minute, want: 15 * time.Minute},
+		{mode: roundingDown, granularity: 15, minimum: 15, duration: 3 * time.Minute, want: 0},
+		{mode: roundingNone, minimum: 10, duration: 0, want: 0},
+	}
+	defer func(config RoundingConfig) { trackerConfig.Rounding = config }(trackerConfig.Rounding)
+	for _, test := range tests {
+		trackerConfig.Rounding = RoundingConfig{Mode: test.mode, GranularityMinutes: test.granularity, MinimumMinutes: test.minimum}
+		if got := roundDuration(test.duration); got != test.want {
+			t.Errorf("roundDuration(%s) with %s/%d/%d = %s, want %s", test.duration, test.mode, test.granularity, test.minimum, got, test.want)
+		}
+	}
+}
+
+func TestGetBookedDuration(t *testing.T) {
+	defer func(config RoundingConfig, directory string) {
+		trackerConfig.Rounding = config
+		dataDirectory = directory
+		mergedShortEntries = shortEntryCarry{}
+	}(trackerConfig.Rounding, dataDirectory)
+
+	day := time.Date(2024, 3, 11, 0, 0, 0, 0, time.Local)
+	at := func(days int, hour int, minute int) time.Time {
+		return day.AddDate(0, 0, days).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+	type entry struct {
+		start    time.Time
+		end      time.Time
+		past     bool
+		wantBook bool
+		want     time.Duration
+	}
+	tests := []struct {
+		name    string
+		action  string
+		entries []entry
+	}{
+		{name: "keep", action: shortEntryKeep, entries: []entry{
+			{start: at(0, 9, 0), end: at(0, 9, 3), wantBook: true, want: 3 * time.Minute},
+		}},
+		{name: "drop", action: shortEntryDrop, entries: []entry{
+			{start: at(0, 9, 0), end: at(0, 9, 3)},
+			{start: at(0, 9, 3), end: at(0, 10, 0), wantBook: true, want: 57 * time.Minute},
+		}},
+		{name: "merge into the next entry", action: shortEntryMerge, entries: []entry{
+			{start: at(0, 9, 0), end: at(0, 9, 3)},
+			{start: at(0, 9, 3), end: at(0, 9, 5)},
+			{start: at(0, 9, 5), end: at(0, 10, 0), wantBook: true, want: time.Hour},
+			{start: at(0, 10, 0), end: at(0, 11, 0), wantBook: true, want: time.Hour},
+		}},
+		{name: "past work does not take up merged entries", action: shortEntryMerge, entries: []entry{
+			{start: at(0, 9, 0), end: at(0, 9, 3)},
+			{start: at(0, 9, 30), end: at(0, 9, 32), past: true, wantBook: true, want: 2 * time.Minute},
+			{start: at(0, 9, 40), end: at(0, 10, 0), wantBook: true, want: 23 * time.Minute},
+		}},
+		{name: "earlier work does not take up merged entries", action: shortEntryMerge, entries: []entry{
+			{start: at(0, 9, 0), end: at(0, 9, 3)},
+			{start: at(0, 8, 0), end: at(0, 9, 0), wantBook: true, want: time.Hour},
+			{start: at(0, 9, 3), end: at(0, 10, 0), wantBook: true, want: time.Hour},
+		}},
+		{name: "merged entries end with the day", action: shortEntryMerge, entries: []entry{
+			{start: at(0, 17, 0), end: at(0, 17, 3)},
+			{start: at(1, 9, 0), end: at(1, 10, 0), wantBook: true, want: time.Hour},
+		}},
+	}
+	for _, test := range tests {
+		dataDirectory = t.TempDir()
+		mergedShortEntries = shortEntryCarry{}
+		trackerConfig.Rounding = RoundingConfig{Mode: roundingNone, ShortEntryMinutes: 5, ShortEntryAction: test.action}
+		for i, entry := range test.entries {
+			var got time.Duration
+			var book bool
+			if entry.past {
+				got, book = getBookedPastDuration("ABC-1", entry.end.Sub(entry.start))
+			} else {
+				got, book = getBookedDuration("ABC-1", entry.start, entry.end)
+			}
+			if book != entry.wantBook || got != entry.want {
+				t.Errorf("%s: entry %d booked %s (%t), want %s (%t)", test.name, i, got, book, entry.want, entry.wantBook)
+			}
+		}
+	}
+}
+
+func TestShortEntryCarrySurvivesRestart(t *testing.T) {
+	defer func(config RoundingConfig, directory string) {
+		trackerConfig.Rounding = config
+		dataDirectory = directory
+		mergedShortEntries = shortEntryCarry{}
+	}(trackerConfig.Rounding, dataDirectory)
+
+	dataDirectory = t.TempDir()
+	trackerConfig.Rounding = RoundingConfig{Mode: roundingNone, ShortEntryMinutes: 5, ShortEntryAction: shortEntryMerge}
+	start := time.Date(2024, 3, 11, 9, 0, 0, 0, time.Local)
+	getBookedDuration("ABC-1", start, start.Add(3*time.Minute))
+
+	mergedShortEntries = shortEntryCarry{}
+	retrieveShortEntryCarry()
+	got, book := getBookedDuration("ABC-2", start.Add(3*time.Minute), start.Add(time.Hour))
+	if !book || got != time.Hour {
+		t.Errorf("after a restart booked %s (%t), want %s", got, book, time.Hour)
+	}
+}
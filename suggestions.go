@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	suggestionSections         []suggestionSection
+	suggestionSectionsFetch    time.Time
+	suggestionSectionsFetching bool
+	suggestionSectionsMutex    sync.Mutex
+)
+
+// SuggestionQuery.Connection names the connection the query runs on, all
+// connections when empty.
+type SuggestionQuery struct {
+	Name       string `json:"name"`
+	JQL        string `json:"jql"`
+	Connection string `json:"connection,omitempty"`
+}
+
+type suggestionSection struct {
+	Name   string
+	Issues []JIRAIssue
+}
+
+type JQLSearchResponse struct {
+	Expand     string `json:"expand"`
+	StartAt    int    `json:"startAt"`
+	MaxResults int    `json:"maxResults"`
+	Total      int    `json:"total"`
+	Issues     []struct {
+		ID     string `json:"id"`
+		Self   string `json:"self"`
+		Key    string `json:"key"`
+		Fields struct {
+			Summary string `json:"summary"`
+			Project struct {
+				ID   string `json:"id"`
+				Key  string `json:"key"`
+				Name string `json:"name"`
+			} `json:"project"`
+		} `json:"fields"`
+	} `json:"issues"`
+}
+
+type SprintQueryResponse struct {
+	MaxResults int  `json:"maxResults"`
+	StartAt    int  `json:"startAt"`
+	IsLast     bool `json:"isLast"`
+	Values     []struct {
+		ID            int    `json:"id"`
+		Self          string `json:"self"`
+		State         string `json:"state"`
+		Name          string `json:"name"`
+		OriginBoardID int    `json:"originBoardId"`
+	} `json:"values"`
+}
+
+// searchJIRAWithJQL pages through /rest/api/2/search until JIRA has no more
+// issues for jql or maxResults issues have been collected.
+func searchJIRAWithJQL(ctx context.Context, connection *jiraConnection, jql string, maxResults int) ([]JIRAIssue, error) {
+	if connection.isCloud() {
+		return searchJIRACloudWithJQL(ctx, connection, jql, maxResults)
+	}
+	var result []JIRAIssue = make([]JIRAIssue, 0)
+
+	jql = url.QueryEscape(jql)
+	for startAt := 0; len(result) < maxResults; {
+		pageSize := maxResults - len(result)
+		if pageSize > 50 {
+			pageSize = 50
+		}
+		path := connection.apiPath("/search?jql=%s&fields=summary,project&startAt=%d&maxResults=%d", jql, startAt, pageSize)
+		myLogger.Printf("Requesting issues by JQL from %s %s", connection.Config.Name, path)
+
+		var searchResponse JQLSearchResponse
+		err := connection.JIRA.Do(ctx, "GET", path, nil, &searchResponse)
+		if err != nil {
+			myErrorLogger.Printf("Got error %s", err.Error())
+			return result, err
+		}
+
+		fetched := time.Now()
+		for _, issue := range searchResponse.Issues {
+			result = append(result, JIRAIssue{
+				Key:     issue.Key,
+				Summary: issue.Fields.Summary,
+				Project: issue.Fields.Project.Key,
+				Fetched: fetched,
+			})
+		}
+
+		startAt = searchResponse.StartAt + len(searchResponse.Issues)
+		if len(searchResponse.Issues) == 0 || startAt >= searchResponse.Total {
+			break
+		}
+	}
+	return result, nil
+}
+
+func getActiveSprintForBoard(ctx context.Context, connection *jiraConnection, boardID int) (int, string, error) {
+	path := fmt.Sprintf("/rest/agile/1.0/board/%d/sprint?state=active", boardID)
+	myLogger.Printf("Requesting active sprint from %s %s", connection.Config.Name, path)
+
+	var sprintResponse SprintQueryResponse
+	err := connection.JIRA.Do(ctx, "GET", path, nil, &sprintResponse)
+	if err != nil {
+		myErrorLogger.Printf("Got error %s", err.Error())
+		return 0, "", err
+	}
+	if len(sprintResponse.Values) == 0 {
+		return 0, "", fmt.Errorf("board %d has no active sprint", boardID)
+	}
+	return sprintResponse.Values[0].ID, sprintResponse.Values[0].Name, nil
+}
+
+// fetchSuggestionSections runs the configured queries. The error joins the
+// failed fetches; the sections hold whatever could be fetched regardless.
+func fetchSuggestionSections(ctx context.Context) ([]suggestionSection, error) {
+	config := trackerConfig.Suggestions
+	var sections []suggestionSection
+	var errs []error
+
+	queries := make([]SuggestionQuery, len(config.Queries))
+	copy(queries, config.Queries)
+	// the board is one of the default connection
+	if config.SprintBoardID > 0 {
+		sprintID, sprintName, err := getActiveSprintForBoard(ctx, getDefaultConnection(), config.SprintBoardID)
+		if err != nil {
+			myWarningLogger.Printf("Could not get the current sprint: %s", err.Error())
+			errs = append(errs, err)
+		} else {
+			queries = append(queries, SuggestionQuery{
+				Name:       sprintName,
+				JQL:        fmt.Sprintf("sprint = %d ORDER BY Rank ASC", sprintID),
+				Connection: getDefaultConnection().Config.Name,
+			})
+		}
+	}
+
+	for _, query := range queries {
+		if strings.TrimSpace(query.JQL) == "" {
+			continue
+		}
+		var issues []JIRAIssue
+		for _, connection := range jiraConnections {
+			if query.Connection != "" && query.Connection != connection.Config.Name {
+				continue
+			}
+			connectionIssues, err := searchJIRAWithJQL(ctx, connection, query.JQL, config.MaxResults)
+			if err != nil {
+				myWarningLogger.Printf("Could not get suggestions for %s from %s: %s", query.Name, connection.Config.Name, err.Error())
+				errs = append(errs, err)
+				continue
+			}
+			issues = append(issues, labelIssues(connectionIssues, connection)...)
+		}
+		if len(issues) > 0 {
+			sections = append(sections, suggestionSection{Name: query.Name, Issues: issues})
+			issueCache.storeIssues(issues)
+		}
+	}
+	return sections, errors.Join(errs...)
+}
+
+// getSuggestionSections returns the "my work" suggestions, fetching them
+// again only once they are older than the configured refresh interval. Only a
+// complete fetch is kept, so the next picker tries again after a failure, and
+// while a fetch runs the previous sections are returned instead of waiting.
+func getSuggestionSections() []suggestionSection {
+	config := trackerConfig.Suggestions
+	if len(config.Queries) == 0 && config.SprintBoardID == 0 {
+		return nil
+	}
+
+	suggestionSectionsMutex.Lock()
+	cached := suggestionSections
+	fresh := cached != nil && time.Since(suggestionSectionsFetch) < time.Duration(config.RefreshMinutes)*time.Minute
+	if fresh || suggestionSectionsFetching {
+		suggestionSectionsMutex.Unlock()
+		return cached
+	}
+	suggestionSectionsFetching = true
+	suggestionSectionsMutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(trackerConfig.JIRASearch.TimeoutSeconds)*time.Second)
+	defer cancel()
+	sections, err := fetchSuggestionSections(ctx)
+
+	suggestionSectionsMutex.Lock()
+	defer suggestionSectionsMutex.Unlock()
+	suggestionSectionsFetching = false
+	if err != nil {
+		if len(sections) == 0 {
+			return cached
+		}
+		return sections
+	}
+	if sections == nil {
+		sections = []suggestionSection{}
+	}
+	suggestionSections = sections
+	suggestionSectionsFetch = time.Now()
+	return sections
+}
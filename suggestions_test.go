@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetSuggestionSectionsRetriesAfterFailure(t *testing.T) {
+	useTempDataDirectory(t)
+	online := false
+	jira := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !online {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"startAt":0,"total":1,"issues":[{"key":"ABC-1","fields":{"summary":"Fix login","project":{"key":"ABC"}}}]}`))
+	}))
+	defer jira.Close()
+
+	connection := newJIRAConnection(ConnectionConfig{Name: "server", BaseURL: jira.URL})
+	connection.JIRA.Trace = nil
+	previousConnections, previousConfig := jiraConnections, trackerConfig
+	jiraConnections = []*jiraConnection{connection}
+	trackerConfig.Suggestions = SuggestionsConfig{Queries: []SuggestionQuery{{Name: "Mine", JQL: "assignee = currentUser()"}}, MaxResults: 10, RefreshMinutes: 60}
+	suggestionSections = nil
+	t.Cleanup(func() {
+		jiraConnections, trackerConfig = previousConnections, previousConfig
+		suggestionSections = nil
+	})
+
+	if sections := getSuggestionSections(); len(sections) != 0 {
+		t.Fatalf("getSuggestionSections() while offline = %+v", sections)
+	}
+	online = true
+	sections := getSuggestionSections()
+	if len(sections) != 1 || len(sections[0].Issues) != 1 || sections[0].Issues[0].Key != "ABC-1" {
+		t.Fatalf("getSuggestionSections() after a failed fetch = %+v, want the fetched section", sections)
+	}
+	online = false
+	if sections := getSuggestionSections(); len(sections) != 1 {
+		t.Errorf("getSuggestionSections() did not keep the successful fetch: %+v", sections)
+	}
+}
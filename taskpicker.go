@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+// suggested issues rank above history entries that match equally well
+const suggestionScoreBonus = 20
+
+type taskPickerItem struct {
+	Label    string
+	Entry    WorkLogHistoryEntry
+	Issue    string
+	FromJIRA bool
+	Header   bool
+	Score    float64
+}
+
+// taskPicker is a search field with a ranked list of history entries and
+// JIRA issues below it. Choosing a history entry hands over the complete
+// entry, choosing a JIRA issue puts it into the field so that an account can
+// still be picked for it. While the field is empty, the "my work"
+// suggestions are shown in sections above the history.
+type taskPicker struct {
+	Entry           *widget.Entry
+	List            *widget.List
+	OnChanged       func(string)
+	OnHistoryChosen func(WorkLogHistoryEntry)
+	OnIssueChosen   func(string)
+
+	items       []taskPickerItem
+	jiraIssues  []JIRAIssue
+	sections    []suggestionSection
+	settingText bool
+	searcher    issueSearcher
+}
+
+func newTaskPicker() *taskPicker {
+	picker := &taskPicker{}
+	picker.items = rankTaskPickerItems("", nil, nil)
+
+	picker.Entry = widget.NewEntry()
+	picker.Entry.SetPlaceHolder("Type to search history and JIRA...")
+	picker.Entry.OnChanged = func(query string) {
+		if picker.settingText {
+			return
+		}
+		if picker.OnChanged != nil {
+			picker.OnChanged(query)
+		}
+		picker.search(query)
+	}
+
+	picker.List = widget.NewList(
+		func() int {
+			return len(picker.items)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("template")
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			label := o.(*widget.Label)
+			label.TextStyle.Bold = picker.items[i].Header
+			label.SetText(picker.items[i].Label)
+		})
+	picker.List.OnSelected = func(i widget.ListItemID) {
+		picker.List.Unselect(i)
+		if i < len(picker.items) && !picker.items[i].Header {
+			picker.choose(picker.items[i])
+		}
+	}
+
+	go func() {
+		sections := append(getGitSuggestionSections(), getSuggestionSections()...)
+		if len(sections) == 0 {
+			return
+		}
+		picker.sections = sections
+		picker.items = rankTaskPickerItems(picker.Entry.Text, picker.jiraIssues, picker.sections)
+		picker.List.Refresh()
+	}()
+	return picker
+}
+
+func (picker *taskPicker) search(query string) {
+	picker.jiraIssues = nil
+	picker.items = rankTaskPickerItems(query, nil, picker.sections)
+	picker.List.Refresh()
+	picker.List.ScrollToTop()
+	picker.setJIRAStyle(false)
+
+	if len(query) <= 3 {
+		picker.searcher.Stop()
+		return
+	}
+	picker.searcher.Search(query, func(issues []JIRAIssue) {
+		if picker.Entry.Text != query {
+			return
+		}
+		picker.jiraIssues = issues
+		picker.items = rankTaskPickerItems(query, issues, picker.sections)
+		picker.List.Refresh()
+		picker.setJIRAStyle(len(issues) > 0)
+	})
+}
+
+// SetText fills the field without searching, e.g. with the task of the entry
+// being edited.
+func (picker *taskPicker) SetText(text string) {
+	picker.settingText = true
+	picker.Entry.SetText(text)
+	picker.settingText = false
+}
+
+// Stop cancels a pending JIRA search, e.g. when the dialog is closed.
+func (picker *taskPicker) Stop() {
+	picker.searcher.Stop()
+}
+
+func (picker *taskPicker) setJIRAStyle(foundInJIRA bool) {
+	picker.Entry.TextStyle.Bold = foundInJIRA
+	picker.Entry.TextStyle.Italic = foundInJIRA
+	picker.Entry.Refresh()
+}
+
+func (picker *taskPicker) choose(item taskPickerItem) {
+	bindTaskToConnection(item.Entry.Task, item.Entry.Connection)
+	if !item.FromJIRA {
+		if picker.OnHistoryChosen != nil {
+			picker.OnHistoryChosen(item.Entry)
+		}
+		return
+	}
+	picker.SetText(item.Issue)
+	picker.setJIRAStyle(false)
+	if picker.OnIssueChosen != nil {
+		picker.OnIssueChosen(item.Issue)
+	}
+}
+
+// ChooseBest chooses the top ranked item, if the field matches anything.
+func (picker *taskPicker) ChooseBest() bool {
+	if picker.Entry.Text == "" {
+		return false
+	}
+	for _, item := range picker.items {
+		if !item.Header {
+			picker.choose(item)
+			return true
+		}
+	}
+	return false
+}
+
+func (picker *taskPicker) Container(size fyne.Size) fyne.CanvasObject {
+	return container.New(layout.NewGridWrapLayout(size), picker.List)
+}
+
+// frecencyScore weights how often a task was used by how recently, so that a
+// task used daily last month ranks below one used a few times this week.
+func frecencyScore(entry WorkLogHistoryEntry, now time.Time) float64 {
+	age := now.Sub(entry.LastUsage)
+	var recencyWeight float64
+	switch {
+	case age < 24*time.Hour:
+		recencyWeight = 100
+	case age < 4*24*time.Hour:
+		recencyWeight = 80
+	case age < 14*24*time.Hour:
+		recencyWeight = 60
+	case age < 31*24*time.Hour:
+		recencyWeight = 40
+	case age < 90*24*time.Hour:
+		recencyWeight = 20
+	default:
+		recencyWeight = 10
+	}
+	return float64(entry.Count) * recencyWeight
+}
+
+// rankTaskPickerItems merges the history, the suggestions and the JIRA
+// search results into one list. Without a query the suggestions come first,
+// one section each, followed by the history ordered by frecency. With a query
+// the fuzzy match quality dominates and frecency breaks near-ties.
+func rankTaskPickerItems(query string, jiraIssues []JIRAIssue, sections []suggestionSection) []taskPickerItem {
+	now := time.Now()
+	historyItems := []taskPickerItem{}
+	seenLabels := make(map[string]bool)
+	seenTasks := make(map[string]bool)
+
+	history := getWorkLogHistoryEntries()
+	sort.SliceStable(history, func(i, j int) bool {
+		return frecencyScore(history[i], now) > frecencyScore(history[j], now)
+	})
+
+	for _, entry := range history {
+		label := formatHistoryEntry(entry)
+		if seenLabels[label] {
+			continue
+		}
+		matchScore, matched := fuzzyMatch(query, label+" "+entry.Comment)
+		if !matched {
+			continue
+		}
+		seenLabels[label] = true
+		seenTasks[getPickerTaskKey(entry.Connection, entry.Task)] = true
+		historyItems = append(historyItems, taskPickerItem{
+			Label: label,
+			Entry: entry,
+			Score: float64(matchScore)*10 + math.Log1p(frecencyScore(entry, now))*10,
+		})
+	}
+
+	if query == "" && len(sections) > 0 {
+		items := []taskPickerItem{}
+		seenSuggestions := make(map[string]bool)
+		for _, section := range sections {
+			sectionItems := []taskPickerItem{}
+			for _, issue := range section.Issues {
+				if seenSuggestions[getPickerTaskKey(issue.Connection, issue.Key)] {
+					continue
+				}
+				seenSuggestions[getPickerTaskKey(issue.Connection, issue.Key)] = true
+				sectionItems = append(sectionItems, newIssuePickerItem(issue, section.Name, 0))
+			}
+			if len(sectionItems) > 0 {
+				items = append(items, taskPickerItem{Label: section.Name, Header: true})
+				items = append(items, sectionItems...)
+			}
+		}
+		if len(historyItems) > 0 {
+			items = append(items, taskPickerItem{Label: "Recent", Header: true})
+			items = append(items, historyItems...)
+		}
+		return items
+	}
+
+	items := historyItems
+	for _, section := range sections {
+		for _, issue := range section.Issues {
+			if seenTasks[getPickerTaskKey(issue.Connection, issue.Key)] {
+				continue
+			}
+			matchScore, matched := fuzzyMatch(query, issue.Key+" "+issue.Summary)
+			if !matched {
+				continue
+			}
+			seenTasks[getPickerTaskKey(issue.Connection, issue.Key)] = true
+			items = append(items, newIssuePickerItem(issue, section.Name, float64(matchScore)*10+suggestionScoreBonus))
+		}
+	}
+
+	for _, issue := range jiraIssues {
+		if seenTasks[getPickerTaskKey(issue.Connection, issue.Key)] {
+			continue
+		}
+		seenTasks[getPickerTaskKey(issue.Connection, issue.Key)] = true
+		// JIRA matched the query on its own terms, so an issue that does not
+		// fuzzy match still belongs in the list, just further down
+		matchScore, _ := fuzzyMatch(query, issue.Key+" "+issue.Summary)
+		items = append(items, newIssuePickerItem(issue, "JIRA", float64(matchScore)*10))
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Score > items[j].Score
+	})
+	return items
+}
+
+// newIssuePickerItem labels the issue with where it came from, which with
+// more than one connection includes the connection.
+func newIssuePickerItem(issue JIRAIssue, source string, score float64) taskPickerItem {
+	if hasMultipleConnections() && issue.Connection != "" {
+		if source == "JIRA" {
+			source = issue.Connection
+		} else {
+			source = fmt.Sprintf("%s @ %s", source, issue.Connection)
+		}
+	}
+	return taskPickerItem{
+		Label:    fmt.Sprintf("%s · %s · %s", issue.Key, issue.Summary, source),
+		Entry:    WorkLogHistoryEntry{Task: issue.Key, TaskName: issue.Summary, Connection: issue.Connection},
+		Issue:    formatJIRAIssue(issue),
+		FromJIRA: true,
+		Score:    score,
+	}
+}
+
+// getPickerTaskKey identifies a task across the history and the issues of
+// all connections.
+func getPickerTaskKey(connection string, task string) string {
+	return getConnectionName(connection) + "/" + task
+}
+
+// fuzzyMatch reports whether all runes of pattern appear in text in order,
+// ignoring case. Matches at the start of a word and runs of consecutive
+// runes score higher, so "fixlog" ranks "Fix login" above "profile logs".
+func fuzzyMatch(pattern string, text string) (int, bool) {
+	patternRunes := []rune(strings.ToLower(pattern))
+	textRunes := []rune(strings.ToLower(text))
+	if len(patternRunes) == 0 {
+		return 0, true
+	}
+
+	score := 0
+	consecutive := 0
+	p := 0
+	for t := 0; t < len(textRunes) && p < len(patternRunes); t++ {
+		for p < len(patternRunes) && unicode.IsSpace(patternRunes[p]) {
+			p++
+			consecutive = 0
+		}
+		if p == len(patternRunes) {
+			break
+		}
+		if textRunes[t] != patternRunes[p] {
+			consecutive = 0
+			continue
+		}
+		score++
+		if t == 0 || !unicode.IsLetter(textRunes[t-1]) && !unicode.IsDigit(textRunes[t-1]) {
+			score += 3
+		}
+		score += 2 * consecutive
+		consecutive++
+		p++
+	}
+	for p < len(patternRunes) && unicode.IsSpace(patternRunes[p]) {
+		p++
+	}
+	if p < len(patternRunes) {
+		return 0, false
+	}
+	return score, true
+}
+
+func formatHistoryEntry(entry WorkLogHistoryEntry) string {
+	parts := []string{entry.Task}
+	if entry.TaskName != "" && entry.TaskName != entry.Task {
+		parts = append(parts, entry.TaskName)
+	}
+	if entry.Account != "" {
+		parts = append(parts, entry.Account)
+	}
+	if hasMultipleConnections() {
+		parts = append(parts, getConnectionName(entry.Connection))
+	}
+	return strings.Join(parts, " · ")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFrecencyScore(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		count int
+		age   time.Duration
+		want  float64
+	}{
+		{count: 1, age: time.Hour, want: 100},
+		{count: 3, age: 2 * 24 * time.Hour, want: 240},
+		{count: 2, age: 10 * 24 * time.Hour, want: 120},
+		{count: 5, age: 20 * 24 * time.Hour, want: 200},
+		{count: 5, age: 60 * 24 * time.Hour, want: 100},
+		{count: 5, age: 365 * 24 * time.Hour, want: 50},
+		{count: 0, age: time.Hour, want: 0},
+	}
+	for _, test := range tests {
+		entry := WorkLogHistoryEntry{Count: test.count, LastUsage: now.Add(-test.age)}
+		if got := frecencyScore(entry, now); got != test.want {
+			t.Errorf("frecencyScore(count %d, age %s) = %g, want %g", test.count, test.age, got, test.want)
+		}
+	}
+
+	daily := WorkLogHistoryEntry{Count: 5, LastUsage: now.Add(-40 * 24 * time.Hour)}
+	recent := WorkLogHistoryEntry{Count: 3, LastUsage: now.Add(-2 * 24 * time.Hour)}
+	if frecencyScore(recent, now) <= frecencyScore(daily, now) {
+		t.Errorf("a task used a few times this week should rank above one used daily weeks ago")
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		match   bool
+	}{
+		{pattern: "", text: "anything", match: true},
+		{pattern: "abc", text: "ABC-123 Fix login", match: true},
+		{pattern: "fixlog", text: "Fix login", match: true},
+		{pattern: "abc 12", text: "ABC-123", match: true},
+		{pattern: "a b", text: "ab", match: true},
+		{pattern: "fix  login", text: "fixlogin", match: true},
+		{pattern: "ab c", text: "abc", match: true},
+		{pattern: "lgn", text: "login", match: true},
+		{pattern: "nigol", text: "login", match: false},
+		{pattern: "xyz", text: "ABC-123", match: false},
+		{pattern: "loginx", text: "login", match: false},
+	}
+	for _, test := range tests {
+		if _, match := fuzzyMatch(test.pattern, test.text); match != test.match {
+			t.Errorf("fuzzyMatch(%q, %q) matched = %t, want %t", test.pattern, test.text, match, test.match)
+		}
+	}
+}
+
+func TestFuzzyMatchRanking(t *testing.T) {
+	tests := []struct {
+		pattern string
+		better  string
+		worse   string
+	}{
+		{pattern: "fixlog", better: "Fix login", worse: "fix the catalog"},
+		{pattern: "abc", better: "ABC-1", worse: "xaxbxc"},
+		{pattern: "log", better: "login page", worse: "catalog"},
+	}
+	for _, test := range tests {
+		betterScore, betterMatch := fuzzyMatch(test.pattern, test.better)
+		worseScore, worseMatch := fuzzyMatch(test.pattern, test.worse)
+		if !betterMatch || !worseMatch {
+			t.Errorf("fuzzyMatch(%q) should match both %q and %q", test.pattern, test.better, test.worse)
+			continue
+		}
+		if betterScore <= worseScore {
+			t.Errorf("fuzzyMatch(%q) ranks %q (%d) not above %q (%d)", test.pattern, test.better, betterScore, test.worse, worseScore)
+		}
+	}
+}
@@ -2,12 +2,12 @@ package main
 
 import (
 	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"image/color"
-	"io"
 	"io/ioutil"
 	"log"
 	"math"
@@ -41,6 +41,8 @@ var (
 	currentAccount              binding.String = binding.NewString()
 	currentAccountName          binding.String = binding.NewString()
 	currentComment              binding.String = binding.NewString()
+	currentActivity             binding.String = binding.NewString()
+	currentConnection           binding.String = binding.NewString()
 	currentStatus               binding.String = binding.NewString()
 	currentLocation             binding.String = binding.NewString()
 	currentDate                 binding.String = binding.NewString()
@@ -150,7 +152,12 @@ type GeoLocation struct {
 type myTheme struct{}
 
 type WorkLogHistoryRoot struct {
-	WorkLogHistory []WorkLogHistoryEntry `json:"WorkLogHistory"`
+	Version        int                          `json:"version"`
+	WorkLogHistory []WorkLogHistoryEntry        `json:"WorkLogHistory"`
+	TaskAttributes map[string]map[string]string `json:"TaskAttributes,omitempty"`
+	// TaskConnections maps a task to the name of the connection it is booked
+	// on, tasks without an entry are booked on the default connection.
+	TaskConnections map[string]string `json:"TaskConnections,omitempty"`
 }
 
 type WorkLogHistoryEntry struct {
@@ -159,6 +166,8 @@ type WorkLogHistoryEntry struct {
 	Account     string    `json:"account"`
 	AccountName string    `json:"accountName"`
 	Comment     string    `json:"comment"`
+	Activity    string    `json:"activity,omitempty"`
+	Connection  string    `json:"connection,omitempty"`
 	Count       int       `json:"count"`
 	LastUsage   time.Time `json:"time"`
 }
@@ -174,6 +183,8 @@ type WorkLogHistoryEntryWithoutCount struct {
 	Account     string `json:"account"`
 	AccountName string `json:"accountName"`
 	Comment     string `json:"comment"`
+	Activity    string `json:"activity,omitempty"`
+	Connection  string `json:"connection,omitempty"`
 }
 
 type AccountQueryResponse struct {
@@ -273,37 +284,17 @@ type IssueSearchResponse []struct {
 }
 
 type Worklog struct {
-	Attributes            Attributes  `json:"attributes"`
-	BillableSeconds       string      `json:"billableSeconds"`
-	OriginID              int         `json:"originId"`
-	Worker                string      `json:"worker"`
-	Comment               string      `json:"comment"`
-	Started               string      `json:"started"`
-	TimeSpentSeconds      int         `json:"timeSpentSeconds"`
-	OriginTaskID          string      `json:"originTaskId"`
-	RemainingEstimate     interface{} `json:"remainingEstimate"`
-	EndDate               interface{} `json:"endDate"`
-	IncludeNonWorkingDays bool        `json:"includeNonWorkingDays"`
-}
-type Account struct {
-	Name            string `json:"name"`
-	WorkAttributeID int    `json:"workAttributeId"`
-	Value           string `json:"value"`
-}
-type Task struct {
-	Name            string `json:"name"`
-	WorkAttributeID int    `json:"workAttributeId"`
-	Value           string `json:"value"`
-}
-type WorkFrom struct {
-	Name            string `json:"name"`
-	WorkAttributeID int    `json:"workAttributeId"`
-	Value           string `json:"value"`
-}
-type Attributes struct {
-	Account  Account  `json:"_Account_"`
-	Task     Task     `json:"_Task_"`
-	WorkFrom WorkFrom `json:"_WorkFrom_"`
+	Attributes            map[string]WorkAttributeValue `json:"attributes"`
+	BillableSeconds       string                        `json:"billableSeconds"`
+	OriginID              int                           `json:"originId"`
+	Worker                string                        `json:"worker"`
+	Comment               string                        `json:"comment"`
+	Started               string                        `json:"started"`
+	TimeSpentSeconds      int                           `json:"timeSpentSeconds"`
+	OriginTaskID          string                        `json:"originTaskId"`
+	RemainingEstimate     interface{}                   `json:"remainingEstimate"`
+	EndDate               interface{}                   `json:"endDate"`
+	IncludeNonWorkingDays bool                          `json:"includeNonWorkingDays"`
 }
 
 func (m myTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) color.Color {
@@ -330,17 +321,6 @@ func (m myTheme) Icon(name fyne.ThemeIconName) fyne.Resource {
 	return theme.DefaultTheme().Icon(name)
 }
 
-func init() {
-	logFile, err := os.OpenFile("tracker.log",
-		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		s := fmt.Sprintf("\nFailed to open error log file:", err)
-		log.Printf(s)
-		dialog.NewError(err, myWindow).Show()
-	}
-	myLogger = log.New(io.MultiWriter(logFile), "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-}
-
 func getElementFromStringWithColon(input string, index int) string {
 	if strings.Contains(input, ":") {
 		if len(strings.Split(input, ":")) >= index {
@@ -353,15 +333,46 @@ func getElementFromStringWithColon(input string, index int) string {
 	}
 }
 
-func startWorkAndResetUI(newTask string, newTaskName string, account string, accountName string, comment string) {
-	startWork(newTask, newTaskName, currentTask, currentTaskName, account, accountName, currentAccount, currentAccountName, comment, currentComment)
+func startWorkAndResetUI(newTask string, newTaskName string, account string, accountName string, comment string, activity string) {
+	startWork(newTask, newTaskName, currentTask, currentTaskName, account, accountName, currentAccount, currentAccountName, comment, currentComment, activity, currentActivity)
+	paused = false
 	b1.Disable()
 	b2.Enable()
 	b3.Disable()
 	idlenessTicker.Reset(time.Duration(1 * time.Second))
 }
 
-func startWork(task string, taskName string, currentTask binding.String, currentTaskName binding.String, account string, accountName string, currentAccount binding.String, currentAccountName binding.String, comment string, currentComment binding.String) {
+func stopWorkAndResetUI() {
+	stopWork(currentTask, currentTaskName, currentAccount, currentAccountName, currentComment, currentActivity)
+	b1.Enable()
+	b2.Disable()
+	b3.Disable()
+	idlenessDurationDisplay.Set("")
+	idlenessInstantDisplay.Set("")
+}
+
+// startWorkFromDialog starts a task the user chose in the Start or Log Idle
+// dialog. Only such an explicit start offers the JIRA transitions, resuming
+// after a pause or a break and quick switching do not.
+func startWorkFromDialog(newTask string, newTaskName string, account string, accountName string, comment string, activity string) {
+	startWorkAndResetUI(newTask, newTaskName, account, accountName, comment, activity)
+	if account != "" {
+		go offerStartTransition(newTask)
+	}
+}
+
+// stopWorkFromButton stops the running task on the Stop button and offers the
+// JIRA transitions for it.
+func stopWorkFromButton() {
+	stoppedTask, _ := currentTask.Get()
+	stoppedAccount, _ := currentAccount.Get()
+	stopWorkAndResetUI()
+	if stoppedTask != "" && stoppedAccount != "" {
+		go offerStopTransitions(stoppedTask)
+	}
+}
+
+func startWork(task string, taskName string, currentTask binding.String, currentTaskName binding.String, account string, accountName string, currentAccount binding.String, currentAccountName binding.String, comment string, currentComment binding.String, activity string, currentActivity binding.String) {
 	working = true
 	task = strings.Trim(task, "\n")
 	task = strings.Trim(task, "\r")
@@ -376,34 +387,51 @@ func startWork(task string, taskName string, currentTask binding.String, current
 	currentTask.Set(task)
 	currentTaskName.Set(taskName)
 	currentTaskStartTimeDisplay.Set(time.Now().Format("15:04:05"))
-	currentStatus.Set("Working...")
-	currentLocation.Set(getPublicIP())
+	if activity != "" {
+		currentStatus.Set(fmt.Sprintf("Working... (%s)", getActivityName(activity)))
+	} else {
+		currentStatus.Set("Working...")
+	}
+	refreshCurrentLocation()
 	currentAccount.Set(account)
 	currentAccountName.Set(accountName)
 	currentComment.Set(comment)
+	currentActivity.Set(activity)
+	// the worklog goes to the connection the task was started on, even if
+	// the task is bound to another one before it is stopped
+	currentConnection.Set(getTaskConnectionName(task))
+	if account != "" {
+		go loadEstimateForTask(task)
+	}
 }
 
-func stopWork(currentTask binding.String, currentTaskName binding.String, currentAccount binding.String, currentAccountName binding.String, currentComment binding.String) {
+func stopWork(currentTask binding.String, currentTaskName binding.String, currentAccount binding.String, currentAccountName binding.String, currentComment binding.String, currentActivity binding.String) {
 	working = false
 	currentTaskBoundString, currentTaskBindingError := currentTask.Get()
 	currentAccountBoundString, _ := currentAccount.Get()
 	currentTaskNameBoundString, _ := currentTaskName.Get()
 	currentAccountNameBoundString, _ := currentAccountName.Get()
 	currentCommentBoundString, _ := currentComment.Get()
+	currentActivityBoundString, _ := currentActivity.Get()
+	currentConnectionBoundString, _ := currentConnection.Get()
 	if currentTaskBoundString != "" && currentTaskBindingError == nil {
 		myLogger.Printf("Spent %f minutes (%f seconds) on %s\n", time.Since(currentTaskStartInstant).Minutes(), time.Since(currentTaskStartInstant).Seconds(), currentTaskBoundString)
-		writtenBytes, err := fmt.Fprintf(workLogWriter, "%s;%s;%s;%s;%s;%s;%g\r", getPublicIP(), currentTaskBoundString, currentTaskStartInstant.Format("2006-01-02"), currentTaskStartInstant.Format("15:04:05"), time.Now().Format("2006-01-02"), time.Now().Format("15:04:05"), math.Round(time.Since(currentTaskStartInstant).Minutes()))
-		myLogger.Printf("wrote %d bytes\n", writtenBytes)
-		workLogWriter.Flush()
-		go postWorkLog(currentTaskBoundString, currentTaskNameBoundString, currentAccountBoundString, currentAccountNameBoundString, currentCommentBoundString, time.Since(currentTaskStartInstant))
-		if err != nil {
-			panic(err)
+		if bookedDuration, book := getBookedDuration(currentTaskBoundString, currentTaskStartInstant, time.Now()); book {
+			writtenBytes, err := fmt.Fprintf(workLogWriter, "%s;%s;%s;%s;%s;%s;%g;%s\r", getCurrentLocation(), currentTaskBoundString, currentTaskStartInstant.Format("2006-01-02"), currentTaskStartInstant.Format("15:04:05"), time.Now().Format("2006-01-02"), time.Now().Format("15:04:05"), math.Round(bookedDuration.Minutes()), currentActivityBoundString)
+			myLogger.Printf("wrote %d bytes\n", writtenBytes)
+			workLogWriter.Flush()
+			go postWorkLog(currentTaskBoundString, currentConnectionBoundString, currentTaskNameBoundString, currentAccountBoundString, currentAccountNameBoundString, currentCommentBoundString, currentActivityBoundString, getCurrentLocation(), bookedDuration)
+			if err != nil {
+				panic(err)
+			}
 		}
 		currentTask.Set("")
 		currentTaskName.Set("")
 		currentAccount.Set("")
 		currentAccountName.Set("")
 		currentComment.Set("")
+		currentActivity.Set("")
+		currentConnection.Set("")
 		currentTaskStartTimeDisplay.Set("")
 		currentTaskDurationDisplay.Set("")
 		currentStatus.Set("Not Working...")
@@ -411,15 +439,20 @@ func stopWork(currentTask binding.String, currentTaskName binding.String, curren
 
 }
 
-func stopDueToIdleness(currentTask string, currentTaskName string, currentAccount string, currentAccountName string, currentComment string, pointInTimeWhenIWentIdle time.Time) {
+func stopDueToIdleness(currentTask string, currentConnection string, currentTaskName string, currentAccount string, currentAccountName string, currentComment string, currentActivity string, pointInTimeWhenIWentIdle time.Time) {
 	working = false
 	currentStatus.Set(fmt.Sprintf("Idle since %s", time.Now().Format("15:04:05")))
-	currentLocation.Set(getPublicIP())
+	location := getPublicIP()
+	currentLocation.Set(location)
 	myLogger.Printf("Idling for %f minutes (%f seconds) while on %s\n", time.Since(pointInTimeWhenIWentIdle).Minutes(), time.Since(pointInTimeWhenIWentIdle).Seconds(), currentTask)
 	myLogger.Printf("Logging %f minutes (%f seconds)  on %s\n", pointInTimeWhenIWentIdle.Sub(currentTaskStartInstant).Minutes(), pointInTimeWhenIWentIdle.Sub(currentTaskStartInstant).Seconds(), currentTask)
-	writtenBytes, err := fmt.Fprintf(workLogWriter, "%s;%s;%s;%s;%s;%s;%g\r", getPublicIP(), currentTask, currentTaskStartInstant.Format("2006-01-02"), currentTaskStartInstant.Format("15:04:05"), pointInTimeWhenIWentIdle.Format("2006-01-02"), pointInTimeWhenIWentIdle.Format("15:04:05"), math.Round(pointInTimeWhenIWentIdle.Sub(currentTaskStartInstant).Minutes()))
+	bookedDuration, book := getBookedDuration(currentTask, currentTaskStartInstant, pointInTimeWhenIWentIdle)
+	if !book {
+		return
+	}
+	writtenBytes, err := fmt.Fprintf(workLogWriter, "%s;%s;%s;%s;%s;%s;%g;%s\r", location, currentTask, currentTaskStartInstant.Format("2006-01-02"), currentTaskStartInstant.Format("15:04:05"), pointInTimeWhenIWentIdle.Format("2006-01-02"), pointInTimeWhenIWentIdle.Format("15:04:05"), math.Round(bookedDuration.Minutes()), currentActivity)
 	workLogWriter.Flush()
-	go postWorkLog(currentTask, currentTaskName, currentAccount, currentAccountName, currentComment, pointInTimeWhenIWentIdle.Sub(currentTaskStartInstant))
+	go postWorkLog(currentTask, currentConnection, currentTaskName, currentAccount, currentAccountName, currentComment, currentActivity, location, bookedDuration)
 	if err != nil {
 		panic(err)
 	}
@@ -430,8 +463,8 @@ func backupLogWork(currentTaskBoundString string) {
 	myLogger.Printf("Working on %s\n", currentTaskBoundString)
 }
 
-func logIdleWorkAndResetUI(idleTask string, idleTaskName string, idleAccount string, idleAccountName string, idleComment string) {
-	logIdleWork(idleTask, idleTaskName, idleAccount, idleAccountName, idleComment, idlenessInstant)
+func logIdleWorkAndResetUI(idleTask string, idleTaskName string, idleAccount string, idleAccountName string, idleComment string, idleActivity string) {
+	logIdleWork(idleTask, idleTaskName, idleAccount, idleAccountName, idleComment, idleActivity, idlenessInstant)
 	b3.Disable()
 	idlenessDurationDisplay.Set("")
 	idlenessInstantDisplay.Set("")
@@ -440,18 +473,23 @@ func logIdleWorkAndResetUI(idleTask string, idleTaskName string, idleAccount str
 	currentAccount.Set("")
 	currentAccountName.Set("")
 	currentComment.Set("")
+	currentActivity.Set("")
+	currentConnection.Set("")
 	currentTaskStartTimeDisplay.Set("")
 	currentTaskDurationDisplay.Set("")
 }
 
-func logIdleWork(idleTask string, idleTaskName string, idleAccount string, idleAccountName string, idleComment string, pointInTimeWhenIWentIdle time.Time) {
+func logIdleWork(idleTask string, idleTaskName string, idleAccount string, idleAccountName string, idleComment string, idleActivity string, pointInTimeWhenIWentIdle time.Time) {
 	myLogger.Printf("Logging idle work %f minutes (%f seconds) on %s\n", time.Since(pointInTimeWhenIWentIdle).Minutes(), time.Since(pointInTimeWhenIWentIdle).Seconds(), idleTask)
-	writtenBytes, err := fmt.Fprintf(workLogWriter, "%s; %s;%s;%s;%s;%s;%g\r", getPublicIP(), idleTask, pointInTimeWhenIWentIdle.Format("2006-01-02"), pointInTimeWhenIWentIdle.Format("15:04:05"), time.Now().Format("2006-01-02"), time.Now().Format("15:04:05"), math.Round(time.Since(pointInTimeWhenIWentIdle).Minutes()))
-	myLogger.Printf("wrote %d bytes\n", writtenBytes)
-	workLogWriter.Flush()
-	go postWorkLog(idleTask, idleTaskName, idleAccount, idleAccountName, idleComment, time.Since(pointInTimeWhenIWentIdle))
-	if err != nil {
-		panic(err)
+	if bookedDuration, book := getBookedDuration(idleTask, pointInTimeWhenIWentIdle, time.Now()); book {
+		location := getPublicIP()
+		writtenBytes, err := fmt.Fprintf(workLogWriter, "%s; %s;%s;%s;%s;%s;%g;%s\r", location, idleTask, pointInTimeWhenIWentIdle.Format("2006-01-02"), pointInTimeWhenIWentIdle.Format("15:04:05"), time.Now().Format("2006-01-02"), time.Now().Format("15:04:05"), math.Round(bookedDuration.Minutes()), idleActivity)
+		myLogger.Printf("wrote %d bytes\n", writtenBytes)
+		workLogWriter.Flush()
+		go postWorkLog(idleTask, getTaskConnectionName(idleTask), idleTaskName, idleAccount, idleAccountName, idleComment, idleActivity, location, bookedDuration)
+		if err != nil {
+			panic(err)
+		}
 	}
 	currentTask.Set("")
 	currentTaskName.Set("")
@@ -476,7 +514,7 @@ func getIdleDuration() time.Duration {
 	currentTickCount, _, _ := getTickCount.Call()
 	r1, _, err := getLastInputInfo.Call(uintptr(unsafe.Pointer(&lastInputInfo)))
 	if r1 == 0 {
-		myLogger.Println("error getting last input info: " + err.Error())
+		myErrorLogger.Printf("Got error getting last input info: %s", err.Error())
 		dialog.NewError(err, myWindow).Show()
 	}
 	return time.Duration((uint32(currentTickCount) - lastInputInfo.dwTime)) * time.Millisecond
@@ -527,7 +565,7 @@ func getPublicIP() string {
 	resp, err := client.Do(req)
 
 	if err != nil {
-		myLogger.Printf("\nGot error %s", err.Error())
+		myErrorLogger.Printf("Got error %s", err.Error())
 		dialog.NewError(err, myWindow).Show()
 		return "no network"
 	} else {
@@ -539,13 +577,30 @@ func getPublicIP() string {
 		var myIP MyIPAddress
 		err = json.NewDecoder(resp.Body).Decode(&myIP)
 		if err != nil {
-			myLogger.Printf("\nDecode Failed %s", err.Error())
+			myErrorLogger.Printf("Decode Failed %s", err.Error())
 		}
 
 		return myIP.IP
 	}
 }
 
+// getCurrentLocation returns the public IP looked up when the tracker or the
+// current task started, for code on the ticker or holding a lock, which must
+// not wait for a new lookup.
+func getCurrentLocation() string {
+	location, _ := currentLocation.Get()
+	return location
+}
+
+// refreshCurrentLocation looks up the public IP again in the background, so
+// that starting a task, which pausing and focus mode do from the ticker,
+// never waits for the lookup.
+func refreshCurrentLocation() {
+	go func() {
+		currentLocation.Set(getPublicIP())
+	}()
+}
+
 func getBingImageOfTheDay() fyne.Resource {
 
 	defaulticon, _ := fyne.LoadResourceFromPath("icon.jpg")
@@ -566,7 +621,7 @@ func getBingImageOfTheDay() fyne.Resource {
 	resp, err := client.Do(req)
 
 	if err != nil {
-		myLogger.Printf("\nGot error %s", err.Error())
+		myErrorLogger.Printf("Got error %s", err.Error())
 		dialog.NewError(err, myWindow).Show()
 		return defaulticon
 	} else {
@@ -578,7 +633,7 @@ func getBingImageOfTheDay() fyne.Resource {
 		var myBingImage BingImageOfTheDay
 		err = json.NewDecoder(resp.Body).Decode(&myBingImage)
 		if err != nil {
-			myLogger.Printf("\nDecode Failed %s", err.Error())
+			myErrorLogger.Printf("Decode Failed %s", err.Error())
 		}
 
 		if len(myBingImage.Images) > 0 {
@@ -587,7 +642,7 @@ func getBingImageOfTheDay() fyne.Resource {
 
 			icon, err := fyne.LoadResourceFromURLString("https://www.bing.com" + myBingImage.Images[0].URL)
 			if err != nil {
-				myLogger.Printf("\nFetching Bing Image Failed %s", err.Error())
+				myErrorLogger.Printf("Fetching Bing Image Failed %s", err.Error())
 				return defaulticon
 			} else {
 				return icon
@@ -624,7 +679,7 @@ func firstNStories(s []int, n int) []int {
 func parseURL(urlStr string) *url.URL {
 	link, err := url.Parse(urlStr)
 	if err != nil {
-		myLogger.Printf("Could not parse URL", err)
+		myWarningLogger.Printf("Could not parse URL %s", err.Error())
 	}
 
 	return link
@@ -648,7 +703,7 @@ func getBingImageData(myBingImage BingImageOfTheDay) []byte {
 	resp, err := client.Do(req)
 
 	if err != nil {
-		myLogger.Printf("\nGot error %s", err.Error())
+		myErrorLogger.Printf("Got error %s", err.Error())
 		dialog.NewError(err, myWindow).Show()
 		return []byte{}
 	} else {
@@ -679,7 +734,7 @@ func getMyTopStories() []Story {
 	resp, err := client.Do(req)
 
 	if err != nil {
-		myLogger.Printf("\nGot error %s", err.Error())
+		myErrorLogger.Printf("Got error %s", err.Error())
 		dialog.NewError(err, myWindow).Show()
 		return []Story{}
 	} else {
@@ -691,7 +746,7 @@ func getMyTopStories() []Story {
 		var myTopStories []int = make([]int, 0)
 		err = json.NewDecoder(resp.Body).Decode(&myTopStories)
 		if err != nil {
-			myLogger.Printf("\nDecode Failed %s", err.Error())
+			myErrorLogger.Printf("Decode Failed %s", err.Error())
 		}
 
 		var topStoryCollection []Story = make([]Story, 0)
@@ -719,7 +774,7 @@ func getStoryDetails(story int) Story {
 	resp, err := client.Do(req)
 
 	if err != nil {
-		myLogger.Printf("\nGot error %s", err.Error())
+		myErrorLogger.Printf("Got error %s", err.Error())
 		dialog.NewError(err, myWindow).Show()
 		return Story{}
 	} else {
@@ -731,7 +786,7 @@ func getStoryDetails(story int) Story {
 		var myStory Story
 		err = json.NewDecoder(resp.Body).Decode(&myStory)
 		if err != nil {
-			myLogger.Printf("\nDecode Failed %s", err.Error())
+			myErrorLogger.Printf("Decode Failed %s", err.Error())
 		}
 		if myStory.URL == "" {
 			myStory.URL = fmt.Sprintf("https://news.ycombinator.com/item?id=%d", story)
@@ -757,7 +812,7 @@ func getMyLocation() string {
 	resp, err := client.Do(req)
 
 	if err != nil {
-		myLogger.Printf("\nGot error %s", err.Error())
+		myErrorLogger.Printf("Got error %s", err.Error())
 		dialog.NewError(err, myWindow).Show()
 		return "no network"
 	} else {
@@ -769,265 +824,179 @@ func getMyLocation() string {
 		var myLocation GeoLocation
 		err = json.NewDecoder(resp.Body).Decode(&myLocation)
 		if err != nil {
-			myLogger.Printf("\nDecode Failed %s", err.Error())
+			myErrorLogger.Printf("Decode Failed %s", err.Error())
 		}
 
 		return fmt.Sprintf("%s, %s", myLocation.Query, myLocation.City)
 	}
 }
 
-func getProjectAndAccountForIssue(issue string) IssueWithProjectAndActivity {
-	issue = url.QueryEscape(issue)
-	url := fmt.Sprintf("https://jira.surecomp.com/rest/api/latest/issue/%s?fields=project,customfield_10900", issue)
-
-	client := &http.Client{
-		Timeout: time.Second * 60,
-	}
-
-	req, err := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "Bearer ")
-	req.Header.Set("Content-Type", "application/json")
-	if err != nil {
-		myLogger.Printf("\nGot error %s", err.Error())
-		dialog.NewError(err, myWindow).Show()
+func getProjectAndAccountForIssue(connection *jiraConnection, issue string) IssueWithProjectAndActivity {
+	var issueResponse IssueWithProjectAndActivity
+	var err error
+	if connection.isCloud() {
+		issueResponse, err = getCloudProjectAndAccountForIssue(connection, issue)
+	} else {
+		path := connection.apiPath("/issue/%s?fields=project,customfield_10900", url.PathEscape(issue))
+		myLogger.Printf("Requesting project and accounts for issue from %s %s", connection.Config.Name, path)
+		err = connection.JIRA.Do(context.Background(), "GET", path, nil, &issueResponse)
 	}
-	myLogger.Printf("Requesting project and accounts for issue from JIRA %s", url)
-
-	timeWhenPostWasSent := time.Now()
-	resp, err := client.Do(req)
-
 	if err != nil {
-		myLogger.Printf("\nGot error %s", err.Error())
+		myErrorLogger.Printf("Got error %s", err.Error())
 		dialog.NewError(err, myWindow).Show()
 		return IssueWithProjectAndActivity{}
-	} else {
-
-		myLogger.Printf("Got Response Code %s", resp.Status)
-		myLogger.Printf("Requesting project and accounts for issue from JIRA took %s", time.Since(timeWhenPostWasSent).String())
-
-		defer resp.Body.Close()
+	}
+	return issueResponse
+}
 
-		var issueResponse IssueWithProjectAndActivity
-		err = json.NewDecoder(resp.Body).Decode(&issueResponse)
+func getAccountsForProject(connection *jiraConnection, projectID string) []string {
+	if connection.isCloud() {
+		accounts, err := getCloudAccountsForProject(connection, projectID)
 		if err != nil {
-			myLogger.Printf("\nDecode Failed %s", err.Error())
+			myErrorLogger.Printf("Got error %s", err.Error())
 		}
-
-		return issueResponse
+		return accounts
 	}
-}
-
-func getAccountsForProject(projectID string) []string {
 	tqlQuery := url.QueryEscape(fmt.Sprintf(`status in ("OPEN") AND project =%s`, projectID))
-	url := fmt.Sprintf("https://jira.surecomp.com/rest/tempo-accounts/1/account/search?tqlQuery=%s", tqlQuery)
-
-	client := &http.Client{
-		Timeout: time.Second * 60,
-	}
-
-	req, err := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "Bearer ")
-	req.Header.Set("Content-Type", "application/json")
-	if err != nil {
-		myLogger.Printf("\nGot error %s", err.Error())
-	}
-	myLogger.Printf("Requesting project accounts from JIRA %s", url)
-
-	timeWhenPostWasSent := time.Now()
-	resp, err := client.Do(req)
+	path := fmt.Sprintf("/rest/tempo-accounts/1/account/search?tqlQuery=%s", tqlQuery)
+	myLogger.Printf("Requesting project accounts from %s %s", connection.Config.Name, path)
 
 	var result []string = make([]string, 0)
 
+	var response AccountQueryResponse
+	err := connection.Tempo.Do(context.Background(), "GET", path, nil, &response)
 	if err != nil {
-		myLogger.Println("\nGot error %s", err.Error())
-	} else {
-
-		myLogger.Printf("Got Response Code %s", resp.Status)
-		myLogger.Printf("Requesting project accounts from JIRA took %s", time.Since(timeWhenPostWasSent).String())
-
-		defer resp.Body.Close()
-
-		var response AccountQueryResponse
-		err = json.NewDecoder(resp.Body).Decode(&response)
-		if err != nil {
-			myLogger.Printf("\nDecode Failed %s", err.Error())
-			dialog.NewError(err, myWindow).Show()
-		}
-
-		if &response != nil {
-			for i := 0; i < len(response.Accounts); i++ {
-				result = append(result, fmt.Sprintf("%s: %s", response.Accounts[i].Key, response.Accounts[i].Name))
-			}
-		}
+		myErrorLogger.Printf("Got error %s", err.Error())
+		return result
+	}
+	for i := 0; i < len(response.Accounts); i++ {
+		result = append(result, fmt.Sprintf("%s: %s", response.Accounts[i].Key, response.Accounts[i].Name))
 	}
 	return result
 }
 
-func searchJIRAIsssue(q string) []string {
-	var result []string = make([]string, 0)
-
-	if checkJIRA, _ := searchJIRAForTasks.Get(); !checkJIRA {
-		return result
+// searchJIRAIsssue asks the JIRA quick search for issues matching q. Unlike
+// the other JIRA calls it does not show error dialogs, because it runs while
+// the user is typing and the caller falls back to the issue cache instead.
+func searchJIRAIsssue(ctx context.Context, connection *jiraConnection, q string) ([]JIRAIssue, error) {
+	if connection.isCloud() {
+		return searchJIRACloudIssuePicker(ctx, connection, q)
 	}
+	var result []JIRAIssue = make([]JIRAIssue, 0)
 
-	q = url.QueryEscape(q)
-	url := fmt.Sprintf("https://jira.surecomp.com/rest/quicksearch/1.0/productsearch/search?q=%s&_=%d", q, time.Now().UnixMilli())
+	path := fmt.Sprintf("/rest/quicksearch/1.0/productsearch/search?q=%s&_=%d", url.QueryEscape(q), time.Now().UnixMilli())
+	myLogger.Printf("Requesting issues from %s %s", connection.Config.Name, path)
 
-	client := &http.Client{
-		Timeout: time.Second * 60,
+	var issueResponse IssueSearchResponse
+	err := connection.JIRA.Do(ctx, "GET", path, nil, &issueResponse)
+	if err != nil {
+		myErrorLogger.Printf("Got error %s", err.Error())
+		return result, err
 	}
 
-	req, err := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "Bearer ")
-	req.Header.Set("Content-Type", "application/json")
-	if err != nil {
-		myLogger.Printf("\nGot error %s", err.Error())
-		dialog.NewError(err, myWindow).Show()
+	fetched := time.Now()
+	for i := 0; i < len(issueResponse); i++ {
+		if issueResponse[i].Name == "Issues" {
+			for j := 0; j < len(issueResponse[i].Items); j++ {
+				key := issueResponse[i].Items[j].Subtitle
+				result = append(result, JIRAIssue{
+					Key:     key,
+					Summary: issueResponse[i].Items[j].Title,
+					Project: getProjectKeyFromIssueKey(key),
+					Fetched: fetched,
+				})
+			}
+		}
 	}
-	myLogger.Printf("Requesting issues from JIRA %s", url)
+	return result, nil
+}
 
-	timeWhenPostWasSent := time.Now()
-	resp, err := client.Do(req)
+func postWorkLog(task string, connectionName string, taskName string, account string, accountName string, comment string, activity string, location string, duration time.Duration) {
+	postWorkLogStartedAt(task, connectionName, taskName, account, accountName, comment, activity, location, time.Now().Add(-duration), duration)
+}
 
-	if err != nil {
-		myLogger.Printf("\nGot error %s", err.Error())
+// postWorkLogStartedAt books a worklog that started at a given time, e.g. a
+// past meeting, rather than just now. location is the public IP where the
+// work was recorded, which sets the work location of the worklog.
+func postWorkLogStartedAt(task string, connectionName string, taskName string, account string, accountName string, comment string, activity string, location string, started time.Time, duration time.Duration) {
+	saveWorkLogToHistory(task, connectionName, taskName, account, accountName, comment, activity)
+	if trackerConfig.Submission.Mode == submissionDraft {
+		addDraft(task, connectionName, taskName, account, accountName, comment, activity, location, started, duration)
+		return
+	}
+	if err := submitWorkLog(task, connectionName, taskName, account, accountName, comment, activity, location, started, duration); err != nil {
+		myErrorLogger.Printf("Got error %s", err.Error())
 		dialog.NewError(err, myWindow).Show()
-	} else {
-
-		myLogger.Printf("Got Response Code %s", resp.Status)
-		myLogger.Printf("Requesting issues from JIRA took %s", time.Since(timeWhenPostWasSent).String())
-
-		defer resp.Body.Close()
-
-		var issueResponse IssueSearchResponse
-		err = json.NewDecoder(resp.Body).Decode(&issueResponse)
-		if err != nil {
-			myLogger.Printf("\nDecode Failed %s", err.Error())
-			dialog.NewError(err, myWindow).Show()
-		}
-
-		if issueResponse != nil {
-			for i := 0; i < len(issueResponse); i++ {
-				if issueResponse[i].Name == "Issues" {
-					if issueResponse[i].Items != nil {
-						for j := 0; j < len(issueResponse[i].Items); j++ {
-							title := issueResponse[i].Items[j].Title
-							if len(title) > 35 {
-								title = title[0:35] + "..."
-							}
-							result = append(result, fmt.Sprintf("%s: %s", issueResponse[i].Items[j].Subtitle, title))
-						}
-					}
-				}
-			}
-		}
 	}
-	return result
 }
 
-func postWorkLog(task string, taskName string, account string, accountName string, comment string, duration time.Duration) {
-	saveWorkLogToHistory(task, taskName, account, accountName, comment)
-	myLocation := getPublicIP()
+// submitWorkLog books a worklog in Tempo and posts its comment to the issue
+// when asked to. Only a failed worklog is returned, a comment that could not
+// be posted is reported right away.
+func submitWorkLog(task string, connectionName string, taskName string, account string, accountName string, comment string, activity string, myLocation string, started time.Time, duration time.Duration) error {
+	connection := getConnection(connectionName)
 
 	var originTaskID string
 	var accountValue string
 	if account == "" {
-		originTaskID = "71238"
-		accountValue = "INT101"
+		if connection.Config.InternalIssue == "" {
+			return fmt.Errorf("%s has no account and %s has no internal issue to book it on", task, connection.Config.Name)
+		}
+		originTaskID = connection.Config.InternalIssue
+		accountValue = connection.Config.InternalAccount
 	} else {
 		originTaskID = task
 		accountValue = account
 	}
+	commentData := newCommentTemplateData(task, taskName, accountName, comment, activity, myLocation, started, duration)
 	var finalComment string
 	if comment != "" {
-		finalComment = fmt.Sprintf("%s\nWorking from %s\nAutomatically filled by MyTracker written in GoLang", comment, myLocation)
+		finalComment = buildComment(comment, renderCommentFooter(trackerConfig.Comments.WorklogFooter, commentData))
 	} else {
-		finalComment = fmt.Sprintf("%s\nWorking from %s\nAutomatically filled by MyTracker written in GoLang", task, myLocation)
+		finalComment = buildComment(task, renderCommentFooter(trackerConfig.Comments.WorklogFooter, commentData))
 	}
-	today := time.Now().Format("2006-01-02")
 	durationInSeconds := int(duration.Seconds())
-	buf := new(bytes.Buffer)
 
-	var workLocation string
-	if strings.HasPrefix(myLocation, "89.245") {
-		workLocation = "Office"
-	} else {
-		workLocation = "Home"
+	workLocation := getWorkLocation(myLocation)
+	var remainingEstimate interface{}
+	if account != "" {
+		remainingEstimate = getRemainingEstimateForWorklog(task, duration)
+	}
+	attributes, err := buildWorkAttributes(connection, task, accountValue, activity, workLocation)
+	if err != nil {
+		return err
 	}
 	u := Worklog{
-		Attributes: Attributes{
-			Account{Name: "Activity", WorkAttributeID: 1, Value: accountValue},
-			Task{Name: "Task", WorkAttributeID: 2, Value: "Administration"},
-			WorkFrom{Name: "Work From", WorkAttributeID: 4, Value: workLocation}},
+		Attributes:            attributes,
 		BillableSeconds:       "",
 		OriginID:              -1,
-		Worker:                "JIRAUSER11920",
+		Worker:                getWorker(connection),
 		Comment:               finalComment,
-		Started:               today,
+		Started:               started.Format("2006-01-02"),
 		TimeSpentSeconds:      durationInSeconds,
 		OriginTaskID:          originTaskID,
-		RemainingEstimate:     nil,
+		RemainingEstimate:     remainingEstimate,
 		EndDate:               nil,
 		IncludeNonWorkingDays: false}
-	json.NewEncoder(buf).Encode(&u)
 
-	client := &http.Client{
-		Timeout: time.Second * 60,
+	myLogger.Printf("Posting worklog %s %s %d to %s", task, duration.String(), durationInSeconds, connection.Config.Name)
+	timeWhenPostWasSent := time.Now()
+	if connection.isCloud() {
+		err = postTempoCloudWorklog(connection, u, started)
+	} else {
+		err = connection.Tempo.Do(context.Background(), "POST", "/rest/tempo-timesheets/4/worklogs", &u, nil)
 	}
-
-	req, err := http.NewRequest("POST", "https://jira.surecomp.com/rest/tempo-timesheets/4/worklogs", buf)
-	req.Header.Set("Authorization", "Bearer ")
-	req.Header.Set("Content-Type", "application/json")
+	myLogger.Printf("Posting Worklog took %s", time.Since(timeWhenPostWasSent).String())
 	if err != nil {
-		myLogger.Printf("\nGot error %s", err.Error())
-		dialog.NewError(err, myWindow).Show()
+		return err
 	}
-
-	myLogger.Printf("Posting worklog %s %s %d", task, duration.String(), durationInSeconds)
-	timeWhenPostWasSent := time.Now()
-	resp, err := client.Do(req)
-	if err != nil {
-		myLogger.Printf("\nGot error %s", err.Error())
-		dialog.NewError(err, myWindow).Show()
-	} else {
-		myLogger.Printf("Got Response Code %s", resp.Status)
-		if resp.StatusCode != http.StatusOK {
-			err := errors.New(fmt.Sprintf("JIRA returned error code %s", resp.Status))
+	if account != "" && comment != "" && shouldPostCommentToIssue(task) {
+		if err := postIssueComment(connection, task, comment, commentData); err != nil {
+			myErrorLogger.Printf("Got error %s", err.Error())
 			dialog.NewError(err, myWindow).Show()
 		}
-		myLogger.Printf("Posting Worklog took %s", time.Since(timeWhenPostWasSent).String())
-		defer resp.Body.Close()
-	}
-}
-
-func getStringFromHistory(worklogHistory WorkLogHistoryRoot, recencyMode string) []string {
-	history := worklogHistory.WorkLogHistory
-
-	if recencyMode == "LFU" {
-		sort.SliceStable(history, func(i, j int) bool {
-			return history[i].Count > history[j].Count
-		})
-	} else if recencyMode == "LRU" {
-		sort.SliceStable(history, func(i, j int) bool {
-			return history[i].LastUsage.After(history[j].LastUsage)
-		})
-	} else if recencyMode == "A to Z" {
-		sort.SliceStable(history, func(i, j int) bool {
-			return history[i].Task < (history[j].Task)
-		})
-	} else if recencyMode == "Z to A" {
-		sort.SliceStable(history, func(i, j int) bool {
-			return history[i].Task > (history[j].Task)
-		})
 	}
-
-	list := []string{}
-	for _, entry := range history {
-		buff, _ := json.Marshal(entry)
-		list = append(list, fmt.Sprintf("%s", buff))
-	}
-
-	return list
+	return nil
 }
 
 func getIdOfHistoryEntry(entry WorkLogHistoryEntry) WorkLogHistoryEntryWithoutCount {
@@ -1037,6 +1006,8 @@ func getIdOfHistoryEntry(entry WorkLogHistoryEntry) WorkLogHistoryEntryWithoutCo
 		Account:     entry.Account,
 		AccountName: entry.AccountName,
 		Comment:     entry.Comment,
+		Activity:    entry.Activity,
+		Connection:  entry.Connection,
 	}
 	return u
 }
@@ -1076,6 +1047,8 @@ func sortWorkLogHistory(worklogHistory WorkLogHistoryRoot) []WorkLogHistoryEntry
 			Account:     key.Account,
 			AccountName: key.AccountName,
 			Comment:     key.Comment,
+			Activity:    key.Activity,
+			Connection:  key.Connection,
 			Count:       value.Count,
 			LastUsage:   value.LastUsage,
 		}
@@ -1099,72 +1072,80 @@ func sortTasksFromHistory(history []WorkLogHistoryEntry) []WorkLogHistoryEntry {
 	return history
 }
 
-func saveWorkLogToHistory(task string, taskName string, account string, accountName string, comment string) {
-	workLogHistoryFile, err := os.OpenFile("work.history", os.O_TRUNC|os.O_WRONLY, 0644)
-	if err != nil {
-		myLogger.Printf("\nGot error when writing history %s", err.Error())
-		dialog.NewError(err, myWindow).Show()
-	}
-	defer workLogHistoryFile.Close()
-
-	workLogHistoryWriter := bufio.NewWriter(workLogHistoryFile)
-
-	buf := new(bytes.Buffer)
-
+func saveWorkLogToHistory(task string, connectionName string, taskName string, account string, accountName string, comment string, activity string) {
 	u := WorkLogHistoryEntry{
 		Task:        task,
 		TaskName:    taskName,
 		Account:     account,
 		AccountName: accountName,
 		Comment:     comment,
+		Activity:    activity,
+		Connection:  connectionName,
 		Count:       1,
 		LastUsage:   time.Now(),
 	}
 
+	workLogHistoryMutex.Lock()
 	worklogHistory.WorkLogHistory = append(worklogHistory.WorkLogHistory, u)
 	worklogHistory.WorkLogHistory = sortWorkLogHistory(worklogHistory)
 	worklogHistory.WorkLogHistory = sortTasksFromHistory(worklogHistory.WorkLogHistory)
+	workLogHistoryMutex.Unlock()
 
-	json.NewEncoder(buf).Encode(&worklogHistory)
+	saveWorkLogHistory()
+}
 
-	writtenBytes, err := fmt.Fprintf(workLogHistoryWriter, "%s", buf)
+func main() {
+	dataDirectoryFlag := flag.String("data-dir", "", fmt.Sprintf("directory for the worklog, the history, the caches and the config (default $%s or the per-user data directory)", dataDirectoryEnv))
+	flag.BoolVar(&debugLogging, "debug", false, "log at debug level, including the metadata of all HTTP requests")
+	flag.Parse()
+	err := setupDataDirectory(*dataDirectoryFlag)
 	if err != nil {
-		myLogger.Printf("\nGot error when writing history %s", err.Error())
-		dialog.NewError(err, myWindow).Show()
+		fmt.Fprintf(os.Stderr, "timetracker: cannot use the data directory, check -data-dir and $%s: %s\n", dataDirectoryEnv, err.Error())
+		os.Exit(1)
 	}
-	myLogger.Printf("wrote %d bytes to history\n", writtenBytes)
-	workLogHistoryWriter.Flush()
-}
 
-func retrieveWorklogHistory() {
-	file, err := os.OpenFile("work.history", os.O_CREATE|os.O_RDONLY, 0644)
-	if err != nil {
-		myLogger.Printf("\nGot error when reading history %s", err.Error())
-		dialog.NewError(err, myWindow).Show()
+	// until this is the running instance, log to stderr only, so that a
+	// second launch leaves the files of the running one alone
+	setLoggers(os.Stderr)
+	if !acquireSingleInstance(flag.Args()) {
+		return
 	}
-	defer file.Close()
+	defer releaseSingleInstance()
 
-	err = json.NewDecoder(file).Decode(&worklogHistory)
-	myLogger.Printf("Retrieved worklog history of size %d", len(worklogHistory.WorkLogHistory))
-	if err != nil {
-		myLogger.Printf("\nGot error when reading history %s", err.Error())
-		dialog.NewError(err, myWindow).Show()
+	migrationMessages := migrateDataFiles()
+	openTrackerLog()
+	myLogger.Printf("Using data directory %s", dataDirectory)
+	for _, message := range migrationMessages {
+		myLogger.Printf("%s", message)
 	}
-}
 
-func main() {
-	workLogFile, err := os.OpenFile("work.log", os.O_APPEND|os.O_WRONLY, 0644)
+	workLogFile, err := os.OpenFile(getDataFilePath("work.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
 		panic(err)
 	}
 	workLogWriter = bufio.NewWriter(workLogFile)
 
-	retrieveWorklogHistory()
-
 	defer workLogFile.Close()
 
 	myApp := app.NewWithID("GoTimeTracker")
 	myWindow = myApp.NewWindow("MyTimeTracker")
+
+	// read after creating the window, so that problems can be shown in it
+	retrieveWorklogHistory()
+	retrieveTrackerConfig()
+	configureLogging()
+	setupConnections()
+	authorizeConnections()
+	retrieveIssueCache()
+	retrieveAccountCache()
+	retrieveDismissedSuggestions()
+	retrieveDrafts()
+	retrieveShortEntryCarry()
+	setupGitWatchers()
+	setupWindowTracking()
+	for _, connection := range jiraConnections {
+		getWorkAttributes(connection)
+	}
 	myApp.Settings().SetTheme(&myTheme{})
 	icon = getBingImageOfTheDay()
 	myWindow.SetIcon(icon)
@@ -1228,7 +1209,6 @@ func main() {
 
 	b1 = widget.NewButton("\r\nStart\r\n", func() {
 		var startDialog dialog.Dialog
-		var entryOptions []string = make([]string, 0)
 		var accountOptions []string = make([]string, 0)
 		var accountOptionsFiltered []string = make([]string, 0)
 		var accountSelected string
@@ -1277,109 +1257,117 @@ func main() {
 		projectEntry.Disable()
 
 		commentEntry := widget.NewEntry()
-
-		var workLogHistoryAsStrings = getStringFromHistory(worklogHistory, "LRU")
-		recentEntry := widget.NewSelect(workLogHistoryAsStrings, func(s string) {
-			var historyEntry WorkLogHistoryEntry
-			json.Unmarshal([]byte(s), &historyEntry)
-			startWorkAndResetUI(historyEntry.Task, historyEntry.TaskName, historyEntry.Account, historyEntry.AccountName, historyEntry.Comment)
-			startDialog.Hide()
+		postCommentCheck := widget.NewCheck("", nil)
+		postCommentCheck.SetChecked(trackerConfig.Comments.PostToIssueByDefault)
+
+		attributeForm := newWorkAttributeForm(getDefaultConnection())
+		estimateForm := newRemainingEstimateForm()
+		var activitySelected string
+		activitySelect := newActivitySelect(func(activity string) {
+			activitySelected = activity
 		})
 
-		entry := widget.NewSelectEntry(entryOptions)
-		entry.OnChanged = func(changeEntry string) {
+		picker := newTaskPicker()
+		picker.OnHistoryChosen = func(historyEntry WorkLogHistoryEntry) {
+			startWorkFromDialog(historyEntry.Task, historyEntry.TaskName, historyEntry.Account, historyEntry.AccountName, historyEntry.Comment, historyEntry.Activity)
+			startDialog.Hide()
+		}
+		picker.OnChanged = func(changeEntry string) {
 			accountOptions = nil
 			accountEntry.SetOptions(nil)
 			accountEntry.Refresh()
 			accountEntry.SetText("")
-			for _, option := range entryOptions {
-				if option == changeEntry {
-					entry.SetText(changeEntry)
-					entryOptions = nil
-					entry.SetOptions(nil)
-					entry.TextStyle.Bold = false
-					entry.TextStyle.Italic = false
-					entry.Refresh()
-					projectAndAccountForIssue := getProjectAndAccountForIssue(getElementFromStringWithColon(changeEntry, 0))
-					if projectAndAccountForIssue != (IssueWithProjectAndActivity{}) {
-						if projectAndAccountForIssue.Fields.Customfield10900.Key != "" {
-							standardAccount := fmt.Sprintf("%s:%s", projectAndAccountForIssue.Fields.Customfield10900.Key, projectAndAccountForIssue.Fields.Customfield10900.Name)
-							accountOptions = append(accountOptions, standardAccount)
-							if standardAccount != "" {
-								accountEntry.SetText(standardAccount)
-							}
-						}
-						projectEntry.Text = fmt.Sprintf("%s:%s:%s", projectAndAccountForIssue.Fields.Project.ID, projectAndAccountForIssue.Fields.Project.Key, projectAndAccountForIssue.Fields.Project.Name)
-						if len(projectAndAccountForIssue.Fields.Project.ID) > 0 {
-							accountOptions = append(accountOptions, getAccountsForProject(projectAndAccountForIssue.Fields.Project.ID)...)
-						}
-					}
-					accountEntry.SetOptions(accountOptions)
-					accountEntry.Refresh()
-					projectEntry.Refresh()
-					return
-				}
-				if strings.Contains(option, changeEntry) {
-					return
-				}
-			}
-			if len(changeEntry) > 3 {
-				issues := searchJIRAIsssue(changeEntry)
-				if len(issues) > 0 {
-					entry.TextStyle.Bold = true
-					entry.TextStyle.Italic = true
-					entry.Refresh()
-				} else {
-					entry.TextStyle.Bold = false
-					entry.TextStyle.Italic = false
-					entry.Refresh()
-				}
-				entryOptions = nil
-				entry.SetOptions(issues)
-				entryOptions = issues
-			} else {
-				entry.TextStyle.Bold = false
-				entry.TextStyle.Italic = false
-				entry.Refresh()
-			}
+			projectEntry.SetText("")
 		}
+		picker.OnIssueChosen = func(issue string) {
+			var standardAccount string
+			var project string
+			accountOptions, standardAccount, project = getAccountOptionsForIssue(getConnectionForTask(getElementFromStringWithColon(issue, 0)), getElementFromStringWithColon(issue, 0))
+			accountEntry.SetOptions(accountOptions)
+			accountEntry.SetText(standardAccount)
+			accountEntry.Refresh()
+			projectEntry.SetText(project)
+			attributeForm.SetConnection(getConnectionForTask(getElementFromStringWithColon(issue, 0)))
+			attributeForm.SetValues(getTaskAttributes(getElementFromStringWithColon(issue, 0)))
+			activitySelect.SetSelected(getActivityName(getDefaultActivityForTask(getElementFromStringWithColon(issue, 0))))
+			estimateForm.SetIssue(getElementFromStringWithColon(issue, 0))
+			postCommentCheck.SetChecked(shouldPostCommentToIssue(getElementFromStringWithColon(issue, 0)))
+		}
+
+		entry := picker.Entry
 		entry.Validator = taskValidator
 
-		formListRecent := widget.NewFormItem("Choose recent", recentEntry)
-		formItem := widget.NewFormItem("Enter task name", entry)
+		formItem := widget.NewFormItem("Task", entry)
+		formListTasks := widget.NewFormItem("", picker.Container(fyne.NewSize(600, 180)))
 		commentFormItem := widget.NewFormItem("Comment", commentEntry)
+		postCommentFormItem := widget.NewFormItem("Post comment to issue?", postCommentCheck)
 		accountFormItem := widget.NewFormItem("Account", accountEntry)
 		projectFormItem := widget.NewFormItem("Project", projectEntry)
+		rememberAccountCheck := widget.NewCheck("", nil)
+		formItemRememberAccount := widget.NewFormItem("Remember account for project?", rememberAccountCheck)
 		jiraCheck := widget.NewCheckWithData("", searchJIRAForTasks)
 		formItemJIRACheck := widget.NewFormItem("Search JIRA for tasks?", jiraCheck)
-		radio := widget.NewRadioGroup([]string{"LRU", "LFU", "A to Z", "Z to A"}, func(value string) {
-			recentEntry.Options = getStringFromHistory(worklogHistory, value)
-			recentEntry.Refresh()
-		})
-		radio.Horizontal = true
-		radio.Selected = "LRU"
-		formItemRecencyMode := widget.NewFormItem("Recency Mode?", radio)
+
+		activityFormItem := widget.NewFormItem("Activity", activitySelect)
+		formItems := []*widget.FormItem{formItem, formListTasks, commentFormItem, postCommentFormItem, activityFormItem, accountFormItem, projectFormItem, formItemRememberAccount}
+		formItems = append(formItems, attributeForm.FormItems()...)
+		formItems = append(formItems, estimateForm.FormItems()...)
+		formItems = append(formItems, formItemJIRACheck)
 
 		startDialog = dialog.NewForm("Starting a task", "                        Enter                        ",
 			"                        Cancel                        ",
-			[]*widget.FormItem{
-				formListRecent, formItem, commentFormItem, accountFormItem, projectFormItem, formItemJIRACheck, formItemRecencyMode}, func(validTask bool) {
+			formItems, func(validTask bool) {
 				if validTask {
+					if attributeError := attributeForm.Validate(); attributeError != nil {
+						dialog.NewError(attributeError, myWindow).Show()
+						return
+					}
+					if accountSelected != "" {
+						if estimateError := estimateForm.Apply(entry.Text); estimateError != nil {
+							dialog.NewError(estimateError, myWindow).Show()
+							return
+						}
+					}
+					rememberTaskAttributes(entry.Text, attributeForm.Values())
 					if accountSelected != "" {
-						startWorkAndResetUI(getElementFromStringWithColon(entry.Text, 0), getElementFromStringWithColon(entry.Text, 1), getElementFromStringWithColon(accountSelected, 0), getElementFromStringWithColon(accountSelected, 1), commentEntry.Text)
+						rememberPostCommentToIssue(entry.Text, postCommentCheck.Checked)
+					}
+					if rememberAccountCheck.Checked {
+						rememberAccountForProject(entry.Text, projectEntry.Text, accountSelected)
+					}
+					if accountSelected != "" {
+						startWorkFromDialog(getElementFromStringWithColon(entry.Text, 0), getElementFromStringWithColon(entry.Text, 1), getElementFromStringWithColon(accountSelected, 0), getElementFromStringWithColon(accountSelected, 1), commentEntry.Text, activitySelected)
 					} else {
-						startWorkAndResetUI(entry.Text, entry.Text, accountSelected, accountSelected, commentEntry.Text)
+						startWorkFromDialog(entry.Text, entry.Text, accountSelected, accountSelected, commentEntry.Text, activitySelected)
 					}
 				}
 			}, myWindow)
+		startDialog.SetOnClosed(picker.Stop)
 
 		entry.OnSubmitted = func(entryString string) {
 			entryError := entry.Validate()
 			if entryError == nil {
+				if attributeError := attributeForm.Validate(); attributeError != nil {
+					dialog.NewError(attributeError, myWindow).Show()
+					return
+				}
+				if accountSelected != "" {
+					if estimateError := estimateForm.Apply(entry.Text); estimateError != nil {
+						dialog.NewError(estimateError, myWindow).Show()
+						return
+					}
+				}
+				rememberTaskAttributes(entry.Text, attributeForm.Values())
 				if accountSelected != "" {
-					startWorkAndResetUI(getElementFromStringWithColon(entryString, 0), getElementFromStringWithColon(entryString, 1), getElementFromStringWithColon(accountSelected, 0), getElementFromStringWithColon(accountSelected, 1), commentEntry.Text)
+					rememberPostCommentToIssue(entry.Text, postCommentCheck.Checked)
+				}
+				if rememberAccountCheck.Checked {
+					rememberAccountForProject(entry.Text, projectEntry.Text, accountSelected)
+				}
+				if accountSelected != "" {
+					startWorkFromDialog(getElementFromStringWithColon(entryString, 0), getElementFromStringWithColon(entryString, 1), getElementFromStringWithColon(accountSelected, 0), getElementFromStringWithColon(accountSelected, 1), commentEntry.Text, activitySelected)
 				} else {
-					startWorkAndResetUI(entry.Text, entry.Text, accountSelected, accountSelected, commentEntry.Text)
+					startWorkFromDialog(entry.Text, entry.Text, accountSelected, accountSelected, commentEntry.Text, activitySelected)
 				}
 				startDialog.Hide()
 			}
@@ -1392,12 +1380,7 @@ func main() {
 	b2 = widget.NewButton("\r\nStop\r\n", func() {
 		currentTaskBoundString, currentTaskBindingError := currentTask.Get()
 		if currentTaskBoundString != "" && currentTaskBindingError == nil {
-			stopWork(currentTask, currentTaskName, currentAccount, currentAccountName, currentComment)
-			b1.Enable()
-			b2.Disable()
-			b3.Disable()
-			idlenessDurationDisplay.Set("")
-			idlenessInstantDisplay.Set("")
+			stopWorkFromButton()
 		}
 	})
 	b2.Disable()
@@ -1405,7 +1388,6 @@ func main() {
 	b3 = widget.NewButton("\r\nLog Idle\r\n", func() {
 		var logIdleDialog dialog.Dialog
 		continueOnIdleTask := true
-		var entryOptions []string = make([]string, 0)
 		var accountOptions []string = make([]string, 0)
 		var accountOptionsFiltered []string = make([]string, 0)
 		var accountSelected string
@@ -1452,95 +1434,62 @@ func main() {
 		}
 
 		commentEntry := widget.NewEntry()
+		postCommentCheck := widget.NewCheck("", nil)
+		postCommentCheck.SetChecked(trackerConfig.Comments.PostToIssueByDefault)
 		projectEntry := widget.NewEntry()
 		projectEntry.Disable()
 
-		var workLogHistoryAsStrings = getStringFromHistory(worklogHistory, "LRU")
-		recentEntry := widget.NewSelect(workLogHistoryAsStrings, func(s string) {
-			var historyEntry WorkLogHistoryEntry
-			json.Unmarshal([]byte(s), &historyEntry)
+		attributeForm := newWorkAttributeForm(getDefaultConnection())
+		estimateForm := newRemainingEstimateForm()
+		var activitySelected string
+		activitySelect := newActivitySelect(func(activity string) {
+			activitySelected = activity
+		})
 
-			logIdleWorkAndResetUI(historyEntry.Task, historyEntry.TaskName, historyEntry.Account, historyEntry.AccountName, historyEntry.Comment)
+		picker := newTaskPicker()
+		picker.OnHistoryChosen = func(historyEntry WorkLogHistoryEntry) {
+			logIdleWorkAndResetUI(historyEntry.Task, historyEntry.TaskName, historyEntry.Account, historyEntry.AccountName, historyEntry.Comment, historyEntry.Activity)
 			if continueOnIdleTask {
-				startWorkAndResetUI(historyEntry.Task, historyEntry.TaskName, historyEntry.Account, historyEntry.AccountName, historyEntry.Comment)
+				startWorkFromDialog(historyEntry.Task, historyEntry.TaskName, historyEntry.Account, historyEntry.AccountName, historyEntry.Comment, historyEntry.Activity)
 			}
 			logIdleDialog.Hide()
-		})
-
-		entry := widget.NewSelectEntry(entryOptions)
-		entry.OnChanged = func(changeEntry string) {
+		}
+		picker.OnChanged = func(changeEntry string) {
 			accountOptions = nil
 			accountEntry.SetOptions(nil)
 			accountEntry.Refresh()
 			accountEntry.SetText("")
-			for _, option := range entryOptions {
-				if option == changeEntry {
-					entry.SetText(getElementFromStringWithColon(changeEntry, 0))
-					entryOptions = nil
-					entry.SetOptions(nil)
-					entry.TextStyle.Bold = false
-					entry.TextStyle.Italic = false
-					entry.Refresh()
-					projectAndAccountForIssue := getProjectAndAccountForIssue(entry.Text)
-					if projectAndAccountForIssue != (IssueWithProjectAndActivity{}) {
-						if projectAndAccountForIssue.Fields.Customfield10900.Key != "" {
-							standardAccount := fmt.Sprintf("%s:%s", projectAndAccountForIssue.Fields.Customfield10900.Key, projectAndAccountForIssue.Fields.Customfield10900.Name)
-							accountOptions = append(accountOptions, standardAccount)
-							if standardAccount != "" {
-								accountEntry.SetText(standardAccount)
-							}
-						}
-						projectEntry.Text = fmt.Sprintf("%s:%s:%s", projectAndAccountForIssue.Fields.Project.ID, projectAndAccountForIssue.Fields.Project.Key, projectAndAccountForIssue.Fields.Project.Name)
-						if len(projectAndAccountForIssue.Fields.Project.ID) > 0 {
-							accountOptions = append(accountOptions, getAccountsForProject(projectAndAccountForIssue.Fields.Project.ID)...)
-						}
-					}
-					accountEntry.SetOptions(accountOptions)
-					accountEntry.Refresh()
-					projectEntry.Refresh()
-					return
-				}
-				if strings.Contains(option, changeEntry) {
-					return
-				}
-			}
-			if len(changeEntry) > 3 {
-				issues := searchJIRAIsssue(changeEntry)
-				if len(issues) > 0 {
-					entry.TextStyle.Bold = true
-					entry.TextStyle.Italic = true
-					entry.Refresh()
-				} else {
-					entry.TextStyle.Bold = false
-					entry.TextStyle.Italic = false
-					entry.Refresh()
-				}
-				entryOptions = nil
-				entry.SetOptions(issues)
-				entryOptions = issues
-			} else {
-				entry.TextStyle.Bold = false
-				entry.TextStyle.Italic = false
-				entry.Refresh()
-			}
+			projectEntry.SetText("")
+		}
+		picker.OnIssueChosen = func(issue string) {
+			var standardAccount string
+			var project string
+			accountOptions, standardAccount, project = getAccountOptionsForIssue(getConnectionForTask(getElementFromStringWithColon(issue, 0)), getElementFromStringWithColon(issue, 0))
+			accountEntry.SetOptions(accountOptions)
+			accountEntry.SetText(standardAccount)
+			accountEntry.Refresh()
+			projectEntry.SetText(project)
+			attributeForm.SetConnection(getConnectionForTask(getElementFromStringWithColon(issue, 0)))
+			attributeForm.SetValues(getTaskAttributes(getElementFromStringWithColon(issue, 0)))
+			activitySelect.SetSelected(getActivityName(getDefaultActivityForTask(getElementFromStringWithColon(issue, 0))))
+			estimateForm.SetIssue(getElementFromStringWithColon(issue, 0))
+			postCommentCheck.SetChecked(shouldPostCommentToIssue(getElementFromStringWithColon(issue, 0)))
 		}
+
+		entry := picker.Entry
 		entry.Validator = taskValidator
-		formListRecent := widget.NewFormItem("Choose recent", recentEntry)
-		formItem := widget.NewFormItem("Enter task you did while idle", entry)
+		formItem := widget.NewFormItem("Task you did while idle", entry)
+		formListTasks := widget.NewFormItem("", picker.Container(fyne.NewSize(600, 180)))
 		commentFormItem := widget.NewFormItem("Comment", commentEntry)
+		postCommentFormItem := widget.NewFormItem("Post comment to issue?", postCommentCheck)
 		accountFormItem := widget.NewFormItem("Account", accountEntry)
 		projectFormItem := widget.NewFormItem("Project", projectEntry)
+		rememberAccountCheck := widget.NewCheck("", nil)
+		formItemRememberAccount := widget.NewFormItem("Remember account for project?", rememberAccountCheck)
 
 		jiraCheck := widget.NewCheckWithData("", searchJIRAForTasks)
 		jiraCheck.Checked = true
 		formItemJIRACheck := widget.NewFormItem("Search JIRA for tasks?", jiraCheck)
-		radio := widget.NewRadioGroup([]string{"LRU", "LFU", "A to Z", "Z to A"}, func(value string) {
-			recentEntry.Options = getStringFromHistory(worklogHistory, value)
-			recentEntry.Refresh()
-		})
-		radio.Horizontal = true
-		radio.Selected = "LRU"
-		formItemRecencyMode := widget.NewFormItem("Recency Mode?", radio)
 
 		entryCheck := widget.NewCheck("", func(checked bool) {
 			continueOnIdleTask = checked
@@ -1548,36 +1497,76 @@ func main() {
 		entryCheck.Checked = true
 		formItemCheck := widget.NewFormItem("Continue working on this task", entryCheck)
 
+		activityFormItem := widget.NewFormItem("Activity", activitySelect)
+		formItems := []*widget.FormItem{formItem, formListTasks, commentFormItem, postCommentFormItem, activityFormItem, accountFormItem, projectFormItem, formItemRememberAccount}
+		formItems = append(formItems, attributeForm.FormItems()...)
+		formItems = append(formItems, estimateForm.FormItems()...)
+		formItems = append(formItems, formItemJIRACheck, formItemCheck)
+
 		logIdleDialog = dialog.NewForm("Logging Idle Time", "                        Enter                        ",
 			"                        Cancel                        ",
-			[]*widget.FormItem{
-				formListRecent, formItem, commentFormItem, accountFormItem, projectFormItem, formItemJIRACheck, formItemRecencyMode, formItemCheck}, func(validTask bool) {
+			formItems, func(validTask bool) {
 				if validTask {
+					if attributeError := attributeForm.Validate(); attributeError != nil {
+						dialog.NewError(attributeError, myWindow).Show()
+						return
+					}
 					if accountSelected != "" {
-						logIdleWorkAndResetUI(getElementFromStringWithColon(entry.Text, 0), getElementFromStringWithColon(entry.Text, 1), getElementFromStringWithColon(accountSelected, 0), getElementFromStringWithColon(accountSelected, 1), commentEntry.Text)
+						if estimateError := estimateForm.Apply(entry.Text); estimateError != nil {
+							dialog.NewError(estimateError, myWindow).Show()
+							return
+						}
+					}
+					rememberTaskAttributes(entry.Text, attributeForm.Values())
+					if accountSelected != "" {
+						rememberPostCommentToIssue(entry.Text, postCommentCheck.Checked)
+					}
+					if rememberAccountCheck.Checked {
+						rememberAccountForProject(entry.Text, projectEntry.Text, accountSelected)
+					}
+					if accountSelected != "" {
+						logIdleWorkAndResetUI(getElementFromStringWithColon(entry.Text, 0), getElementFromStringWithColon(entry.Text, 1), getElementFromStringWithColon(accountSelected, 0), getElementFromStringWithColon(accountSelected, 1), commentEntry.Text, activitySelected)
 						if continueOnIdleTask {
-							startWorkAndResetUI(getElementFromStringWithColon(entry.Text, 0), getElementFromStringWithColon(entry.Text, 1), getElementFromStringWithColon(accountSelected, 0), getElementFromStringWithColon(accountSelected, 1), commentEntry.Text)
+							startWorkFromDialog(getElementFromStringWithColon(entry.Text, 0), getElementFromStringWithColon(entry.Text, 1), getElementFromStringWithColon(accountSelected, 0), getElementFromStringWithColon(accountSelected, 1), commentEntry.Text, activitySelected)
 						}
 					} else {
-						logIdleWorkAndResetUI(entry.Text, entry.Text, accountSelected, accountSelected, commentEntry.Text)
+						logIdleWorkAndResetUI(entry.Text, entry.Text, accountSelected, accountSelected, commentEntry.Text, activitySelected)
 						if continueOnIdleTask {
-							startWorkAndResetUI(entry.Text, entry.Text, accountSelected, accountSelected, commentEntry.Text)
+							startWorkFromDialog(entry.Text, entry.Text, accountSelected, accountSelected, commentEntry.Text, activitySelected)
 						}
 					}
 				}
 			}, myWindow)
+		logIdleDialog.SetOnClosed(picker.Stop)
 		entry.OnSubmitted = func(entryString string) {
 			entryError := entry.Validate()
 			if entryError == nil {
+				if attributeError := attributeForm.Validate(); attributeError != nil {
+					dialog.NewError(attributeError, myWindow).Show()
+					return
+				}
 				if accountSelected != "" {
-					logIdleWorkAndResetUI(getElementFromStringWithColon(entry.Text, 0), getElementFromStringWithColon(entry.Text, 1), getElementFromStringWithColon(accountSelected, 0), getElementFromStringWithColon(accountSelected, 1), commentEntry.Text)
+					if estimateError := estimateForm.Apply(entry.Text); estimateError != nil {
+						dialog.NewError(estimateError, myWindow).Show()
+						return
+					}
+				}
+				rememberTaskAttributes(entry.Text, attributeForm.Values())
+				if accountSelected != "" {
+					rememberPostCommentToIssue(entry.Text, postCommentCheck.Checked)
+				}
+				if rememberAccountCheck.Checked {
+					rememberAccountForProject(entry.Text, projectEntry.Text, accountSelected)
+				}
+				if accountSelected != "" {
+					logIdleWorkAndResetUI(getElementFromStringWithColon(entry.Text, 0), getElementFromStringWithColon(entry.Text, 1), getElementFromStringWithColon(accountSelected, 0), getElementFromStringWithColon(accountSelected, 1), commentEntry.Text, activitySelected)
 					if continueOnIdleTask {
-						startWorkAndResetUI(getElementFromStringWithColon(entryString, 0), getElementFromStringWithColon(entryString, 1), getElementFromStringWithColon(accountSelected, 0), getElementFromStringWithColon(accountSelected, 1), commentEntry.Text)
+						startWorkFromDialog(getElementFromStringWithColon(entryString, 0), getElementFromStringWithColon(entryString, 1), getElementFromStringWithColon(accountSelected, 0), getElementFromStringWithColon(accountSelected, 1), commentEntry.Text, activitySelected)
 					}
 				} else {
-					logIdleWorkAndResetUI(entry.Text, entry.Text, accountSelected, accountSelected, commentEntry.Text)
+					logIdleWorkAndResetUI(entry.Text, entry.Text, accountSelected, accountSelected, commentEntry.Text, activitySelected)
 					if continueOnIdleTask {
-						startWorkAndResetUI(entry.Text, entry.Text, accountSelected, accountSelected, commentEntry.Text)
+						startWorkFromDialog(entry.Text, entry.Text, accountSelected, accountSelected, commentEntry.Text, activitySelected)
 					}
 				}
 				logIdleDialog.Hide()
@@ -1590,11 +1579,31 @@ func main() {
 
 	b4 = widget.NewButton("\r\nExit\r\n", func() {
 		if working {
-			stopWork(currentTask, currentTaskName, currentAccount, currentAccountName, currentComment)
+			stopWork(currentTask, currentTaskName, currentAccount, currentAccountName, currentComment, currentActivity)
 		}
 		myApp.Quit()
 	})
 
+	showLogsButton := widget.NewButton("\r\nShow Logs\r\n", showLogsWindow)
+	pomodoroButton = widget.NewButton("\r\nFocus\r\n", togglePomodoro)
+	meetingsButton = widget.NewButton("\r\nMeetings\r\n", showMeetingsWindow)
+	if !hasCalendar() {
+		meetingsButton.Disable()
+	}
+	activityButton = widget.NewButton("\r\nActivity\r\n", showActivityWindow)
+	if !trackerConfig.WindowTracking.Enabled {
+		activityButton.Disable()
+	}
+	reviewButton = widget.NewButton("\r\nReview\r\n", showReviewWindow)
+	if trackerConfig.Submission.Mode != submissionDraft && len(getDrafts()) == 0 {
+		reviewButton.Disable()
+	}
+	timesheetButton = widget.NewButton("\r\nTimesheet\r\n", showTimesheetWindow)
+	if getDefaultConnection().isCloud() {
+		timesheetButton.Disable()
+	}
+	reportButton := widget.NewButton("\r\nReport\r\n", showActivityReport)
+
 	sep := container.New(layout.NewGridWrapLayout(fyne.NewSize(0, 14)), layout.NewSpacer())
 	labelsPlusStart := container.New(layout.NewVBoxLayout(), currentTaskLabelName, sep, widget.NewSeparator(), currentCommentLabelName, sep, widget.NewSeparator(), currentAccountLabelName, sep, widget.NewSeparator(), startLabelName, sep, widget.NewSeparator(), durationLabelName, sep, widget.NewSeparator(), idleDurationLabelName, sep, b1)
 	b2b3 := container.New(layout.NewGridLayout(2), b2, b3)
@@ -1621,7 +1630,7 @@ func main() {
 		container.NewTabItem(bingCopyright, container.NewMax(currentCopyRightLabelLink, iconWidget)))
 	tabs.SetTabLocation(container.TabLocationTop)
 
-	iconPlusExit := container.New(layout.NewVBoxLayout(), datePLusIP, widget.NewSeparator(), container.New(layout.NewGridWrapLayout(fyne.NewSize(400, 59)), currentStatusLabel), widget.NewSeparator(), container.New(layout.NewGridWrapLayout(fyne.NewSize(400, 238)), tabs), b4)
+	iconPlusExit := container.New(layout.NewVBoxLayout(), datePLusIP, widget.NewSeparator(), container.New(layout.NewGridWrapLayout(fyne.NewSize(400, 59)), currentStatusLabel), widget.NewSeparator(), container.New(layout.NewGridWrapLayout(fyne.NewSize(400, 238)), tabs), container.New(layout.NewGridLayout(4), showLogsButton, pomodoroButton, meetingsButton, activityButton, reviewButton, timesheetButton, reportButton, b4))
 	main := container.New(layout.NewGridLayout(3), labelsPlusStart, entriesPlusStopPlusIdle, iconPlusExit)
 	myWindow.SetContent(main)
 
@@ -1644,9 +1653,11 @@ func main() {
 			currenAccountBoundString, _ := currentAccount.Get()
 			currenAccountNameBoundString, _ := currentAccountName.Get()
 			currentCommentBoundString, _ := currentComment.Get()
+			currentActivityBoundString, _ := currentActivity.Get()
+			currentConnectionBoundString, _ := currentConnection.Get()
 			if currentTaskBoundString != "" && currentTaskBindingError == nil {
 				now := time.Now()
-				currentTaskDurationDisplay.Set(now.Sub(currentTaskStartInstant).String())
+				currentTaskDurationDisplay.Set(formatDurationWithEstimate(currentTaskBoundString, now.Sub(currentTaskStartInstant)))
 				if (now.Second()+1)%60 == 0 {
 					backupLogWork(currentTaskBoundString)
 				}
@@ -1654,6 +1665,12 @@ func main() {
 					checkIfStillWorking(currentTaskBoundString, myWindow)
 				}
 			}
+			if countdown, active := tickPomodoro(time.Now()); active {
+				currentTaskDurationDisplay.Set(countdown)
+			}
+			tickCalendar(time.Now())
+			tickReview(time.Now())
+			tickApprovals(time.Now())
 			durationAfterWhichWeAreConsideredIdle := time.Duration(10 * time.Minute)
 			idleDuration := getIdleDuration()
 			idlenessDurationDisplay.Set(idleDuration.String())
@@ -1665,11 +1682,11 @@ func main() {
 					b3.Enable()
 					idlenessInstant = time.Now().Truncate(durationAfterWhichWeAreConsideredIdle)
 					idlenessInstantDisplay.Set(idlenessInstant.Format("15:04:05"))
-					stopDueToIdleness(currentTaskBoundString, currentTaskNameBoundString, currenAccountBoundString, currenAccountNameBoundString, currentCommentBoundString, idlenessInstant)
+					stopDueToIdleness(currentTaskBoundString, currentConnectionBoundString, currentTaskNameBoundString, currenAccountBoundString, currenAccountNameBoundString, currentCommentBoundString, currentActivityBoundString, idlenessInstant)
 				}
 			} else { //we are not idle, are we maybe working and not tracking?
 				if idleDuration.Seconds() < 60 { // we are active
-					if !working { //we do not have a current task
+					if !working && !paused { //we do not have a current task
 						maybeWorkingDuration += 1        //one more second during which we are maybe working
 						if maybeWorkingDuration == 300 { //duration we are probably working - notify
 							checkIfWorkingAndNotTracking(myWindow)
@@ -1683,6 +1700,16 @@ func main() {
 		}
 	}()
 
+	registerGlobalHotkeys()
+	myApp.Lifecycle().SetOnStarted(func() {
+		if flag.NArg() > 0 {
+			if err := runInstanceCommand(flag.Args()); err != nil {
+				dialog.NewError(err, myWindow).Show()
+			}
+		}
+		setInstanceReady()
+	})
+
 	myWindow.CenterOnScreen()
 	myWindow.SetFixedSize(true)
 	myWindow.Resize(fyne.NewSize(1225, 460))
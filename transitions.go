@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const keepIssueStatus = "Keep current status"
+
+type JIRAUser struct {
+	AccountID   string `json:"accountId"`
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type IssueTransition struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	To   struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"to"`
+}
+
+type IssueTransitionsResponse struct {
+	Transitions []IssueTransition `json:"transitions"`
+}
+
+type IssueStatusAndAssignee struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Status struct {
+			Name string `json:"name"`
+		} `json:"status"`
+		Assignee *JIRAUser `json:"assignee"`
+	} `json:"fields"`
+}
+
+// getTransitionsConfigForIssue returns the workflow hooks of the issue's
+// project, falling back to the "*" entry for projects without their own.
+func getTransitionsConfigForIssue(issue string) ProjectTransitionsConfig {
+	projects := trackerConfig.Transitions.Projects
+	if config, found := projects[getProjectKeyFromIssueKey(issue)]; found {
+		return config
+	}
+	return projects["*"]
+}
+
+func getMyJIRAUser(connection *jiraConnection) (JIRAUser, error) {
+	connection.userMutex.Lock()
+	defer connection.userMutex.Unlock()
+	if connection.user != (JIRAUser{}) {
+		return connection.user, nil
+	}
+	err := connection.JIRA.Do(context.Background(), "GET", connection.apiPath("/myself"), nil, &connection.user)
+	return connection.user, err
+}
+
+func isSameJIRAUser(user JIRAUser, other JIRAUser) bool {
+	if user.AccountID != "" || other.AccountID != "" {
+		return user.AccountID == other.AccountID
+	}
+	return user.Name == other.Name
+}
+
+func getIssueStatusAndAssignee(issue string) (IssueStatusAndAssignee, error) {
+	var statusAndAssignee IssueStatusAndAssignee
+	connection := getConnectionForTask(issue)
+	path := connection.apiPath("/issue/%s?fields=status,assignee", url.PathEscape(issue))
+	err := connection.JIRA.Do(context.Background(), "GET", path, nil, &statusAndAssignee)
+	return statusAndAssignee, err
+}
+
+func getIssueTransitions(issue string) ([]IssueTransition, error) {
+	var transitionsResponse IssueTransitionsResponse
+	connection := getConnectionForTask(issue)
+	path := connection.apiPath("/issue/%s/transitions", url.PathEscape(issue))
+	err := connection.JIRA.Do(context.Background(), "GET", path, nil, &transitionsResponse)
+	return transitionsResponse.Transitions, err
+}
+
+func transitionIssue(issue string, transition IssueTransition) error {
+	myLogger.Printf("Transitioning %s with %s to %s", issue, transition.Name, transition.To.Name)
+	connection := getConnectionForTask(issue)
+	path := connection.apiPath("/issue/%s/transitions", url.PathEscape(issue))
+	body := map[string]interface{}{"transition": map[string]string{"id": transition.ID}}
+	return connection.JIRA.Do(context.Background(), "POST", path, body, nil)
+}
+
+func assignIssueToMe(issue string) error {
+	connection := getConnectionForTask(issue)
+	me, err := getMyJIRAUser(connection)
+	if err != nil {
+		return err
+	}
+	myLogger.Printf("Assigning %s to %s", issue, me.DisplayName)
+	path := connection.apiPath("/issue/%s/assignee", url.PathEscape(issue))
+	// JIRA Cloud knows users by account ID only
+	if connection.isCloud() {
+		return connection.JIRA.Do(context.Background(), "PUT", path, map[string]string{"accountId": me.AccountID}, nil)
+	}
+	return connection.JIRA.Do(context.Background(), "PUT", path, map[string]string{"name": me.Name}, nil)
+}
+
+// findTransition matches name against the transition names as well as the
+// names of the statuses they lead to, as workflows rarely name both alike.
+func findTransition(transitions []IssueTransition, name string) (IssueTransition, bool) {
+	for _, transition := range transitions {
+		if strings.EqualFold(transition.Name, name) || strings.EqualFold(transition.To.Name, name) {
+			return transition, true
+		}
+	}
+	return IssueTransition{}, false
+}
+
+// offerStartTransition asks whether to move a freshly started issue to the
+// configured status and to assign it to the user, skipping whatever is
+// already the case.
+func offerStartTransition(issue string) {
+	config := getTransitionsConfigForIssue(issue)
+	if config.StartTransition == "" && !config.AssignOnStart {
+		return
+	}
+
+	statusAndAssignee, err := getIssueStatusAndAssignee(issue)
+	if err != nil {
+		myWarningLogger.Printf("Could not get the status of %s: %s", issue, err.Error())
+		return
+	}
+
+	var transition IssueTransition
+	var transitionFound bool
+	if config.StartTransition != "" && !strings.EqualFold(statusAndAssignee.Fields.Status.Name, config.StartTransition) {
+		transitions, err := getIssueTransitions(issue)
+		if err != nil {
+			myWarningLogger.Printf("Could not get the transitions of %s: %s", issue, err.Error())
+		}
+		transition, transitionFound = findTransition(transitions, config.StartTransition)
+	}
+
+	assign := false
+	if config.AssignOnStart {
+		me, err := getMyJIRAUser(getConnectionForTask(issue))
+		if err != nil {
+			myWarningLogger.Printf("Could not get the current JIRA user: %s", err.Error())
+		} else {
+			assign = statusAndAssignee.Fields.Assignee == nil || !isSameJIRAUser(*statusAndAssignee.Fields.Assignee, me)
+		}
+	}
+
+	var question string
+	switch {
+	case transitionFound && assign:
+		question = fmt.Sprintf("Move %s from %s to %s and assign it to you?", issue, statusAndAssignee.Fields.Status.Name, transition.To.Name)
+	case transitionFound:
+		question = fmt.Sprintf("Move %s from %s to %s?", issue, statusAndAssignee.Fields.Status.Name, transition.To.Name)
+	case assign:
+		question = fmt.Sprintf("Assign %s to you?", issue)
+	default:
+		return
+	}
+
+	dialog.NewConfirm("Update JIRA issue", question, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		if transitionFound {
+			if err := transitionIssue(issue, transition); err != nil {
+				myErrorLogger.Printf("Got error %s", err.Error())
+				dialog.NewError(err, myWindow).Show()
+				return
+			}
+		}
+		if assign {
+			if err := assignIssueToMe(issue); err != nil {
+				myErrorLogger.Printf("Got error %s", err.Error())
+				dialog.NewError(err, myWindow).Show()
+			}
+		}
+	}, myWindow).Show()
+}
+
+// offerStopTransitions lets the user move a stopped issue along its
+// workflow, e.g. to review at the end of the day.
+func offerStopTransitions(issue string) {
+	if !getTransitionsConfigForIssue(issue).OfferOnStop {
+		return
+	}
+
+	transitions, err := getIssueTransitions(issue)
+	if err != nil {
+		myWarningLogger.Printf("Could not get the transitions of %s: %s", issue, err.Error())
+		return
+	}
+	if len(transitions) == 0 {
+		return
+	}
+
+	options := []string{keepIssueStatus}
+	for _, transition := range transitions {
+		options = append(options, fmt.Sprintf("%s → %s", transition.Name, transition.To.Name))
+	}
+	transitionSelect := widget.NewSelect(options, nil)
+	transitionSelect.SetSelected(keepIssueStatus)
+
+	dialog.NewForm(fmt.Sprintf("Stopped working on %s", issue), "Apply", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Transition", transitionSelect)}, func(confirmed bool) {
+			if !confirmed || transitionSelect.SelectedIndex() <= 0 {
+				return
+			}
+			if err := transitionIssue(issue, transitions[transitionSelect.SelectedIndex()-1]); err != nil {
+				myErrorLogger.Printf("Got error %s", err.Error())
+				dialog.NewError(err, myWindow).Show()
+			}
+		}, myWindow).Show()
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	workAttributeTypeAccount      = "ACCOUNT"
+	workAttributeTypeStaticList   = "STATIC_LIST"
+	workAttributeTypeCheckbox     = "CHECKBOX"
+	workAttributeTypeInputNumeric = "INPUT_NUMERIC"
+
+	workAttributesRetryInterval = 10 * time.Minute
+)
+
+type WorkAttribute struct {
+	ID               int                        `json:"id"`
+	Key              string                     `json:"key"`
+	Name             string                     `json:"name"`
+	Type             WorkAttributeType          `json:"type"`
+	ExternalURL      string                     `json:"externalUrl"`
+	Required         bool                       `json:"required"`
+	Sequence         int                        `json:"sequence"`
+	StaticListValues []WorkAttributeStaticValue `json:"staticListValues"`
+}
+
+type WorkAttributeType struct {
+	Name       string `json:"name"`
+	Value      string `json:"value"`
+	SystemType bool   `json:"systemType"`
+}
+
+type WorkAttributeStaticValue struct {
+	ID              int    `json:"id"`
+	Name            string `json:"name"`
+	Value           string `json:"value"`
+	Removed         bool   `json:"removed"`
+	Sequence        int    `json:"sequence"`
+	WorkAttributeID int    `json:"workAttributeId"`
+}
+
+type WorkAttributeValue struct {
+	Name            string `json:"name"`
+	WorkAttributeID int    `json:"workAttributeId"`
+	Value           string `json:"value"`
+}
+
+// workAttributeForm holds one input per work attribute that the user fills
+// in by hand. The account and the activity are picked separately and the
+// work location is derived from the public IP, so none of them gets an input
+// here. The inputs follow the schema of the connection of the chosen task, so
+// they sit in a container of their own that SetConnection fills again.
+type workAttributeForm struct {
+	attributes []WorkAttribute
+	selects    map[string]*widget.Select
+	checks     map[string]*widget.Check
+	entries    map[string]*widget.Entry
+	container  *fyne.Container
+}
+
+func getWorkAttributeDefinitions(connection *jiraConnection) ([]WorkAttribute, error) {
+	if connection.isCloud() {
+		return getCloudWorkAttributeDefinitions(connection)
+	}
+	path := "/rest/tempo-core/1/work-attribute"
+	myLogger.Printf("Requesting work attributes from Tempo of %s %s", connection.Config.Name, path)
+
+	var attributes []WorkAttribute
+	err := connection.Tempo.Do(context.Background(), "GET", path, nil, &attributes)
+	if err != nil {
+		myErrorLogger.Printf("Got error %s", err.Error())
+		return nil, err
+	}
+	sort.SliceStable(attributes, func(i, j int) bool {
+		return attributes[i].Sequence < attributes[j].Sequence
+	})
+	return attributes, nil
+}
+
+// getWorkAttributes returns the work attribute schema of a connection for
+// the dialogs. The schema is fetched once per session in the background, so
+// that the dialogs never wait for Tempo; until it arrives, and while Tempo
+// cannot be reached, the dialogs have no attribute inputs.
+func getWorkAttributes(connection *jiraConnection) []WorkAttribute {
+	connection.workAttributesMutex.Lock()
+	defer connection.workAttributesMutex.Unlock()
+
+	if !connection.workAttributesFetched && !connection.workAttributesLoading && !time.Now().Before(connection.workAttributesRetry) {
+		connection.workAttributesLoading = true
+		go loadWorkAttributes(connection)
+	}
+	return connection.workAttributes
+}
+
+// requireWorkAttributes returns the work attribute schema of a connection for
+// posting a worklog, fetching it right away when it has not arrived yet. Ids
+// and keys of the attributes differ between Tempo instances, so a worklog is
+// not posted at all rather than with a schema that was not fetched.
+func requireWorkAttributes(connection *jiraConnection) ([]WorkAttribute, error) {
+	connection.workAttributesMutex.Lock()
+	if connection.workAttributesFetched {
+		defer connection.workAttributesMutex.Unlock()
+		return connection.workAttributes, nil
+	}
+	connection.workAttributesMutex.Unlock()
+
+	attributes, err := getWorkAttributeDefinitions(connection)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the work attributes of %s: %w", connection.Config.Name, err)
+	}
+	storeWorkAttributes(connection, attributes)
+	return attributes, nil
+}
+
+// loadWorkAttributes fetches the work attribute schema of a connection. After
+// a failure it is fetched again in the background no earlier than
+// workAttributesRetryInterval later.
+func loadWorkAttributes(connection *jiraConnection) {
+	attributes, err := getWorkAttributeDefinitions(connection)
+	if err != nil {
+		connection.workAttributesMutex.Lock()
+		defer connection.workAttributesMutex.Unlock()
+		connection.workAttributesLoading = false
+		myLogger.Printf("Fetching the work attributes of %s again in %s", connection.Config.Name, workAttributesRetryInterval.String())
+		connection.workAttributesRetry = time.Now().Add(workAttributesRetryInterval)
+		return
+	}
+	storeWorkAttributes(connection, attributes)
+}
+
+func storeWorkAttributes(connection *jiraConnection, attributes []WorkAttribute) {
+	connection.workAttributesMutex.Lock()
+	defer connection.workAttributesMutex.Unlock()
+	myLogger.Printf("Retrieved %d work attributes of %s", len(attributes), connection.Config.Name)
+	connection.workAttributes = attributes
+	connection.workAttributesFetched = true
+	connection.workAttributesLoading = false
+}
+
+func isManualWorkAttribute(attribute WorkAttribute) bool {
+	return attribute.Type.Value != workAttributeTypeAccount && attribute.Key != trackerConfig.WorkAttributes.LocationAttributeKey && attribute.Key != trackerConfig.WorkAttributes.ActivityAttributeKey
+}
+
+func newWorkAttributeForm(connection *jiraConnection) *workAttributeForm {
+	form := &workAttributeForm{container: container.NewVBox()}
+	form.SetConnection(connection)
+	return form
+}
+
+// SetConnection replaces the inputs by those of the schema of connection and
+// fills them with the configured defaults.
+func (form *workAttributeForm) SetConnection(connection *jiraConnection) {
+	form.attributes = nil
+	form.selects = make(map[string]*widget.Select)
+	form.checks = make(map[string]*widget.Check)
+	form.entries = make(map[string]*widget.Entry)
+
+	var items []*widget.FormItem
+	for _, attribute := range getWorkAttributes(connection) {
+		if !isManualWorkAttribute(attribute) {
+			continue
+		}
+		attribute := attribute
+		form.attributes = append(form.attributes, attribute)
+		label := attribute.Name
+		if attribute.Required {
+			label += " *"
+		}
+
+		switch attribute.Type.Value {
+		case workAttributeTypeStaticList:
+			var options []string
+			for _, value := range attribute.StaticListValues {
+				if !value.Removed {
+					options = append(options, value.Name)
+				}
+			}
+			selectWidget := widget.NewSelect(options, nil)
+			form.selects[attribute.Key] = selectWidget
+			items = append(items, widget.NewFormItem(label, selectWidget))
+		case workAttributeTypeCheckbox:
+			check := widget.NewCheck("", nil)
+			form.checks[attribute.Key] = check
+			items = append(items, widget.NewFormItem(label, check))
+		default:
+			entry := widget.NewEntry()
+			entry.Validator = func(text string) error {
+				if text == "" {
+					if attribute.Required {
+						return errors.New("value required")
+					}
+					return nil
+				}
+				if attribute.Type.Value == workAttributeTypeInputNumeric {
+					if _, err := strconv.ParseFloat(text, 64); err != nil {
+						return errors.New("number required")
+					}
+				}
+				return nil
+			}
+			form.entries[attribute.Key] = entry
+			items = append(items, widget.NewFormItem(label, entry))
+		}
+	}
+	form.container.Objects = nil
+	if len(items) > 0 {
+		form.container.Objects = []fyne.CanvasObject{widget.NewForm(items...)}
+	}
+	form.container.Refresh()
+	form.SetValues(nil)
+}
+
+func (form *workAttributeForm) FormItems() []*widget.FormItem {
+	return []*widget.FormItem{widget.NewFormItem("", form.container)}
+}
+
+// SetValues fills the inputs with the given values, using the configured
+// defaults for attributes that have no value.
+func (form *workAttributeForm) SetValues(values map[string]string) {
+	for _, attribute := range form.attributes {
+		value, found := values[attribute.Key]
+		if !found {
+			value = trackerConfig.WorkAttributes.Defaults[attribute.Key]
+		}
+		if selectWidget, found := form.selects[attribute.Key]; found {
+			selectWidget.ClearSelected()
+			for _, staticValue := range attribute.StaticListValues {
+				if staticValue.Value == value && !staticValue.Removed {
+					selectWidget.SetSelected(staticValue.Name)
+				}
+			}
+		}
+		if check, found := form.checks[attribute.Key]; found {
+			check.SetChecked(value == "true")
+		}
+		if entry, found := form.entries[attribute.Key]; found {
+			entry.SetText(value)
+		}
+	}
+}
+
+func (form *workAttributeForm) Values() map[string]string {
+	values := make(map[string]string)
+	for _, attribute := range form.attributes {
+		if selectWidget, found := form.selects[attribute.Key]; found {
+			for _, staticValue := range attribute.StaticListValues {
+				if staticValue.Name == selectWidget.Selected {
+					values[attribute.Key] = staticValue.Value
+				}
+			}
+		}
+		if check, found := form.checks[attribute.Key]; found {
+			values[attribute.Key] = strconv.FormatBool(check.Checked)
+		}
+		if entry, found := form.entries[attribute.Key]; found && entry.Text != "" {
+			values[attribute.Key] = entry.Text
+		}
+	}
+	return values
+}
+
+// Validate checks the required static lists and the entries. The inputs are
+// not items of the dialog form itself, so it does not validate them.
+func (form *workAttributeForm) Validate() error {
+	for _, attribute := range form.attributes {
+		if selectWidget, found := form.selects[attribute.Key]; found && attribute.Required && selectWidget.Selected == "" {
+			return fmt.Errorf("%s is required", attribute.Name)
+		}
+		if entry, found := form.entries[attribute.Key]; found {
+			if err := entry.Validate(); err != nil {
+				return fmt.Errorf("%s: %w", attribute.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func getTaskAttributes(task string) map[string]string {
+	workLogHistoryMutex.Lock()
+	defer workLogHistoryMutex.Unlock()
+	return worklogHistory.TaskAttributes[task]
+}
+
+// rememberTaskAttributes keeps the attribute values of a task, they are
+// written to work.history with the next worklog and used for all worklogs
+// of the task until changed.
+func rememberTaskAttributes(task string, values map[string]string) {
+	task = getElementFromStringWithColon(task, 0)
+	workLogHistoryMutex.Lock()
+	defer workLogHistoryMutex.Unlock()
+	if worklogHistory.TaskAttributes == nil {
+		worklogHistory.TaskAttributes = make(map[string]map[string]string)
+	}
+	worklogHistory.TaskAttributes[task] = values
+}
+
+func getWorkLocation(publicIP string) string {
+	if trackerConfig.WorkAttributes.OfficeIPPrefix != "" && strings.HasPrefix(publicIP, trackerConfig.WorkAttributes.OfficeIPPrefix) {
+		return trackerConfig.WorkAttributes.OfficeValue
+	}
+	return trackerConfig.WorkAttributes.HomeValue
+}
+
+func buildWorkAttributes(connection *jiraConnection, task string, accountValue string, activity string, workLocation string) (map[string]WorkAttributeValue, error) {
+	schema, err := requireWorkAttributes(connection)
+	if err != nil {
+		return nil, err
+	}
+	taskAttributes := getTaskAttributes(task)
+	attributes := make(map[string]WorkAttributeValue)
+	for _, attribute := range schema {
+		var value string
+		if attribute.Type.Value == workAttributeTypeAccount {
+			value = accountValue
+		} else if attribute.Key == trackerConfig.WorkAttributes.LocationAttributeKey {
+			value = workLocation
+		} else if attribute.Key == trackerConfig.WorkAttributes.ActivityAttributeKey && activity != "" {
+			value = activity
+		} else if taskValue, found := taskAttributes[attribute.Key]; found {
+			value = taskValue
+		} else {
+			value = trackerConfig.WorkAttributes.Defaults[attribute.Key]
+		}
+		if value == "" {
+			if attribute.Required {
+				myWarningLogger.Printf("No value for required work attribute %s on %s", attribute.Key, task)
+			}
+			continue
+		}
+		attributes[attribute.Key] = WorkAttributeValue{Name: attribute.Name, WorkAttributeID: attribute.ID, Value: value}
+	}
+	return attributes, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBuildWorkAttributesRequiresFetchedSchema(t *testing.T) {
+	useTempDataDirectory(t)
+	useWorkLogHistory(t, WorkLogHistoryRoot{})
+	online := false
+	tempo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !online {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`[{"id":7,"key":"_Location_","name":"Location","type":{"value":"STATIC_LIST"},"required":true,"sequence":1},` +
+			`{"id":9,"key":"_Billing_","name":"Billing","type":{"value":"ACCOUNT"},"required":true,"sequence":0}]`))
+	}))
+	defer tempo.Close()
+
+	connection := newJIRAConnection(ConnectionConfig{Name: "server", BaseURL: tempo.URL})
+	connection.JIRA.Trace = nil
+	previousConfig := trackerConfig
+	trackerConfig.WorkAttributes.LocationAttributeKey = "_Location_"
+	t.Cleanup(func() { trackerConfig = previousConfig })
+
+	if attributes, err := buildWorkAttributes(connection, "ABC-1", "ACC", "", "Home"); err == nil {
+		t.Fatalf("buildWorkAttributes() without a schema = %+v, want an error", attributes)
+	}
+
+	online = true
+	attributes, err := buildWorkAttributes(connection, "ABC-1", "ACC", "", "Home")
+	if err != nil {
+		t.Fatalf("buildWorkAttributes() error %v", err)
+	}
+	want := map[string]WorkAttributeValue{
+		"_Billing_":  {Name: "Billing", WorkAttributeID: 9, Value: "ACC"},
+		"_Location_": {Name: "Location", WorkAttributeID: 7, Value: "Home"},
+	}
+	if len(attributes) != len(want) {
+		t.Fatalf("buildWorkAttributes() = %+v, want %+v", attributes, want)
+	}
+	for key, value := range want {
+		if attributes[key] != value {
+			t.Errorf("attribute %s = %+v, want %+v", key, attributes[key], value)
+		}
+	}
+	if fetched := getWorkAttributes(connection); len(fetched) != 2 {
+		t.Errorf("getWorkAttributes() after posting = %+v, want the fetched schema", fetched)
+	}
+}